@@ -29,6 +29,16 @@ func run() error {
 		return cli.CmdTransit(os.Args[2:])
 	case "server":
 		return cli.CmdServer(os.Args[2:])
+	case "extract":
+		return cli.CmdExtract(os.Args[2:])
+	case "update":
+		return cli.CmdUpdate(os.Args[2:])
+	case "graph-diff":
+		return cli.CmdGraphDiff(os.Args[2:])
+	case "validate":
+		return cli.CmdValidate(os.Args[2:])
+	case "profiles":
+		return cli.CmdProfiles(os.Args[2:])
 	case "help":
 		cli.PrintUsage()
 		return nil
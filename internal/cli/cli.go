@@ -1,17 +1,28 @@
 package cli
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/danielscoffee/pathcraft/internal/elevation"
+	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/geojson"
+	"github.com/danielscoffee/pathcraft/internal/graph"
 	"github.com/danielscoffee/pathcraft/internal/gtfs"
 	"github.com/danielscoffee/pathcraft/internal/http"
 	"github.com/danielscoffee/pathcraft/internal/mobility"
+	"github.com/danielscoffee/pathcraft/internal/osm"
+	"github.com/danielscoffee/pathcraft/internal/profiles"
 	"github.com/danielscoffee/pathcraft/internal/routing/raptor"
-	pcTime "github.com/danielscoffee/pathcraft/internal/time"
 	"github.com/danielscoffee/pathcraft/pkg/pathcraft/engine"
 )
 
@@ -22,34 +33,70 @@ func PrintUsage() {
 	pathcraft <command> [options]
 
 	Commands:
-	parse    Parse OSM file and show statistics
-	route    Find route between two points (walking)
-	transit  Find transit route using RAPTOR algorithm
-	server   Start HTTP server with routing endpoints
-	help     Show this help message
+	parse      Parse OSM file and show statistics
+	route      Find route between two points (walking, wheelchair, ...)
+	transit    Find transit route using RAPTOR algorithm
+	server     Start HTTP server with routing endpoints
+	extract    Cut a bounding box or polygon out of an OSM file or graph cache
+	update     Apply an OsmChange file (.osc) to a graph cache
+	graph-diff Compare two graph caches
+	validate   Check a graph cache for integrity problems
+	profiles   List built-in and file profiles, or validate profile files
+	help       Show this help message
 
 	Examples:
 	pathcraft parse --file map.osm
 	pathcraft route --file map.osm --from 1 --to 100
 	pathcraft route --file map.osm --from 1 --to 100 --coords
-	pathcraft transit --gtfs ./gtfs --from MAIN_ST --to HARBOR --time 08:00:00
+	pathcraft route --file map.osm --from 1 --to 100 --dem ./srtm
+	pathcraft route --file map.osm --from 1 --to 100 --profile wheelchair
+	pathcraft route --file map.osm --from 1 --to 100 --profile cycling --cycle-preference 0.8
+	pathcraft route --file map.osm --from 1 --to 100 --profile-file truck.yaml
+	pathcraft profiles validate examples/profiles/*.yaml
+	pathcraft transit --gtfs ./gtfs --from MAIN_ST --to HARBOR --date 2025-03-10 --time 08:00:00
 	pathcraft server --file map.osm --addr :8080
+	pathcraft parse --file north.osm.gz --file south.osm.gz
+	pathcraft extract --file map.osm --bbox 12.56,55.67,12.58,55.68 --out small.cache --osm-out small.osm
+	pathcraft extract --graph map.osm.cache --polygon area.geojson --out small.cache
+	pathcraft parse --file map.osm --updatable
+	pathcraft update --graph map.osm.cache --diff changes.osc
+	pathcraft graph-diff old.cache new.cache --geojson diff.geojson
+	pathcraft validate --graph map.osm.cache --json
 	`)
 }
 
 func CmdServer(args []string) error {
 	fs := flag.NewFlagSet("server", flag.ExitOnError)
-	file := fs.String("file", "", "OSM file to parse (.osm or .osm.gz)")
+	var files fileList
+	fs.Var(&files, "file", fileUsage)
 	addr := fs.String("addr", ":8080", "HTTP server address")
+	dem := fs.String("dem", "", "Elevation tiles (.hgt/.tif file or directory)")
+	minComponent := fs.Int("min-component", 0, minComponentUsage)
+	flagIslands := fs.Bool("flag-islands", false, flagIslandsUsage)
+	simplify := fs.Bool("simplify", false, simplifyUsage)
+	profileName := fs.String("profile", "walking", profileUsage())
+	profileFile := fs.String("profile-file", "", profileFileUsage)
+	cyclePreference := fs.Float64("cycle-preference", mobility.DefaultInfrastructurePreference, cyclePreferenceUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *file == "" {
+	if len(files) == 0 {
 		return fmt.Errorf("--file is required")
 	}
 
-	e, err := loadEngine(*file)
+	profile, fingerprint, err := resolveProfile(*profileName, *profileFile, 0, *cyclePreference)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := buildConfig(profile, *dem, *minComponent, *flagIslands)
+	if err != nil {
+		return err
+	}
+	cfg.Simplify = *simplify
+
+	e, err := loadEngine(files, cfg, fingerprint)
 	if err != nil {
 		return err
 	}
@@ -59,22 +106,146 @@ func CmdServer(args []string) error {
 	return nil
 }
 
-func loadEngine(file string) (*engine.Engine, error) {
-	e := engine.New()
-	cacheFile := file + ".cache"
+func profileUsage() string {
+	return fmt.Sprintf("Routing profile (%s)", strings.Join(mobility.Available(), ", "))
+}
+
+const fileUsage = "OSM file to parse (.osm or .osm.gz), repeat to merge neighbouring extracts"
+
+// fileList collects a flag that may be given several times.
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+const profileFileUsage = "Profile definition file (.json, .yaml or .yml), overrides --profile"
+
+const cyclePreferenceUsage = "For --profile cycling, from 0 (fastest path) to 1 (long detours to stay on cycleways and quiet streets)"
+
+// resolveProfile returns the built-in profile name, or the profile defined
+// in file when one is given. The fingerprint identifies the file contents
+// so that editing a profile invalidates its cached graphs; cycling with
+// other than the default cycle preference is fingerprinted by it.
+func resolveProfile(name, file string, speed, cyclePreference float64) (mobility.Profile, string, error) {
+	if cyclePreference != mobility.DefaultInfrastructurePreference {
+		switch {
+		case name != "cycling" || file != "":
+			return nil, "", fmt.Errorf("--cycle-preference only applies to --profile cycling")
+		case cyclePreference < 0 || cyclePreference > 1:
+			return nil, "", fmt.Errorf("--cycle-preference must be between 0 and 1")
+		}
+		profile := mobility.NewCyclingWithOptions(mobility.CyclingOptions{Speed: speed, InfrastructurePreference: cyclePreference})
+		return profile, fmt.Sprintf("pref%g", cyclePreference), nil
+	}
+	if file == "" {
+		profile, err := mobility.New(name, speed)
+		return profile, "", err
+	}
+	if speed != 0 {
+		return nil, "", fmt.Errorf("--speed cannot be combined with --profile-file; set speed_kmh in the file")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	profile, err := profiles.Load(file)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return profile, hex.EncodeToString(sum[:4]), nil
+}
+
+const minComponentUsage = "Drop disconnected fragments with fewer nodes (0 keeps all)"
+
+const flagIslandsUsage = "Keep components below --min-component and flag them instead of dropping"
+
+const simplifyUsage = "Collapse shape points into their edges (routes must then use junction or tagged node IDs)"
+
+func buildConfig(profile mobility.Profile, demPath string, minComponent int, flagIslands bool) (engine.Config, error) {
+	cfg := engine.Config{
+		Profile:             profile,
+		MinComponentSize:    minComponent,
+		FlagSmallComponents: flagIslands,
+	}
+	if demPath == "" {
+		return cfg, nil
+	}
+
+	fmt.Printf("Loading elevation from %s...\n", demPath)
+	src, err := elevation.Open(demPath)
+	if err != nil {
+		return cfg, fmt.Errorf("loading elevation: %w", err)
+	}
+	cfg.Elevation = src
+	return cfg, nil
+}
+
+// cachePath keys the graph cache by everything that changes edge costs, so
+// switching profile or adding elevation never reuses a stale graph. The
+// plain walking graph keeps the historical name. File profiles add the
+// fingerprint of their definition, and cycling its cycle preference.
+// Merged graphs are stored next to the first file, keyed by the list of
+// files.
+func cachePath(files []string, cfg engine.Config, fingerprint string) string {
+	suffix := ""
+	if len(files) > 1 {
+		sum := sha256.Sum256([]byte(strings.Join(files, "\n")))
+		suffix += fmt.Sprintf(".merged%d-%s", len(files), hex.EncodeToString(sum[:4]))
+	}
+	if fingerprint != "" {
+		suffix += "." + cfg.Profile.Name() + "-" + fingerprint
+	} else if cfg.Profile != nil && cfg.Profile.Name() != "walking" {
+		suffix += "." + cfg.Profile.Name()
+	}
+	if cfg.Elevation != nil {
+		suffix += ".dem"
+	}
+	if cfg.Simplify {
+		suffix += ".simple"
+	}
+	if cfg.MinComponentSize > 0 {
+		suffix += fmt.Sprintf(".min%d", cfg.MinComponentSize)
+		if cfg.FlagSmallComponents {
+			suffix += "-flagged"
+		}
+	}
+	return files[0] + suffix + ".cache"
+}
+
+func loadEngine(files []string, cfg engine.Config, fingerprint string) (*engine.Engine, error) {
+	// A damaged cache is rebuilt rather than routed on.
+	cfg.ValidateOnLoad = true
+	e := engine.NewWithConfig(cfg)
+	cacheFile := cachePath(files, cfg, fingerprint)
 
 	if _, err := os.Stat(cacheFile); err == nil {
 		fmt.Printf("Loading from cache %s...\n", cacheFile)
-		if err := e.LoadGraph(cacheFile); err == nil {
+		err := e.LoadGraph(cacheFile)
+		switch {
+		case err != nil:
+			fmt.Printf("Cache load failed (%v), falling back to OSM parsing...\n", err)
+		case cfg.KeepSource && !e.HasSource():
+			fmt.Printf("Cache has no source data, parsing OSM again...\n")
+		default:
 			return e, nil
 		}
-		fmt.Printf("Cache load failed, falling back to OSM parsing...\n")
 	}
 
-	fmt.Printf("Parsing OSM %s...\n", file)
-	if err := e.LoadOSM(file); err != nil {
+	fmt.Printf("Parsing OSM %s...\n", strings.Join(files, ", "))
+	if err := e.LoadOSM(files...); err != nil {
 		return nil, err
 	}
+	for _, path := range e.LoadReport().Disconnected() {
+		fmt.Printf("Warning: %s does not connect to the main network\n", path)
+	}
 
 	fmt.Printf("Saving cache to %s...\n", cacheFile)
 	if err := e.SaveGraph(cacheFile); err != nil {
@@ -86,18 +257,42 @@ func loadEngine(file string) (*engine.Engine, error) {
 
 func CmdParse(args []string) error {
 	fs := flag.NewFlagSet("parse", flag.ExitOnError)
-	file := fs.String("file", "", "OSM file to parse (.osm or .osm.gz)")
+	var files fileList
+	fs.Var(&files, "file", fileUsage)
+	dem := fs.String("dem", "", "Elevation tiles (.hgt/.tif file or directory)")
+	minComponent := fs.Int("min-component", 0, minComponentUsage)
+	flagIslands := fs.Bool("flag-islands", false, flagIslandsUsage)
+	simplify := fs.Bool("simplify", false, simplifyUsage)
+	updatable := fs.Bool("updatable", false, "Save the OSM data next to the cache so that pathcraft update can apply change files")
+	profileName := fs.String("profile", "walking", profileUsage())
+	profileFile := fs.String("profile-file", "", profileFileUsage)
+	cyclePreference := fs.Float64("cycle-preference", mobility.DefaultInfrastructurePreference, cyclePreferenceUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *file == "" {
+	if len(files) == 0 {
 		return fmt.Errorf("--file is required")
 	}
+	if *updatable && *simplify {
+		return fmt.Errorf("--updatable cannot be combined with --simplify; simplified graphs cannot be updated")
+	}
 
 	start := time.Now()
 
-	e, err := loadEngine(*file)
+	profile, fingerprint, err := resolveProfile(*profileName, *profileFile, 0, *cyclePreference)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := buildConfig(profile, *dem, *minComponent, *flagIslands)
+	if err != nil {
+		return err
+	}
+	cfg.Simplify = *simplify
+	cfg.KeepSource = *updatable
+
+	e, err := loadEngine(files, cfg, fingerprint)
 	if err != nil {
 		return err
 	}
@@ -110,6 +305,10 @@ func CmdParse(args []string) error {
 	fmt.Printf("  Nodes: %d\n", stats.Nodes)
 	fmt.Printf("  Edges: %d\n", stats.Edges)
 
+	printNetwork(stats.NetworkStats)
+	printComponents(stats)
+	printSources(e.LoadReport())
+
 	fmt.Println()
 	fmt.Println("=== Timing ===")
 	fmt.Printf("  Load & Build: %v\n", loadTime)
@@ -117,25 +316,149 @@ func CmdParse(args []string) error {
 	return nil
 }
 
+// printNetwork reports the size and shape of the network: its length,
+// extent and how that length splits across highway classes.
+func printNetwork(stats graph.NetworkStats) {
+	fmt.Println()
+	fmt.Println("=== Network ===")
+	fmt.Printf("  Length:     %.1f km in %d links\n", stats.TotalLengthM/1000, stats.Links)
+	fmt.Printf("  Avg edge:   %.1f m\n", stats.AvgEdgeLengthM)
+	fmt.Printf("  Oneway:     %.1f%% of length\n", 100*stats.OnewayLengthShare)
+	fmt.Printf("  BBox:       %.5f,%.5f,%.5f,%.5f\n", stats.BBox.MinLon, stats.BBox.MinLat, stats.BBox.MaxLon, stats.BBox.MaxLat)
+	fmt.Printf("  Centroid:   %.5f, %.5f\n", stats.Centroid.Lat, stats.Centroid.Lon)
+	fmt.Printf("  Memory:     ~%d KB\n", stats.MemoryBytes/1024)
+
+	if len(stats.LengthByClass) > 0 {
+		fmt.Println("  Length by class:")
+		for _, c := range stats.LengthByClass {
+			class := c.Class
+			if class == "" {
+				class = "(none)"
+			}
+			share := 0.0
+			if stats.TotalLengthM > 0 {
+				share = 100 * c.LengthM / stats.TotalLengthM
+			}
+			fmt.Printf("    %-16s %9.2f km  %5.1f%%\n", class, c.LengthM/1000, share)
+		}
+	}
+
+	if len(stats.Degrees) > 0 {
+		fmt.Println("  Degree distribution:")
+		for d, count := range stats.Degrees {
+			if count > 0 {
+				fmt.Printf("    %2d: %d nodes\n", d, count)
+			}
+		}
+	}
+}
+
+// printComponents reports how fragmented the network is. Nodes outside
+// the main component cannot be snapped to and usually point at clipped
+// ways or mapping errors.
+func printComponents(stats engine.GraphStats) {
+	fmt.Println()
+	fmt.Println("=== Connectivity ===")
+	fmt.Printf("  Components: %d\n", len(stats.Components))
+	if len(stats.Components) == 0 {
+		return
+	}
+
+	main := stats.Components[0]
+	fmt.Printf("  Main:       %d nodes (%.1f%%)\n", main, 100*float64(main)/float64(stats.Nodes))
+	fmt.Printf("  Outside:    %d nodes\n", stats.Nodes-main)
+	if stats.Islands > 0 {
+		fmt.Printf("  Flagged:    %d nodes in small components\n", stats.Islands)
+	}
+	if len(stats.Components) == 1 {
+		return
+	}
+
+	buckets := []struct {
+		label    string
+		min, max int
+	}{
+		{"1", 1, 1},
+		{"2-9", 2, 9},
+		{"10-99", 10, 99},
+		{"100-999", 100, 999},
+		{"1000+", 1000, int(^uint(0) >> 1)},
+	}
+	fmt.Println("  Sizes (excluding main):")
+	for _, b := range buckets {
+		count, nodes := 0, 0
+		for _, size := range stats.Components[1:] {
+			if size >= b.min && size <= b.max {
+				count++
+				nodes += size
+			}
+		}
+		if count > 0 {
+			fmt.Printf("    %-8s %d components, %d nodes\n", b.label, count, nodes)
+		}
+	}
+}
+
+// printSources shows how merged extracts fit together. Nothing is printed
+// for a single file or a graph loaded from cache.
+func printSources(report engine.LoadReport) {
+	if len(report.Sources) < 2 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("=== Sources ===")
+	for _, s := range report.Sources {
+		fmt.Printf("  %s: %d nodes, %d in main component\n", s.Path, s.Nodes, s.InMain)
+	}
+	m := report.Merge
+	fmt.Printf("  Shared: %d nodes, %d ways (%d joined, %d conflicting)\n", m.SharedNodes, m.SharedWays, m.JoinedWays, m.ConflictingWays)
+}
+
 func CmdRoute(args []string) error {
 	fs := flag.NewFlagSet("route", flag.ExitOnError)
-	file := fs.String("file", "", "OSM file to parse (.osm or .osm.gz)")
+	var files fileList
+	fs.Var(&files, "file", fileUsage)
 	from := fs.Int64("from", 0, "Source node ID")
 	to := fs.Int64("to", 0, "Target node ID")
-	speed := fs.Float64("speed", mobility.DefaultWalkingSpeedMPS, "Walking speed in m/s (default: 1.4 = 5 km/h)")
+	speed := fs.Float64("speed", 0, "Travel speed in m/s (default: the profile's, 1.4 = 5 km/h for walking)")
+	profileName := fs.String("profile", "walking", profileUsage())
+	profileFile := fs.String("profile-file", "", profileFileUsage)
+	cyclePreference := fs.Float64("cycle-preference", mobility.DefaultInfrastructurePreference, cyclePreferenceUsage)
 	coords := fs.Bool("coords", false, "Include coordinates in output")
+	dem := fs.String("dem", "", "Elevation tiles (.hgt/.tif file or directory)")
+	minComponent := fs.Int("min-component", 0, minComponentUsage)
+	flagIslands := fs.Bool("flag-islands", false, flagIslandsUsage)
+	simplify := fs.Bool("simplify", false, simplifyUsage)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *file == "" {
+	if len(files) == 0 {
 		return fmt.Errorf("--file is required")
 	}
 	if *from == 0 || *to == 0 {
 		return fmt.Errorf("--from and --to are required")
 	}
 
-	e, err := loadEngine(*file)
+	profile, _, err := resolveProfile(*profileName, *profileFile, *speed, *cyclePreference)
+	if err != nil {
+		return err
+	}
+	// The graph is built at the profile's own speed whatever --speed is,
+	// so that a cache does not depend on the run that built it. Routes
+	// scale its durations to --speed instead.
+	built, fingerprint, err := resolveProfile(*profileName, *profileFile, 0, *cyclePreference)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := buildConfig(built, *dem, *minComponent, *flagIslands)
+	if err != nil {
+		return err
+	}
+	cfg.Simplify = *simplify
+
+	e, err := loadEngine(files, cfg, fingerprint)
 	if err != nil {
 		return err
 	}
@@ -143,7 +466,6 @@ func CmdRoute(args []string) error {
 	fmt.Printf("Finding route from %d to %d...\n", *from, *to)
 	start := time.Now()
 
-	profile := mobility.NewWalking(*speed)
 	req := engine.RouteRequest{
 		From:               *from,
 		To:                 *to,
@@ -162,7 +484,18 @@ func CmdRoute(args []string) error {
 	fmt.Println("=== Route Found ===")
 	fmt.Printf("  Nodes:    %d\n", len(res.Nodes))
 	fmt.Printf("  Distance: %.0f m\n", res.Distance)
-	fmt.Printf("  Walk time: %.1f min (at %.1f m/s)\n", res.Duration.Minutes(), *speed)
+	fmt.Printf("  Time:     %.1f min (%s at %.1f m/s)\n", res.Duration.Minutes(), profile.Name(), profile.Speed())
+	if cfg.Elevation != nil {
+		fmt.Printf("  Climb:    +%.0f m / -%.0f m\n", res.ElevationGain, res.ElevationLoss)
+	}
+
+	if len(res.Notes) > 0 {
+		fmt.Println()
+		fmt.Println("=== Check Before Travelling ===")
+		for _, n := range res.Notes {
+			fmt.Printf("  Node %d → %d: %s\n", n.From, n.To, n.Note)
+		}
+	}
 
 	fmt.Println()
 	fmt.Println("=== Timing ===")
@@ -195,112 +528,652 @@ func CmdRoute(args []string) error {
 
 func CmdTransit(args []string) error {
 	fs := flag.NewFlagSet("transit", flag.ExitOnError)
-	gtfsDir := fs.String("gtfs", "", "Directory containing GTFS files (stop_times.txt, trips.txt)")
+	gtfsPath := fs.String("gtfs", "", "GTFS feed: a .zip or a directory of its files (stop_times.txt, trips.txt, and optionally stops.txt, routes.txt, agency.txt, calendar.txt, calendar_dates.txt, frequencies.txt, transfers.txt, fare_attributes.txt, fare_rules.txt)")
 	from := fs.String("from", "", "Source stop ID")
 	to := fs.String("to", "", "Target stop ID")
-	depTime := fs.String("time", "08:00:00", "Departure time (HH:MM:SS)")
+	depTime := fs.String("time", "08:00:00", "Departure time, or arrival time with --arrive-by (HH:MM:SS)")
+	depDate := fs.String("date", "", "Departure date (YYYY-MM-DD), today in the feed's time zone by default")
+	patterns := fs.Bool("patterns", false, "List the trip patterns of each route on the service day")
+	maxTransfers := fs.Int("max-transfers", raptor.DefaultMaxTransfers, "Maximum number of transfers between trips")
+	window := fs.Duration("window", 0, "List the journeys leaving within this long after the departure time, such as 60m")
+	criteria := fs.String("criteria", "", "Also compare journeys by these criteria, comma separated: walking, fare, comfort")
+	bagSize := fs.Int("bag-size", raptor.DefaultBagSize, "Maximum number of journeys compared by --criteria")
+	arriveBy := fs.Bool("arrive-by", false, "Find the latest departures arriving by --time")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if *gtfsDir == "" {
+	if *gtfsPath == "" {
 		return fmt.Errorf("--gtfs is required")
 	}
 	if *from == "" || *to == "" {
 		return fmt.Errorf("--from and --to are required")
 	}
+	if *maxTransfers < 0 {
+		return fmt.Errorf("--max-transfers must not be negative")
+	}
+	if *window < 0 {
+		return fmt.Errorf("--window must not be negative")
+	}
+	dominance, err := parseCriteria(*criteria)
+	if err != nil {
+		return err
+	}
+	if dominance.Criteria != 0 && *window > 0 {
+		return fmt.Errorf("--criteria cannot be combined with --window")
+	}
+	if *bagSize < 1 {
+		return fmt.Errorf("--bag-size must be at least 1")
+	}
+	if *arriveBy && (*window > 0 || dominance.Criteria != 0) {
+		return fmt.Errorf("--arrive-by cannot be combined with --window or --criteria")
+	}
 
-	stopTimesPath := filepath.Join(*gtfsDir, "stop_times.txt")
-	tripsPath := filepath.Join(*gtfsDir, "trips.txt")
-
-	fmt.Printf("Loading GTFS data from %s...\n", *gtfsDir)
+	fmt.Printf("Loading GTFS data from %s...\n", *gtfsPath)
 	start := time.Now()
 
-	stopTimes, err := gtfs.ParseStopTimesFile(stopTimesPath)
+	e := engine.New()
+	if err := e.LoadGTFS(*gtfsPath); err != nil {
+		return err
+	}
+	feed := e.Feed()
+	loadTime := time.Since(start)
+
+	fmt.Printf("  Loaded %d stop times, %d trips, %d frequencies\n", len(feed.StopTimes), len(feed.Trips), len(feed.Frequencies))
+	fmt.Printf("  Loaded %d stops, %d routes, %d agencies\n", len(feed.Stops), len(feed.Routes), len(feed.Agencies))
+	fmt.Printf("  Loaded %d transfers\n", len(feed.Transfers))
+	fmt.Printf("  Loaded %d calendars, %d calendar dates\n", len(feed.Calendars), len(feed.CalendarDates))
+	fmt.Printf("  Loaded %d fares, %d fare rules\n", len(feed.FareAttributes), len(feed.FareRules))
+	fmt.Printf("  Load time: %v\n", loadTime)
+
+	loc := e.TransitLocation()
+	if *depDate == "" {
+		*depDate = time.Now().In(loc).Format(time.DateOnly)
+	}
+	when, err := time.ParseInLocation(time.DateTime, *depDate+" "+*depTime, loc)
 	if err != nil {
-		return fmt.Errorf("parsing stop_times.txt: %w", err)
+		return fmt.Errorf("invalid departure date or time: %w", err)
+	}
+	serviceDay, _ := gtfs.ServiceTime(when, loc)
+
+	timing := "departing at"
+	if *arriveBy {
+		timing = "arriving by"
+	}
+	fmt.Printf("\nSearching transit route from %s to %s %s %s...\n", feed.StopName(gtfs.StopID(*from)), feed.StopName(gtfs.StopID(*to)), timing, when.Format("Mon 2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Service day: %s\n", serviceDay)
+	if *window > 0 {
+		fmt.Printf("  Departure window: %v, until %s\n", *window, when.Add(*window).Format(time.TimeOnly))
+	}
+
+	if *patterns {
+		idx, err := e.TransitIndex(when)
+		if err != nil {
+			return err
+		}
+		printPatterns(feed, idx)
 	}
+	routeStart := time.Now()
 
-	tripRoutes, err := gtfs.ParseTripsFile(tripsPath)
+	req := engine.TransitRouteRequest{
+		FromStop:     *from,
+		ToStop:       *to,
+		Departure:    when,
+		MaxTransfers: *maxTransfers,
+		Window:       *window,
+		Dominance:    dominance,
+		BagSize:      *bagSize,
+		ArriveBy:     *arriveBy,
+	}
+	if *maxTransfers == 0 {
+		req.MaxTransfers = -1 // direct trips only
+	}
+	result, err := e.TransitRoute(req)
 	if err != nil {
-		return fmt.Errorf("parsing trips.txt: %w", err)
+		return err
 	}
+	routeTime := time.Since(routeStart)
 
-	idx := gtfs.BuildIndex(stopTimes, tripRoutes)
-	loadTime := time.Since(start)
+	fmt.Println()
+	fmt.Println("=== RAPTOR Search Complete ===")
+	fmt.Printf("  Search time: %v\n", routeTime)
+	fmt.Printf("  Journeys found: %d\n", len(result.Journeys))
 
-	fmt.Printf("  Loaded %d stop times, %d trips\n", len(stopTimes), len(tripRoutes))
-	fmt.Printf("  Load time: %v\n", loadTime)
+	if len(result.Journeys) == 0 {
+		if *arriveBy {
+			fmt.Printf("\n   Stop %s cannot be reached from %s by %s with up to %d transfers\n", *to, *from, when.Format(time.TimeOnly), *maxTransfers)
+			return nil
+		}
+		fmt.Printf("\n   Stop %s is not reachable from %s with up to %d transfers\n", *to, *from, *maxTransfers)
+		return nil
+	}
+
+	for i, j := range result.Journeys {
+		fmt.Println()
+		fmt.Printf("=== Journey %d: %s ===\n", i+1, transfersLabel(j.Transfers))
+		fmt.Printf("  Departure: %s from %s\n", j.Departure.Format(time.TimeOnly), feed.StopName(gtfs.StopID(*from)))
+		fmt.Printf("  Arrival:   %s at %s\n", j.Arrival.Format(time.TimeOnly), feed.StopName(gtfs.StopID(*to)))
+		// With a window, or an arrival time, riders leave for the journey
+		// they pick.
+		since := when
+		if *window > 0 || *arriveBy {
+			since = j.Departure
+		}
+		travelTime := j.Arrival.Sub(since)
+		fmt.Printf("  Duration:  %d min %d sec\n", int(travelTime.Minutes()), int(travelTime.Seconds())%60)
+		if dominance.Criteria != 0 {
+			fmt.Printf("  Walking:   %d min %d sec\n", int(j.Walking.Minutes()), int(j.Walking.Seconds())%60)
+			fmt.Printf("  Fare:      %.2f\n", j.Fare)
+			fmt.Printf("  Comfort:   %.0f (lower is better)\n", j.Comfort)
+		}
+		for k, leg := range j.Legs {
+			from, to := feed.StopName(gtfs.StopID(leg.FromStop)), feed.StopName(gtfs.StopID(leg.ToStop))
+			times := fmt.Sprintf("%s–%s", leg.Departure.Format("15:04"), leg.Arrival.Format("15:04"))
+			if leg.TripID == "" {
+				fmt.Printf("  %d. %s Transfer: %s → %s\n", k+1, times, from, to)
+			} else {
+				fmt.Printf("  %d. %s %s: %s → %s\n", k+1, times, tripLabel(feed, gtfs.TripID(leg.TripID)), from, to)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseCriteria reads the --criteria of a transit search.
+func parseCriteria(list string) (raptor.Dominance, error) {
+	var d raptor.Dominance
+	if list == "" {
+		return d, nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(name) {
+		case "walking":
+			d.Criteria |= raptor.WalkingTime
+		case "fare":
+			d.Criteria |= raptor.Fare
+		case "comfort":
+			d.Criteria |= raptor.Comfort
+		default:
+			return d, fmt.Errorf("unknown criterion %q: want walking, fare or comfort", name)
+		}
+	}
+	return d, nil
+}
+
+func transfersLabel(n int) string {
+	switch n {
+	case 0:
+		return "direct"
+	case 1:
+		return "1 transfer"
+	}
+	return fmt.Sprintf("%d transfers", n)
+}
+
+func printPatterns(feed *gtfs.Feed, idx *gtfs.StopTimeIndex) {
+	routes := make([]gtfs.RouteID, 0, len(idx.RoutePatterns))
+	for id := range idx.RoutePatterns {
+		routes = append(routes, id)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i] < routes[j] })
 
-	// Parse departure time
-	departure, err := pcTime.ParseTime(*depTime)
+	fmt.Println()
+	fmt.Printf("=== Trip Patterns (%d) ===\n", len(idx.Patterns))
+	for _, id := range routes {
+		name := string(id)
+		if r, ok := feed.Routes[id]; ok {
+			name = fmt.Sprintf("%s (%s)", r.Name(), id)
+		}
+		fmt.Printf("  %s\n", name)
+		for _, n := range idx.RoutePatterns[id] {
+			p := &idx.Patterns[n]
+			first, last := idx.Stops[p.Stops[0]], idx.Stops[p.Stops[len(p.Stops)-1]]
+			fmt.Printf("    %-20s %3d trips  %2d stops  %s → %s\n", p.ID, p.TripCount(), len(p.Stops),
+				feed.StopName(first), feed.StopName(last))
+		}
+	}
+	if idx.SkippedTrips > 0 {
+		fmt.Printf("  Skipped: %d trips with an unknown route or a single stop\n", idx.SkippedTrips)
+	}
+}
+
+// tripLabel names a trip by its route, such as "Bus 42 (trip T7)".
+func tripLabel(feed *gtfs.Feed, trip gtfs.TripID) string {
+	route := feed.Route(trip)
+	if route == nil {
+		return fmt.Sprintf("Trip %s", trip)
+	}
+	mode := route.Type.Mode()
+	if mode != "unknown" {
+		mode = strings.ToUpper(mode[:1]) + strings.ReplaceAll(mode[1:], "_", " ")
+		return fmt.Sprintf("%s %s (trip %s)", mode, route.Name(), trip)
+	}
+	return fmt.Sprintf("%s (trip %s)", route.Name(), trip)
+}
+
+func CmdExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	var files fileList
+	fs.Var(&files, "file", "OSM file to clip (.osm or .osm.gz), repeat to merge neighbouring extracts")
+	graphFile := fs.String("graph", "", "Graph cache to cut instead of an OSM file")
+	bbox := fs.String("bbox", "", "Area as minLon,minLat,maxLon,maxLat")
+	polygon := fs.String("polygon", "", "Area as a GeoJSON file of polygons")
+	out := fs.String("out", "", "Graph cache to write")
+	osmOut := fs.String("osm-out", "", "Also write the clipped OSM data (.osm or .osm.gz), needs --file")
+	dem := fs.String("dem", "", "Elevation tiles (.hgt/.tif file or directory)")
+	minComponent := fs.Int("min-component", 0, minComponentUsage)
+	flagIslands := fs.Bool("flag-islands", false, flagIslandsUsage)
+	simplify := fs.Bool("simplify", false, simplifyUsage)
+	profileName := fs.String("profile", "walking", profileUsage())
+	profileFile := fs.String("profile-file", "", profileFileUsage)
+	cyclePreference := fs.Float64("cycle-preference", mobility.DefaultInfrastructurePreference, cyclePreferenceUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if (len(files) == 0) == (*graphFile == "") {
+		return fmt.Errorf("exactly one of --file or --graph is required")
+	}
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+	if *osmOut != "" && len(files) == 0 {
+		return fmt.Errorf("--osm-out needs --file; a graph cache has no OSM data to write")
+	}
+
+	var region geo.Region
+	switch {
+	case *bbox != "" && *polygon != "":
+		return fmt.Errorf("--bbox and --polygon cannot be combined")
+	case *bbox != "":
+		b, err := geo.ParseBBox(*bbox)
+		if err != nil {
+			return err
+		}
+		region = b
+	case *polygon != "":
+		m, err := geojson.ReadRegion(*polygon)
+		if err != nil {
+			return err
+		}
+		region = m
+	default:
+		return fmt.Errorf("--bbox or --polygon is required")
+	}
+
+	start := time.Now()
+
+	var e *engine.Engine
+	if *graphFile != "" {
+		e = engine.New()
+		fmt.Printf("Loading graph %s...\n", *graphFile)
+		if err := e.LoadGraph(*graphFile); err != nil {
+			return err
+		}
+		before := e.Stats()
+		if err := e.Extract(region); err != nil {
+			return err
+		}
+		after := e.Stats()
+		fmt.Printf("Kept %d of %d nodes and %d of %d edges\n", after.Nodes, before.Nodes, after.Edges, before.Edges)
+	} else {
+		profile, _, err := resolveProfile(*profileName, *profileFile, 0, *cyclePreference)
+		if err != nil {
+			return err
+		}
+		cfg, err := buildConfig(profile, *dem, *minComponent, *flagIslands)
+		if err != nil {
+			return err
+		}
+		cfg.Simplify = *simplify
+		cfg.Region = region
+		e = engine.NewWithConfig(cfg)
+
+		fmt.Printf("Parsing OSM %s...\n", strings.Join(files, ", "))
+		clipped, err := e.ReadOSM(files...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Kept %d nodes and %d ways\n", len(clipped.Nodes), len(clipped.Ways))
+
+		if *osmOut != "" {
+			fmt.Printf("Writing OSM to %s...\n", *osmOut)
+			if err := osm.WriteFile(*osmOut, clipped); err != nil {
+				return err
+			}
+		}
+		e.LoadData(clipped)
+	}
+
+	fmt.Printf("Saving graph to %s...\n", *out)
+	if err := e.SaveGraph(*out); err != nil {
+		return err
+	}
+
+	stats := e.Stats()
+	fmt.Println()
+	fmt.Println("=== Graph Statistics ===")
+	fmt.Printf("  Nodes: %d\n", stats.Nodes)
+	fmt.Printf("  Edges: %d\n", stats.Edges)
+
+	fmt.Println()
+	fmt.Println("=== Timing ===")
+	fmt.Printf("  Extract: %v\n", time.Since(start))
+
+	return nil
+}
+
+func CmdUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	graphFile := fs.String("graph", "", "Graph cache built with parse --updatable")
+	diff := fs.String("diff", "", "OsmChange file to apply (.osc or .osc.gz)")
+	out := fs.String("out", "", "Where to write the updated cache (default: overwrite --graph)")
+	dem := fs.String("dem", "", "Elevation tiles the graph was built with")
+	minComponent := fs.Int("min-component", 0, minComponentUsage)
+	flagIslands := fs.Bool("flag-islands", false, flagIslandsUsage)
+	profileName := fs.String("profile", "walking", profileUsage())
+	profileFile := fs.String("profile-file", "", profileFileUsage)
+	cyclePreference := fs.Float64("cycle-preference", mobility.DefaultInfrastructurePreference, cyclePreferenceUsage)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *graphFile == "" || *diff == "" {
+		return fmt.Errorf("--graph and --diff are required")
+	}
+	if *out == "" {
+		*out = *graphFile
+	}
+
+	profile, _, err := resolveProfile(*profileName, *profileFile, 0, *cyclePreference)
+	if err != nil {
+		return err
+	}
+	cfg, err := buildConfig(profile, *dem, *minComponent, *flagIslands)
+	if err != nil {
+		return err
+	}
+	cfg.KeepSource = true
+
+	start := time.Now()
+	e := engine.NewWithConfig(cfg)
+	fmt.Printf("Loading graph %s...\n", *graphFile)
+	if err := e.LoadGraph(*graphFile); err != nil {
+		return err
+	}
+	if !e.HasSource() {
+		return fmt.Errorf("%s has no source data; build it with parse --updatable", *graphFile)
+	}
+
+	fmt.Printf("Applying %s...\n", *diff)
+	stats, err := e.ApplyChange(*diff)
 	if err != nil {
-		return fmt.Errorf("invalid departure time: %w", err)
+		return err
+	}
+
+	fmt.Printf("Saving graph to %s...\n", *out)
+	if err := e.SaveGraph(*out); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("=== Change ===")
+	fmt.Printf("  Nodes:     %d created, %d modified, %d deleted\n", stats.NodesCreated, stats.NodesModified, stats.NodesDeleted)
+	fmt.Printf("  Ways:      %d created, %d modified, %d deleted\n", stats.WaysCreated, stats.WaysModified, stats.WaysDeleted)
+	fmt.Printf("  Relations: %d created, %d modified, %d deleted\n", stats.RelationsCreated, stats.RelationsModified, stats.RelationsDeleted)
+
+	fmt.Println()
+	fmt.Println("=== Graph ===")
+	fmt.Printf("  Nodes: %d -> %d\n", stats.NodesBefore, stats.NodesAfter)
+	fmt.Printf("  Edges: %d -> %d\n", stats.EdgesBefore, stats.EdgesAfter)
+	if stats.Rebuilt {
+		fmt.Println("  Rebuilt in full: the change touched a pedestrian area")
+	} else {
+		fmt.Printf("  Recomputed: %d edges removed, %d added\n", stats.EdgesRemoved, stats.EdgesAdded)
 	}
 
-	// Load transfers if available
-	transfers := make(map[gtfs.StopID][]raptor.Transfer)
-	transfersPath := filepath.Join(*gtfsDir, "transfers.txt")
-	if gtfsTransfers, err := gtfs.ParseTransfersFile(transfersPath); err == nil {
-		for _, t := range gtfsTransfers {
-			transfers[t.FromStopID] = append(transfers[t.FromStopID], raptor.Transfer{
-				To:       t.ToStopID,
-				Duration: pcTime.Time(t.MinTransferTime),
-			})
+	printComponents(e.Stats())
+
+	fmt.Println()
+	fmt.Println("=== Timing ===")
+	fmt.Printf("  Update: %v\n", time.Since(start))
+
+	return nil
+}
+
+func CmdGraphDiff(args []string) error {
+	fs := flag.NewFlagSet("graph-diff", flag.ExitOnError)
+	out := fs.String("geojson", "", "Write the differences as colour-coded GeoJSON")
+	top := fs.Int("top", 10, "Number of largest edge changes to list")
+	check := fs.Bool("check", false, "Exit with an error when the graphs differ")
+
+	// Allow flags before and after the two cache paths.
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
 		}
-		fmt.Printf("  Loaded %d transfers\n", len(gtfsTransfers))
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 2 {
+		return fmt.Errorf("usage: pathcraft graph-diff [options] BEFORE.cache AFTER.cache")
 	}
 
-	router := raptor.NewRouter(idx, transfers)
+	before, err := graph.LoadGraph(paths[0])
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", paths[0], err)
+	}
+	after, err := graph.LoadGraph(paths[1])
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", paths[1], err)
+	}
 
-	fmt.Printf("\nSearching transit route from %s to %s departing at %s...\n", *from, *to, *depTime)
-	routeStart := time.Now()
+	d := graph.Compare(before, after)
 
-	result := router.Search(gtfs.StopID(*from), departure)
-	routeTime := time.Since(routeStart)
+	fmt.Println("=== Nodes ===")
+	fmt.Printf("  %d -> %d\n", len(before.Nodes), len(after.Nodes))
+	fmt.Printf("  Added: %d, removed: %d, moved: %d\n", len(d.AddedNodes), len(d.RemovedNodes), len(d.MovedNodes))
 
 	fmt.Println()
-	fmt.Println("=== RAPTOR Search Complete ===")
-	fmt.Printf("  Search time: %v\n", routeTime)
-	fmt.Printf("  Stops reached: %d\n", len(result.EarliestArrival))
-
-	targetStop := gtfs.StopID(*to)
-	arrivalTime, reached := result.EarliestArrival[targetStop]
-	if !reached {
-		fmt.Printf("\n   Stop %s is not reachable from %s\n", *to, *from)
-		fmt.Println("\n  Available stops from source:")
-		count := 0
-		for stopID, arr := range result.EarliestArrival {
-			if count >= 10 {
-				fmt.Printf("    ... and %d more\n", len(result.EarliestArrival)-10)
-				break
+	fmt.Println("=== Edges ===")
+	fmt.Printf("  Added: %d, removed: %d, changed: %d\n", len(d.AddedEdges), len(d.RemovedEdges), len(d.ChangedEdges))
+	if len(d.ChangedEdges) > 0 && *top > 0 {
+		changed := append([]graph.EdgeChange(nil), d.ChangedEdges...)
+		sort.SliceStable(changed, func(i, j int) bool {
+			return math.Abs(float64(changed[i].CostDelta())) > math.Abs(float64(changed[j].CostDelta()))
+		})
+		fmt.Println("  Largest cost changes:")
+		for _, c := range changed[:min(*top, len(changed))] {
+			fmt.Printf("    %d -> %d: %+.1f s (%.1f -> %.1f), %+.1f m\n",
+				c.From, c.To, float64(c.CostDelta()), float64(c.Before.Cost), float64(c.After.Cost), c.DistanceDelta())
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("=== Components ===")
+	fmt.Printf("  Components: %d -> %d\n", len(d.ComponentsBefore), len(d.ComponentsAfter))
+	if len(d.ComponentsBefore) > 0 && len(d.ComponentsAfter) > 0 {
+		fmt.Printf("  Main:       %d -> %d nodes\n", d.ComponentsBefore[0], d.ComponentsAfter[0])
+	}
+	fmt.Printf("  Joined main: %d nodes, left main: %d nodes\n", len(d.JoinedMain), len(d.LeftMain))
+
+	if *out != "" {
+		if err := os.WriteFile(*out, geojson.DiffToGeoJSON(before, after, d), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("\nWrote %s\n", *out)
+	}
+
+	if *check && !d.Empty() {
+		return fmt.Errorf("graphs differ")
+	}
+	return nil
+}
+
+func CmdValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	graphFile := fs.String("graph", "", "Graph cache to check")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *graphFile == "" {
+		return fmt.Errorf("--graph is required")
+	}
+
+	g, err := graph.LoadGraph(*graphFile)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", *graphFile, err)
+	}
+	report := g.Validate()
+
+	if *asJSON {
+		b, err := json.MarshalIndent(newValidationJSON(report), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Println("=== Validation ===")
+		fmt.Printf("  Nodes:    %d\n", report.Nodes)
+		fmt.Printf("  Edges:    %d\n", report.Edges)
+		fmt.Printf("  Errors:   %d\n", report.Errors)
+		fmt.Printf("  Warnings: %d\n", report.Warnings)
+		if len(report.Counts) > 0 {
+			fmt.Println()
+			fmt.Println("=== Issues ===")
+			kinds := make([]graph.IssueKind, 0, len(report.Counts))
+			for k := range report.Counts {
+				kinds = append(kinds, k)
+			}
+			sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+			for _, k := range kinds {
+				fmt.Printf("  %-16s %-7s %d\n", k, k.Severity(), report.Counts[k])
 			}
-			fmt.Printf("    %s: %s\n", stopID, arr.String())
-			count++
+			fmt.Println()
+			for _, issue := range report.Issues[:min(20, len(report.Issues))] {
+				fmt.Printf("  %s: %s\n", issue.Severity, issue.Message)
+			}
+			if len(report.Issues) > 20 {
+				fmt.Printf("  ... use --json for the full list\n")
+			}
+		}
+	}
+
+	return report.Err()
+}
+
+// validationJSON is the report printed by validate --json.
+type validationJSON struct {
+	Nodes    int                     `json:"nodes"`
+	Edges    int                     `json:"edges"`
+	Errors   int                     `json:"errors"`
+	Warnings int                     `json:"warnings"`
+	Counts   map[graph.IssueKind]int `json:"counts"`
+	Issues   []issueJSON             `json:"issues"`
+}
+
+type issueJSON struct {
+	Kind     graph.IssueKind `json:"kind"`
+	Severity graph.Severity  `json:"severity"`
+	Node     graph.NodeID    `json:"node"`
+	To       *graph.NodeID   `json:"to,omitempty"`
+	Message  string          `json:"message"`
+}
+
+func newValidationJSON(report graph.ValidationReport) validationJSON {
+	issues := make([]issueJSON, len(report.Issues))
+	for i, issue := range report.Issues {
+		issues[i] = issueJSON(issue)
+	}
+	return validationJSON{
+		Nodes:    report.Nodes,
+		Edges:    report.Edges,
+		Errors:   report.Errors,
+		Warnings: report.Warnings,
+		Counts:   report.Counts,
+		Issues:   issues,
+	}
+}
+
+func CmdProfiles(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pathcraft profiles <list|validate> [options]")
+	}
+
+	switch args[0] {
+	case "list":
+		return cmdProfilesList(args[1:])
+	case "validate":
+		return cmdProfilesValidate(args[1:])
+	default:
+		return fmt.Errorf("unknown profiles command: %s", args[0])
+	}
+}
+
+func cmdProfilesList(args []string) error {
+	fs := flag.NewFlagSet("profiles list", flag.ExitOnError)
+	dir := fs.String("dir", "", "Directory of profile definition files to include")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Println("=== Built-in Profiles ===")
+	for _, name := range mobility.Available() {
+		p, err := mobility.New(name, 0)
+		if err != nil {
+			return err
 		}
+		fmt.Printf("  %-12s %.1f m/s\n", name, p.Speed())
+	}
+
+	if *dir == "" {
 		return nil
 	}
 
+	entries, err := profiles.Discover(*dir)
+	if err != nil {
+		return err
+	}
+
 	fmt.Println()
-	fmt.Println("=== Journey Found ===")
-	fmt.Printf("  Departure: %s from %s\n", departure.String(), *from)
-	fmt.Printf("  Arrival:   %s at %s\n", arrivalTime.String(), *to)
-	travelTime := int(arrivalTime - departure)
-	fmt.Printf("  Duration:  %d min %d sec\n", travelTime/60, travelTime%60)
-
-	// Reconstruct and display path
-	path := result.ReconstructPath(targetStop)
-	if len(path) > 0 {
-		fmt.Println()
-		fmt.Println("=== Journey Steps ===")
-		for i, step := range path {
-			if step.IsTransfer {
-				fmt.Printf("  %d. Transfer: %s → %s\n", i+1, step.FromStop, step.ToStop)
-			} else {
-				fmt.Printf("  %d. Trip %s: %s → %s\n", i+1, step.TripID, step.FromStop, step.ToStop)
+	fmt.Printf("=== Profile Files in %s ===\n", *dir)
+	for _, entry := range entries {
+		if entry.Err != nil {
+			fmt.Printf("  %-12s invalid (run profiles validate)\n", filepath.Base(entry.Path))
+			continue
+		}
+		fmt.Printf("  %-12s %.1f m/s  %s\n", entry.Profile.Name(), entry.Profile.Speed(), entry.Path)
+	}
+	return nil
+}
+
+func cmdProfilesValidate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pathcraft profiles validate FILE...")
+	}
+
+	invalid := 0
+	for _, path := range args {
+		p, err := profiles.Load(path)
+		if err != nil {
+			invalid++
+			fmt.Printf("INVALID %s\n", path)
+			for _, line := range strings.Split(strings.TrimPrefix(err.Error(), path+": "), "\n") {
+				fmt.Printf("  %s\n", line)
 			}
+			continue
 		}
+		fmt.Printf("OK      %s (%s)\n", path, p.Name())
 	}
 
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d profile files invalid", invalid, len(args))
+	}
 	return nil
 }
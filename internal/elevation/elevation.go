@@ -0,0 +1,182 @@
+// Package elevation samples terrain height from local digital elevation
+// models (SRTM .hgt tiles and uncompressed GeoTIFF rasters) so that routing
+// profiles can account for slopes.
+package elevation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported elevation format")
+
+// Source returns the terrain height in metres at a coordinate. The boolean
+// is false when the source has no data there (outside coverage or a void).
+type Source interface {
+	Elevation(lat, lon float64) (float64, bool)
+}
+
+// Grid is a north-up raster in WGS84 degrees. Samples are stored row by row
+// starting at the north-west corner; North and West locate the centre of
+// that first sample.
+type Grid struct {
+	North   float64
+	West    float64
+	LatStep float64 // degrees between rows, positive
+	LonStep float64 // degrees between columns, positive
+	Rows    int
+	Cols    int
+	Data    []float32
+	NoData  float32
+	// HasNoData distinguishes an explicit void value from a genuine zero.
+	HasNoData bool
+}
+
+// Elevation interpolates bilinearly between the four surrounding samples.
+// Voids are skipped and the remaining weights renormalised, so a single
+// missing sample does not punch a hole in the terrain.
+func (g *Grid) Elevation(lat, lon float64) (float64, bool) {
+	row := (g.North - lat) / g.LatStep
+	col := (lon - g.West) / g.LonStep
+	if row < 0 || col < 0 || row > float64(g.Rows-1) || col > float64(g.Cols-1) {
+		return 0, false
+	}
+
+	r0 := int(math.Floor(row))
+	c0 := int(math.Floor(col))
+	r1 := min(r0+1, g.Rows-1)
+	c1 := min(c0+1, g.Cols-1)
+	dr := row - float64(r0)
+	dc := col - float64(c0)
+
+	samples := [4]struct {
+		r, c int
+		w    float64
+	}{
+		{r0, c0, (1 - dr) * (1 - dc)},
+		{r0, c1, (1 - dr) * dc},
+		{r1, c0, dr * (1 - dc)},
+		{r1, c1, dr * dc},
+	}
+
+	var sum, weight float64
+	for _, s := range samples {
+		v := g.Data[s.r*g.Cols+s.c]
+		if g.isVoid(v) || s.w == 0 {
+			continue
+		}
+		sum += float64(v) * s.w
+		weight += s.w
+	}
+	if weight == 0 {
+		return 0, false
+	}
+	return sum / weight, true
+}
+
+func (g *Grid) isVoid(v float32) bool {
+	if math.IsNaN(float64(v)) {
+		return true
+	}
+	return g.HasNoData && v == g.NoData
+}
+
+// Tiles queries several sources in order and returns the first hit.
+type Tiles []Source
+
+func (t Tiles) Elevation(lat, lon float64) (float64, bool) {
+	for _, s := range t {
+		if ele, ok := s.Elevation(lat, lon); ok {
+			return ele, true
+		}
+	}
+	return 0, false
+}
+
+// LoadFile reads a single DEM file, picking the decoder by extension.
+func LoadFile(path string) (*Grid, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hgt":
+		return LoadHGT(path)
+	case ".tif", ".tiff":
+		return LoadGeoTIFF(path)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+}
+
+// LoadDir reads every .hgt, .tif and .tiff file in dir. Files are loaded in
+// name order so overlapping tiles resolve the same way on every run.
+func LoadDir(dir string) (Tiles, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".hgt", ".tif", ".tiff":
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no elevation tiles found in %s", dir)
+	}
+
+	tiles := make(Tiles, 0, len(names))
+	for _, name := range names {
+		grid, err := LoadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", name, err)
+		}
+		tiles = append(tiles, grid)
+	}
+	return tiles, nil
+}
+
+// Open loads a single DEM file or every tile in a directory.
+func Open(path string) (Source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return LoadDir(path)
+	}
+	return LoadFile(path)
+}
+
+// toblerDecay and toblerOptimum are the constants of Tobler's hiking
+// function: speed peaks on a gentle 5% descent and decays exponentially
+// with the distance from that grade.
+const (
+	toblerDecay   = 3.5
+	toblerOptimum = -0.05
+)
+
+// ToblerSpeed scales a flat-ground walking speed by Tobler's hiking function
+// for the given grade (rise over run, positive uphill). The curve is
+// normalised so that a grade of zero returns flatSpeed unchanged.
+func ToblerSpeed(flatSpeed, grade float64) float64 {
+	return flatSpeed * math.Exp(-toblerDecay*(math.Abs(grade-toblerOptimum)+toblerOptimum))
+}
+
+// Climb splits the height difference between two points into ascent and
+// descent, both non-negative.
+func Climb(fromEle, toEle float64) (ascent, descent float64) {
+	if toEle > fromEle {
+		return toEle - fromEle, 0
+	}
+	return 0, fromEle - toEle
+}
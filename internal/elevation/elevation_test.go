@@ -0,0 +1,264 @@
+package elevation_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/elevation"
+)
+
+// hgtTile builds a 3x3 tile: rows run north to south, columns west to east.
+func hgtTile(t *testing.T, samples [9]int16) *elevation.Grid {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, samples); err != nil {
+		t.Fatal(err)
+	}
+	g, err := elevation.ReadHGT(&buf, int64(buf.Len()), -9, -35)
+	if err != nil {
+		t.Fatalf("ReadHGT() error = %v", err)
+	}
+	return g
+}
+
+func TestReadHGT_Interpolation(t *testing.T) {
+	g := hgtTile(t, [9]int16{
+		100, 200, 300,
+		100, 200, 300,
+		0, 0, 0,
+	})
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     float64
+	}{
+		{"north-west corner", -8, -35, 100},
+		{"north-east corner", -8, -34, 300},
+		{"halfway along north edge", -8, -34.75, 150},
+		{"centre", -8.5, -34.5, 200},
+		{"between rows", -8.75, -35, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := g.Elevation(tt.lat, tt.lon)
+			if !ok {
+				t.Fatalf("Elevation(%v, %v) reported no data", tt.lat, tt.lon)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Elevation(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := g.Elevation(-7.5, -34.5); ok {
+		t.Error("expected no data outside the tile")
+	}
+}
+
+func TestReadHGT_VoidsAreSkipped(t *testing.T) {
+	g := hgtTile(t, [9]int16{
+		100, -32768, 0,
+		100, 100, 0,
+		0, 0, 0,
+	})
+
+	got, ok := g.Elevation(-8.25, -34.75)
+	if !ok {
+		t.Fatal("expected interpolation around a single void")
+	}
+	if got != 100 {
+		t.Errorf("Elevation() = %v, want 100", got)
+	}
+
+	if _, ok := g.Elevation(-8, -34.5); ok {
+		t.Error("expected no data exactly on a void sample")
+	}
+}
+
+func TestReadHGT_RejectsNonSquare(t *testing.T) {
+	_, err := elevation.ReadHGT(bytes.NewReader(make([]byte, 10)), 10, 0, 0)
+	if err == nil {
+		t.Error("expected error for a non-square tile")
+	}
+}
+
+func TestParseHGTName(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon int
+		wantErr  bool
+	}{
+		{"N08W035.hgt", 8, -35, false},
+		{"s09e034.hgt", -9, 34, false},
+		{"X08W035.hgt", 0, 0, true},
+		{"N8W35.hgt", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon, err := elevation.ParseHGTName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHGTName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && (lat != tt.lat || lon != tt.lon) {
+				t.Errorf("ParseHGTName(%q) = (%d, %d), want (%d, %d)", tt.name, lat, lon, tt.lat, tt.lon)
+			}
+		})
+	}
+}
+
+// geoTIFF encodes a little-endian, single-strip int16 raster whose pixel
+// corners start at (north, west).
+func geoTIFF(width, height int, north, west, step float64, samples []int16) []byte {
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    []byte
+	}
+	le := binary.LittleEndian
+	short := func(v uint16) []byte { return le.AppendUint16(nil, v) }
+	long := func(v uint32) []byte { return le.AppendUint32(nil, v) }
+	doubles := func(vs ...float64) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = le.AppendUint64(b, math.Float64bits(v))
+		}
+		return b
+	}
+
+	var pixels []byte
+	for _, s := range samples {
+		pixels = le.AppendUint16(pixels, uint16(s))
+	}
+
+	entries := []entry{
+		{256, 3, 1, short(uint16(width))},
+		{257, 3, 1, short(uint16(height))},
+		{258, 3, 1, short(16)},
+		{259, 3, 1, short(1)},
+		{273, 4, 1, nil}, // patched below
+		{277, 3, 1, short(1)},
+		{278, 3, 1, short(uint16(height))},
+		{279, 4, 1, long(uint32(len(pixels)))},
+		{339, 3, 1, short(2)},
+		{33550, 12, 3, doubles(step, step, 0)},
+		{33922, 12, 6, doubles(0, 0, 0, west, north, 0)},
+	}
+
+	ifdSize := 2 + len(entries)*12 + 4
+	extra := 8 + ifdSize
+	var tail []byte
+	offsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		if len(e.value) > 4 {
+			offsets[i] = uint32(extra + len(tail))
+			tail = append(tail, e.value...)
+		}
+	}
+	pixelOffset := uint32(extra + len(tail))
+	entries[4].value = long(pixelOffset)
+
+	out := []byte("II")
+	out = le.AppendUint16(out, 42)
+	out = le.AppendUint32(out, 8)
+	out = le.AppendUint16(out, uint16(len(entries)))
+	for i, e := range entries {
+		out = le.AppendUint16(out, e.tag)
+		out = le.AppendUint16(out, e.typ)
+		out = le.AppendUint32(out, e.count)
+		if len(e.value) > 4 {
+			out = le.AppendUint32(out, offsets[i])
+		} else {
+			v := make([]byte, 4)
+			copy(v, e.value)
+			out = append(out, v...)
+		}
+	}
+	out = le.AppendUint32(out, 0)
+	out = append(out, tail...)
+	return append(out, pixels...)
+}
+
+func TestDecodeGeoTIFF(t *testing.T) {
+	buf := geoTIFF(2, 2, -8, -35, 0.5, []int16{10, 20, 30, 40})
+
+	g, err := elevation.DecodeGeoTIFF(buf)
+	if err != nil {
+		t.Fatalf("DecodeGeoTIFF() error = %v", err)
+	}
+
+	if g.Rows != 2 || g.Cols != 2 {
+		t.Fatalf("grid is %dx%d, want 2x2", g.Rows, g.Cols)
+	}
+
+	// Pixel centres sit half a pixel inside the tiepoint corner.
+	got, ok := g.Elevation(-8.25, -34.75)
+	if !ok || got != 10 {
+		t.Errorf("Elevation(-8.25, -34.75) = %v, %v; want 10, true", got, ok)
+	}
+	got, ok = g.Elevation(-8.5, -34.5)
+	if !ok || got != 25 {
+		t.Errorf("Elevation(-8.5, -34.5) = %v, %v; want 25, true", got, ok)
+	}
+}
+
+func TestDecodeGeoTIFF_RejectsGarbage(t *testing.T) {
+	if _, err := elevation.DecodeGeoTIFF([]byte("not a tiff")); err == nil {
+		t.Error("expected error for non-TIFF input")
+	}
+}
+
+func TestDecodeGeoTIFF_RejectsBadScale(t *testing.T) {
+	for _, step := range []float64{0, -0.5, math.NaN()} {
+		buf := geoTIFF(2, 2, -8, -35, step, []int16{10, 20, 30, 40})
+		if _, err := elevation.DecodeGeoTIFF(buf); !errors.Is(err, elevation.ErrUnsupportedFormat) {
+			t.Errorf("pixel scale %v: error = %v, want ErrUnsupportedFormat", step, err)
+		}
+	}
+}
+
+func TestTiles_FirstHitWins(t *testing.T) {
+	a := hgtTile(t, [9]int16{1, 1, 1, 1, 1, 1, 1, 1, 1})
+	b := hgtTile(t, [9]int16{2, 2, 2, 2, 2, 2, 2, 2, 2})
+	b.West = -34 // shift east so only part overlaps
+
+	tiles := elevation.Tiles{a, b}
+	if got, _ := tiles.Elevation(-8.5, -34.5); got != 1 {
+		t.Errorf("overlap = %v, want 1 from the first tile", got)
+	}
+	if got, _ := tiles.Elevation(-8.5, -33.5); got != 2 {
+		t.Errorf("second tile = %v, want 2", got)
+	}
+}
+
+func TestToblerSpeed(t *testing.T) {
+	const flat = 1.4
+
+	if got := elevation.ToblerSpeed(flat, 0); math.Abs(got-flat) > 1e-9 {
+		t.Errorf("ToblerSpeed(flat, 0) = %v, want %v", got, flat)
+	}
+
+	// Tobler's function peaks on a 5% descent, e^(3.5*0.05) times faster
+	// than on the flat.
+	peak := elevation.ToblerSpeed(flat, -0.05)
+	if want := flat * math.Exp(3.5*0.05); math.Abs(peak-want) > 1e-9 {
+		t.Errorf("peak speed = %v, want %v", peak, want)
+	}
+
+	up := elevation.ToblerSpeed(flat, 0.1)
+	down := elevation.ToblerSpeed(flat, -0.1)
+	if !(up < flat && down > up) {
+		t.Errorf("expected climbing to be slowest: up=%v flat=%v down=%v", up, flat, down)
+	}
+
+	for _, grade := range []float64{-1, -0.3, 0, 0.3, 1} {
+		if s := elevation.ToblerSpeed(flat, grade); s > peak+1e-9 {
+			t.Errorf("ToblerSpeed(grade=%v) = %v exceeds the peak %v", grade, s, peak)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TIFF and GeoTIFF tags needed to locate and decode a single-band DEM.
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagSampleFormat    = 339
+	tagPixelScale      = 33550
+	tagTiepoint        = 33922
+	tagGeoKeyDirectory = 34735
+	tagGDALNoData      = 42113
+
+	geoKeyRasterType = 1025
+	rasterPixelPoint = 2
+
+	sampleFormatInt   = 2
+	sampleFormatFloat = 3
+)
+
+// LoadGeoTIFF reads a single-band, uncompressed GeoTIFF in geographic
+// coordinates. Compressed rasters are rejected rather than decoded; convert
+// them first with `gdal_translate -co COMPRESS=NONE`.
+func LoadGeoTIFF(path string) (*Grid, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeGeoTIFF(buf)
+}
+
+// DecodeGeoTIFF is LoadGeoTIFF for an in-memory file.
+func DecodeGeoTIFF(buf []byte) (*Grid, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("%w: file too short for TIFF", ErrUnsupportedFormat)
+	}
+
+	var order binary.ByteOrder
+	switch string(buf[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: not a TIFF file", ErrUnsupportedFormat)
+	}
+	if order.Uint16(buf[2:]) != 42 {
+		return nil, fmt.Errorf("%w: BigTIFF is not supported", ErrUnsupportedFormat)
+	}
+
+	t := &tiff{buf: buf, order: order, tags: make(map[uint16]tiffEntry)}
+	if err := t.readIFD(int(order.Uint32(buf[4:]))); err != nil {
+		return nil, err
+	}
+
+	return t.grid()
+}
+
+type tiffEntry struct {
+	typ    uint16
+	count  int
+	offset int // start of the value bytes within buf
+}
+
+type tiff struct {
+	buf   []byte
+	order binary.ByteOrder
+	tags  map[uint16]tiffEntry
+}
+
+var tiffTypeSize = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+func (t *tiff) readIFD(offset int) error {
+	if offset+2 > len(t.buf) {
+		return fmt.Errorf("%w: IFD offset out of range", ErrUnsupportedFormat)
+	}
+	n := int(t.order.Uint16(t.buf[offset:]))
+	for i := 0; i < n; i++ {
+		pos := offset + 2 + i*12
+		if pos+12 > len(t.buf) {
+			return fmt.Errorf("%w: truncated IFD", ErrUnsupportedFormat)
+		}
+		tag := t.order.Uint16(t.buf[pos:])
+		typ := t.order.Uint16(t.buf[pos+2:])
+		count := int(t.order.Uint32(t.buf[pos+4:]))
+
+		size, ok := tiffTypeSize[typ]
+		if !ok {
+			continue
+		}
+		valueOffset := pos + 8
+		if size*count > 4 {
+			valueOffset = int(t.order.Uint32(t.buf[pos+8:]))
+		}
+		if valueOffset+size*count > len(t.buf) {
+			return fmt.Errorf("%w: tag %d points outside the file", ErrUnsupportedFormat, tag)
+		}
+		t.tags[tag] = tiffEntry{typ: typ, count: count, offset: valueOffset}
+	}
+	return nil
+}
+
+// values returns a numeric tag as float64s regardless of its storage type.
+func (t *tiff) values(tag uint16) []float64 {
+	e, ok := t.tags[tag]
+	if !ok {
+		return nil
+	}
+	out := make([]float64, e.count)
+	for i := range out {
+		switch e.typ {
+		case 1, 7:
+			out[i] = float64(t.buf[e.offset+i])
+		case 6:
+			out[i] = float64(int8(t.buf[e.offset+i]))
+		case 3:
+			out[i] = float64(t.order.Uint16(t.buf[e.offset+2*i:]))
+		case 8:
+			out[i] = float64(int16(t.order.Uint16(t.buf[e.offset+2*i:])))
+		case 4:
+			out[i] = float64(t.order.Uint32(t.buf[e.offset+4*i:]))
+		case 9:
+			out[i] = float64(int32(t.order.Uint32(t.buf[e.offset+4*i:])))
+		case 11:
+			out[i] = float64(math.Float32frombits(t.order.Uint32(t.buf[e.offset+4*i:])))
+		case 12:
+			out[i] = math.Float64frombits(t.order.Uint64(t.buf[e.offset+8*i:]))
+		case 5, 10:
+			num := float64(t.order.Uint32(t.buf[e.offset+8*i:]))
+			den := float64(t.order.Uint32(t.buf[e.offset+8*i+4:]))
+			if den != 0 {
+				out[i] = num / den
+			}
+		}
+	}
+	return out
+}
+
+func (t *tiff) value(tag uint16, fallback float64) float64 {
+	v := t.values(tag)
+	if len(v) == 0 {
+		return fallback
+	}
+	return v[0]
+}
+
+func (t *tiff) ascii(tag uint16) string {
+	e, ok := t.tags[tag]
+	if !ok || e.typ != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(t.buf[e.offset:e.offset+e.count]), "\x00 ")
+}
+
+func (t *tiff) grid() (*Grid, error) {
+	width := int(t.value(tagImageWidth, 0))
+	height := int(t.value(tagImageLength, 0))
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("%w: missing image dimensions", ErrUnsupportedFormat)
+	}
+	if c := t.value(tagCompression, 1); c != 1 {
+		return nil, fmt.Errorf("%w: compression %v (only uncompressed GeoTIFF is read)", ErrUnsupportedFormat, c)
+	}
+	if spp := t.value(tagSamplesPerPixel, 1); spp != 1 {
+		return nil, fmt.Errorf("%w: %v samples per pixel (expected a single band)", ErrUnsupportedFormat, spp)
+	}
+
+	decode, bytesPerSample, err := t.sampleDecoder()
+	if err != nil {
+		return nil, err
+	}
+
+	scale := t.values(tagPixelScale)
+	tie := t.values(tagTiepoint)
+	if len(scale) < 2 || len(tie) < 6 {
+		return nil, fmt.Errorf("%w: missing ModelPixelScale or ModelTiepoint", ErrUnsupportedFormat)
+	}
+	if !(scale[0] > 0 && scale[1] > 0) {
+		// Also catches NaN, which would make every lookup index garbage.
+		return nil, fmt.Errorf("%w: ModelPixelScale %v, %v is not positive", ErrUnsupportedFormat, scale[0], scale[1])
+	}
+
+	data := make([]float32, width*height)
+	if err := t.readBlocks(data, width, height, bytesPerSample, decode); err != nil {
+		return nil, err
+	}
+
+	// Tiepoints refer to the pixel corner unless the raster declares
+	// PixelIsPoint, while Grid stores sample centres.
+	west := tie[3] - tie[0]*scale[0]
+	north := tie[4] + tie[1]*scale[1]
+	if t.rasterType() != rasterPixelPoint {
+		west += scale[0] / 2
+		north -= scale[1] / 2
+	}
+
+	g := &Grid{
+		North:   north,
+		West:    west,
+		LatStep: scale[1],
+		LonStep: scale[0],
+		Rows:    height,
+		Cols:    width,
+		Data:    data,
+	}
+	if nd := t.ascii(tagGDALNoData); nd != "" {
+		if v, err := strconv.ParseFloat(nd, 64); err == nil {
+			g.NoData = float32(v)
+			g.HasNoData = true
+		}
+	}
+	return g, nil
+}
+
+func (t *tiff) rasterType() int {
+	keys := t.values(tagGeoKeyDirectory)
+	for i := 4; i+3 < len(keys); i += 4 {
+		if int(keys[i]) == geoKeyRasterType && keys[i+1] == 0 {
+			return int(keys[i+3])
+		}
+	}
+	return 0
+}
+
+func (t *tiff) sampleDecoder() (func([]byte) float32, int, error) {
+	bits := int(t.value(tagBitsPerSample, 1))
+	format := int(t.value(tagSampleFormat, 1))
+	o := t.order
+
+	switch {
+	case bits == 8 && format == sampleFormatInt:
+		return func(b []byte) float32 { return float32(int8(b[0])) }, 1, nil
+	case bits == 8:
+		return func(b []byte) float32 { return float32(b[0]) }, 1, nil
+	case bits == 16 && format == sampleFormatInt:
+		return func(b []byte) float32 { return float32(int16(o.Uint16(b))) }, 2, nil
+	case bits == 16:
+		return func(b []byte) float32 { return float32(o.Uint16(b)) }, 2, nil
+	case bits == 32 && format == sampleFormatFloat:
+		return func(b []byte) float32 { return math.Float32frombits(o.Uint32(b)) }, 4, nil
+	case bits == 32 && format == sampleFormatInt:
+		return func(b []byte) float32 { return float32(int32(o.Uint32(b))) }, 4, nil
+	case bits == 32:
+		return func(b []byte) float32 { return float32(o.Uint32(b)) }, 4, nil
+	case bits == 64 && format == sampleFormatFloat:
+		return func(b []byte) float32 { return float32(math.Float64frombits(o.Uint64(b))) }, 8, nil
+	}
+	return nil, 0, fmt.Errorf("%w: %d-bit samples of format %d", ErrUnsupportedFormat, bits, format)
+}
+
+// readBlocks copies strips or tiles into data. Strips are treated as tiles
+// spanning the full image width so both layouts share one loop.
+func (t *tiff) readBlocks(data []float32, width, height, bytesPerSample int, decode func([]byte) float32) error {
+	blockW, blockH := width, int(t.value(tagRowsPerStrip, float64(height)))
+	offsets := t.values(tagStripOffsets)
+	if _, tiled := t.tags[tagTileOffsets]; tiled {
+		blockW = int(t.value(tagTileWidth, 0))
+		blockH = int(t.value(tagTileLength, 0))
+		offsets = t.values(tagTileOffsets)
+	}
+	if blockW <= 0 || blockH <= 0 || len(offsets) == 0 {
+		return fmt.Errorf("%w: missing strip or tile layout", ErrUnsupportedFormat)
+	}
+	blockH = min(blockH, height)
+
+	across := (width + blockW - 1) / blockW
+	for i, off := range offsets {
+		bx := (i % across) * blockW
+		by := (i / across) * blockH
+		for y := 0; y < blockH && by+y < height; y++ {
+			for x := 0; x < blockW && bx+x < width; x++ {
+				pos := int(off) + (y*blockW+x)*bytesPerSample
+				if pos+bytesPerSample > len(t.buf) {
+					return fmt.Errorf("%w: raster data truncated", ErrUnsupportedFormat)
+				}
+				data[(by+y)*width+bx+x] = decode(t.buf[pos:])
+			}
+		}
+	}
+	return nil
+}
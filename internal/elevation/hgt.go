@@ -0,0 +1,107 @@
+package elevation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hgtVoid marks missing samples in SRTM data.
+const hgtVoid = -32768
+
+// LoadHGT reads an SRTM tile. The tile's south-west corner comes from the
+// file name (e.g. N08W035.hgt), as .hgt files carry no georeferencing.
+func LoadHGT(path string) (*Grid, error) {
+	lat, lon, err := ParseHGTName(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadHGT(f, info.Size(), lat, lon)
+}
+
+// ReadHGT decodes size bytes of big-endian 16-bit samples for the one-degree
+// tile whose south-west corner is at (lat, lon). Both 1" (3601x3601) and
+// 3" (1201x1201) tiles are accepted; the resolution follows from the size.
+func ReadHGT(r io.Reader, size int64, lat, lon int) (*Grid, error) {
+	samples := size / 2
+	side := int(math.Sqrt(float64(samples)))
+	if side < 2 || int64(side*side)*2 != size {
+		return nil, fmt.Errorf("%w: hgt size %d is not a square tile", ErrUnsupportedFormat, size)
+	}
+
+	raw := make([]int16, side*side)
+	if err := binary.Read(r, binary.BigEndian, raw); err != nil {
+		return nil, fmt.Errorf("reading hgt samples: %w", err)
+	}
+
+	data := make([]float32, len(raw))
+	for i, v := range raw {
+		data[i] = float32(v)
+	}
+
+	step := 1.0 / float64(side-1)
+	return &Grid{
+		North:     float64(lat + 1),
+		West:      float64(lon),
+		LatStep:   step,
+		LonStep:   step,
+		Rows:      side,
+		Cols:      side,
+		Data:      data,
+		NoData:    hgtVoid,
+		HasNoData: true,
+	}, nil
+}
+
+// ParseHGTName extracts the south-west corner from an SRTM tile name such
+// as "N08W035.hgt" or "s09e034.hgt".
+func ParseHGTName(name string) (lat, lon int, err error) {
+	base := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if len(base) != 7 {
+		return 0, 0, fmt.Errorf("invalid hgt tile name: %q", name)
+	}
+
+	lat, err = strconv.Atoi(base[1:3])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hgt latitude in %q: %w", name, err)
+	}
+	lon, err = strconv.Atoi(base[4:7])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hgt longitude in %q: %w", name, err)
+	}
+
+	switch base[0] {
+	case 'N':
+	case 'S':
+		lat = -lat
+	default:
+		return 0, 0, fmt.Errorf("invalid hgt tile name: %q", name)
+	}
+
+	switch base[3] {
+	case 'E':
+	case 'W':
+		lon = -lon
+	default:
+		return 0, 0, fmt.Errorf("invalid hgt tile name: %q", name)
+	}
+
+	return lat, lon, nil
+}
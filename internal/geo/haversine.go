@@ -30,3 +30,29 @@ func HaversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 
 	return EarthRadiusMeters * c
 }
+
+// Bearing returns the initial compass heading in degrees, clockwise from
+// north, of the great circle from the first point to the second.
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * DegreesToRadians
+	lat2Rad := lat2 * DegreesToRadians
+	deltaLon := (lon2 - lon1) * DegreesToRadians
+
+	y := math.Sin(deltaLon) * math.Cos(lat2Rad)
+	x := math.Cos(lat1Rad)*math.Sin(lat2Rad) -
+		math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(deltaLon)
+
+	return math.Mod(math.Atan2(y, x)/DegreesToRadians+360, 360)
+}
+
+// Deflection returns the change of heading in degrees when travelling
+// a -> b -> c, in (-180, 180] and positive for right turns.
+func Deflection(a, b, c graph.Node) float64 {
+	in := Bearing(a.Lat, a.Lon, b.Lat, b.Lon)
+	out := Bearing(b.Lat, b.Lon, c.Lat, c.Lon)
+	d := math.Mod(out-in+540, 360) - 180
+	if d == -180 {
+		d = 180
+	}
+	return d
+}
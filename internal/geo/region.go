@@ -0,0 +1,184 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Point is a coordinate in degrees.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Region is an area that extracts are cut to. Coordinates are treated as
+// planar, which is accurate enough for city-sized areas.
+type Region interface {
+	Contains(p Point) bool
+	// Crossings returns, in increasing order, the fractions along the
+	// segment from a to b at which it crosses the region's boundary.
+	Crossings(a, b Point) []float64
+}
+
+// BBox is a rectangle aligned with the meridians and parallels.
+type BBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// ParseBBox reads "minLon,minLat,maxLon,maxLat", the order used by
+// GeoJSON and most OSM tools.
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, fmt.Errorf("bbox %q: want minLon,minLat,maxLon,maxLat", s)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return BBox{}, fmt.Errorf("bbox %q: %w", s, err)
+		}
+		v[i] = f
+	}
+	b := BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLat >= b.MaxLat || b.MinLon >= b.MaxLon {
+		return BBox{}, fmt.Errorf("bbox %q: minimum must be below maximum", s)
+	}
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 {
+		return BBox{}, fmt.Errorf("bbox %q: coordinates out of range", s)
+	}
+	return b, nil
+}
+
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+func (b BBox) Crossings(a, c Point) []float64 {
+	return b.polygon().Crossings(a, c)
+}
+
+func (b BBox) polygon() Polygon {
+	return Polygon{Outer: []Point{
+		{b.MinLat, b.MinLon},
+		{b.MinLat, b.MaxLon},
+		{b.MaxLat, b.MaxLon},
+		{b.MaxLat, b.MinLon},
+		{b.MinLat, b.MinLon},
+	}}
+}
+
+// Polygon is an outer ring with optional holes. Rings are closed: the
+// last point repeats the first.
+type Polygon struct {
+	Outer []Point
+	Holes [][]Point
+}
+
+func (p Polygon) Contains(pt Point) bool {
+	if !ringContains(p.Outer, pt) {
+		return false
+	}
+	for _, h := range p.Holes {
+		if ringContains(h, pt) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Polygon) Crossings(a, b Point) []float64 {
+	ts := ringCrossings(p.Outer, a, b, nil)
+	for _, h := range p.Holes {
+		ts = ringCrossings(h, a, b, ts)
+	}
+	return sortedUnique(ts)
+}
+
+// MultiPolygon is the union of its polygons.
+type MultiPolygon []Polygon
+
+func (m MultiPolygon) Contains(pt Point) bool {
+	for _, p := range m {
+		if p.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MultiPolygon) Crossings(a, b Point) []float64 {
+	var ts []float64
+	for _, p := range m {
+		ts = append(ts, p.Crossings(a, b)...)
+	}
+	return sortedUnique(ts)
+}
+
+// ringContains is the even-odd rule; points on the boundary count as
+// inside.
+func ringContains(ring []Point, pt Point) bool {
+	inside := false
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		if onBoundary(pt, a, b) {
+			return true
+		}
+		if (a.Lat > pt.Lat) != (b.Lat > pt.Lat) {
+			lon := a.Lon + (pt.Lat-a.Lat)/(b.Lat-a.Lat)*(b.Lon-a.Lon)
+			if pt.Lon < lon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func onBoundary(pt, a, b Point) bool {
+	cross := (b.Lon-a.Lon)*(pt.Lat-a.Lat) - (b.Lat-a.Lat)*(pt.Lon-a.Lon)
+	if math.Abs(cross) > 1e-12 {
+		return false
+	}
+	return pt.Lat >= math.Min(a.Lat, b.Lat) && pt.Lat <= math.Max(a.Lat, b.Lat) &&
+		pt.Lon >= math.Min(a.Lon, b.Lon) && pt.Lon <= math.Max(a.Lon, b.Lon)
+}
+
+// ringCrossings appends the fractions along a-b, strictly between the
+// ends, where it meets an edge of ring.
+func ringCrossings(ring []Point, a, b Point, ts []float64) []float64 {
+	dLat, dLon := b.Lat-a.Lat, b.Lon-a.Lon
+	for i := 0; i+1 < len(ring); i++ {
+		c, d := ring[i], ring[i+1]
+		eLat, eLon := d.Lat-c.Lat, d.Lon-c.Lon
+		denom := dLon*eLat - dLat*eLon
+		if denom == 0 {
+			continue // parallel; touching along an edge is not a crossing
+		}
+		t := ((c.Lon-a.Lon)*eLat - (c.Lat-a.Lat)*eLon) / denom
+		u := ((c.Lon-a.Lon)*dLat - (c.Lat-a.Lat)*dLon) / denom
+		if t > 0 && t < 1 && u >= 0 && u <= 1 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func sortedUnique(ts []float64) []float64 {
+	sort.Float64s(ts)
+	out := ts[:0]
+	for _, t := range ts {
+		if len(out) == 0 || t-out[len(out)-1] > 1e-12 {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Lerp returns the point a fraction t of the way from a to b.
+func Lerp(a, b Point, t float64) Point {
+	return Point{Lat: a.Lat + t*(b.Lat-a.Lat), Lon: a.Lon + t*(b.Lon-a.Lon)}
+}
@@ -20,14 +20,15 @@ type FeatureCollection struct {
 func GraphToGeoJSON(g *graph.Graph) []byte {
 	var features []Feature
 	for from, edges := range g.Edges {
-		fromNode := g.Nodes[from]
 		for _, e := range edges {
-			toNode := g.Nodes[e.To]
+			if !drawable(g, from, e) {
+				continue
+			}
 			features = append(features, Feature{
 				Type: "Feature",
 				Geometry: map[string]any{
 					"type":        "LineString",
-					"coordinates": [][]float64{{fromNode.Lon, fromNode.Lat}, {toNode.Lon, toNode.Lat}},
+					"coordinates": edgeCoordinates(g, from, e),
 				},
 			})
 		}
@@ -49,8 +50,10 @@ func WriteGraphToGeoJSON(g *graph.Graph, w io.Writer) error {
 
 	first := true
 	for from, edges := range g.Edges {
-		fromNode := g.Nodes[from]
 		for _, e := range edges {
+			if !drawable(g, from, e) {
+				continue
+			}
 			if !first {
 				if _, err := w.Write([]byte(`,`)); err != nil {
 					return err
@@ -58,12 +61,11 @@ func WriteGraphToGeoJSON(g *graph.Graph, w io.Writer) error {
 			}
 			first = false
 
-			toNode := g.Nodes[e.To]
 			feature := Feature{
 				Type: "Feature",
 				Geometry: map[string]any{
 					"type":        "LineString",
-					"coordinates": [][]float64{{fromNode.Lon, fromNode.Lat}, {toNode.Lon, toNode.Lat}},
+					"coordinates": edgeCoordinates(g, from, e),
 				},
 			}
 			b, err := json.Marshal(feature)
@@ -81,10 +83,50 @@ func WriteGraphToGeoJSON(g *graph.Graph, w io.Writer) error {
 }
 
 func PathToGeoJSON(g *graph.Graph, path []graph.NodeID) []byte {
+	return RouteToGeoJSON(g, path, map[string]any{"route": true})
+}
+
+// drawable reports whether both ends of e exist. Edges of a damaged graph
+// (see graph.Validate) may point at missing nodes, which would otherwise
+// be drawn at (0, 0).
+func drawable(g *graph.Graph, from graph.NodeID, e graph.Edge) bool {
+	return g.HasNode(from) && g.HasNode(e.To)
+}
+
+// edgeCoordinates returns the line of e from its start node, through any
+// shape points kept by graph simplification, to its end node.
+func edgeCoordinates(g *graph.Graph, from graph.NodeID, e graph.Edge) [][]float64 {
+	start, end := g.Nodes[from], g.Nodes[e.To]
+	coords := make([][]float64, 0, len(e.Geometry)+2)
+	coords = append(coords, []float64{start.Lon, start.Lat})
+	for _, p := range e.Geometry {
+		coords = append(coords, []float64{p.Lon, p.Lat})
+	}
+	return append(coords, []float64{end.Lon, end.Lat})
+}
+
+// RouteToGeoJSON renders a path as a single LineString feature carrying
+// the given properties, e.g. distance or an elevation chart. The path may
+// list graph nodes only or include the shape points of simplified edges;
+// either way the full geometry is drawn.
+func RouteToGeoJSON(g *graph.Graph, path []graph.NodeID, properties map[string]any) []byte {
 	var coords [][]float64
+	prev, hasPrev := graph.NodeID(0), false
 	for _, id := range path {
-		n := g.Nodes[id]
+		n, ok := g.Nodes[id]
+		if !ok {
+			// A shape point, drawn from the edge geometry below.
+			continue
+		}
+		if hasPrev {
+			if e, ok := g.EdgeBetween(prev, id); ok {
+				for _, p := range e.Geometry {
+					coords = append(coords, []float64{p.Lon, p.Lat})
+				}
+			}
+		}
 		coords = append(coords, []float64{n.Lon, n.Lat})
+		prev, hasPrev = id, true
 	}
 
 	fc := FeatureCollection{
@@ -96,7 +138,7 @@ func PathToGeoJSON(g *graph.Graph, path []graph.NodeID) []byte {
 					"type":        "LineString",
 					"coordinates": coords,
 				},
-				Properties: map[string]any{"route": true},
+				Properties: properties,
 			},
 		},
 	}
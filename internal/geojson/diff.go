@@ -0,0 +1,88 @@
+package geojson
+
+import (
+	"encoding/json"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+// Colours of DiffToGeoJSON features, set as the simplestyle "stroke" and
+// "marker-color" properties most map viewers understand.
+const (
+	AddedColor   = "#2a9d8f"
+	RemovedColor = "#e63946"
+	ChangedColor = "#f4a261"
+	IslandColor  = "#7b2cbf"
+)
+
+// DiffToGeoJSON draws the differences between two graphs: added, removed
+// and changed edges as colour-coded lines, and nodes that moved or left
+// the main component as points. Each feature has a "change" property
+// naming what happened to it.
+func DiffToGeoJSON(before, after *graph.Graph, d graph.Diff) []byte {
+	var features []Feature
+
+	line := func(g *graph.Graph, key graph.EdgeKey, change, color string, props map[string]any) {
+		e, ok := g.EdgeBetween(key.From, key.To)
+		if !ok {
+			return
+		}
+		if props == nil {
+			props = map[string]any{}
+		}
+		props["change"] = change
+		props["stroke"] = color
+		props["from"] = key.From
+		props["to"] = key.To
+		features = append(features, Feature{
+			Type: "Feature",
+			Geometry: map[string]any{
+				"type":        "LineString",
+				"coordinates": edgeCoordinates(g, key.From, e),
+			},
+			Properties: props,
+		})
+	}
+	point := func(n graph.Node, change, color string) {
+		features = append(features, Feature{
+			Type: "Feature",
+			Geometry: map[string]any{
+				"type":        "Point",
+				"coordinates": []float64{n.Lon, n.Lat},
+			},
+			Properties: map[string]any{"change": change, "marker-color": color, "id": n.ID},
+		})
+	}
+
+	for _, key := range d.AddedEdges {
+		line(after, key, "added", AddedColor, nil)
+	}
+	for _, key := range d.RemovedEdges {
+		line(before, key, "removed", RemovedColor, nil)
+	}
+	for _, c := range d.ChangedEdges {
+		line(after, c.EdgeKey, "changed", ChangedColor, map[string]any{
+			"distance_before": c.Before.DistanceM,
+			"distance_after":  c.After.DistanceM,
+			"cost_before":     float64(c.Before.Cost),
+			"cost_after":      float64(c.After.Cost),
+		})
+	}
+	for _, id := range d.MovedNodes {
+		point(after.Nodes[id], "moved", ChangedColor)
+	}
+	for _, id := range d.LeftMain {
+		point(after.Nodes[id], "left_main", IslandColor)
+	}
+
+	fc := FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+	if fc.Features == nil {
+		fc.Features = []Feature{}
+	}
+
+	b, _ := json.Marshal(fc)
+	return b
+}
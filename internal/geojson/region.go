@@ -0,0 +1,121 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/danielscoffee/pathcraft/internal/geo"
+)
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geometry       `json:"geometry"`
+	Features    []geometry      `json:"features"`
+}
+
+// ParseRegion reads the area covered by a GeoJSON Polygon or
+// MultiPolygon, or by every polygon in a Feature or FeatureCollection.
+func ParseRegion(data []byte) (geo.MultiPolygon, error) {
+	var g geometry
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("decoding GeoJSON: %w", err)
+	}
+	m, err := g.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("GeoJSON contains no polygon")
+	}
+	return m, nil
+}
+
+// ReadRegion is ParseRegion on the contents of a file.
+func ReadRegion(path string) (geo.MultiPolygon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseRegion(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (g geometry) polygons() (geo.MultiPolygon, error) {
+	switch g.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("Polygon coordinates: %w", err)
+		}
+		p, err := polygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return geo.MultiPolygon{p}, nil
+	case "MultiPolygon":
+		var polys [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+			return nil, fmt.Errorf("MultiPolygon coordinates: %w", err)
+		}
+		var m geo.MultiPolygon
+		for _, rings := range polys {
+			p, err := polygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p)
+		}
+		return m, nil
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, nil
+		}
+		return g.Geometry.polygons()
+	case "FeatureCollection":
+		var m geo.MultiPolygon
+		for _, f := range g.Features {
+			ps, err := f.polygons()
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, ps...)
+		}
+		return m, nil
+	default:
+		// Points and lines cover no area.
+		return nil, nil
+	}
+}
+
+func polygon(rings [][][]float64) (geo.Polygon, error) {
+	if len(rings) == 0 {
+		return geo.Polygon{}, fmt.Errorf("polygon has no rings")
+	}
+	var p geo.Polygon
+	for i, coords := range rings {
+		if len(coords) < 4 {
+			return geo.Polygon{}, fmt.Errorf("ring %d has %d positions, want at least 4", i, len(coords))
+		}
+		ring := make([]geo.Point, len(coords))
+		for j, c := range coords {
+			if len(c) < 2 {
+				return geo.Polygon{}, fmt.Errorf("ring %d position %d: want [lon, lat]", i, j)
+			}
+			ring[j] = geo.Point{Lat: c[1], Lon: c[0]}
+		}
+		if ring[0] != ring[len(ring)-1] {
+			ring = append(ring, ring[0])
+		}
+		if i == 0 {
+			p.Outer = ring
+		} else {
+			p.Holes = append(p.Holes, ring)
+		}
+	}
+	return p, nil
+}
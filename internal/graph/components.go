@@ -0,0 +1,165 @@
+package graph
+
+import "sort"
+
+// Components partitions a graph into strongly connected components: sets
+// of nodes that can all reach each other. Component 0 is the largest, the
+// main network that routes are expected to start and end in.
+type Components struct {
+	Of    map[NodeID]int
+	Sizes []int // nodes per component, largest first
+}
+
+// Count returns the number of components.
+func (c Components) Count() int {
+	return len(c.Sizes)
+}
+
+// InMain reports whether id belongs to the largest component.
+func (c Components) InMain(id NodeID) bool {
+	comp, ok := c.Of[id]
+	return ok && comp == 0
+}
+
+// Below returns the nodes of every component with fewer than minSize
+// nodes. The main component is never included, however small.
+func (c Components) Below(minSize int) []NodeID {
+	var ids []NodeID
+	for id, comp := range c.Of {
+		if comp != 0 && c.Sizes[comp] < minSize {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// StronglyConnectedComponents runs Tarjan's algorithm without recursion,
+// so that long chains of shape points cannot overflow the stack. Results
+// are deterministic: ties in size are ordered by smallest node ID.
+func (g *Graph) StronglyConnectedComponents() Components {
+	ids := make([]NodeID, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	index := make(map[NodeID]int, len(ids))
+	low := make(map[NodeID]int, len(ids))
+	onStack := make(map[NodeID]bool)
+	var stack []NodeID
+	var groups [][]NodeID
+
+	type frame struct {
+		node NodeID
+		next int // index of the next edge to explore
+	}
+
+	counter := 0
+	visit := func(id NodeID) {
+		index[id] = counter
+		low[id] = counter
+		counter++
+		stack = append(stack, id)
+		onStack[id] = true
+	}
+
+	for _, root := range ids {
+		if _, seen := index[root]; seen {
+			continue
+		}
+
+		visit(root)
+		calls := []frame{{node: root}}
+		for len(calls) > 0 {
+			f := &calls[len(calls)-1]
+			v := f.node
+			edges := g.Edges[v]
+
+			if f.next < len(edges) {
+				w := edges[f.next].To
+				f.next++
+				if !g.HasNode(w) {
+					continue
+				}
+				if _, seen := index[w]; !seen {
+					visit(w)
+					calls = append(calls, frame{node: w})
+				} else if onStack[w] {
+					low[v] = min(low[v], index[w])
+				}
+				continue
+			}
+
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].node
+				low[parent] = min(low[parent], low[v])
+			}
+
+			if low[v] == index[v] {
+				var group []NodeID
+				for {
+					top := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[top] = false
+					group = append(group, top)
+					if top == v {
+						break
+					}
+				}
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return group[i] < group[j] })
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) > len(groups[j])
+		}
+		return groups[i][0] < groups[j][0]
+	})
+
+	c := Components{
+		Of:    make(map[NodeID]int, len(ids)),
+		Sizes: make([]int, len(groups)),
+	}
+	for comp, group := range groups {
+		c.Sizes[comp] = len(group)
+		for _, id := range group {
+			c.Of[id] = comp
+		}
+	}
+	return c
+}
+
+// RemoveNodes deletes the given nodes together with every edge leaving
+// or entering them.
+func (g *Graph) RemoveNodes(ids []NodeID) {
+	if len(ids) == 0 {
+		return
+	}
+	removed := make(map[NodeID]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+		delete(g.Nodes, id)
+		delete(g.Edges, id)
+	}
+
+	for from, edges := range g.Edges {
+		kept := edges[:0]
+		for _, e := range edges {
+			if !removed[e.To] {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			delete(g.Edges, from)
+		} else {
+			g.Edges[from] = kept
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package graph_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := graph.NewGraph()
+	for i := 1; i <= 8; i++ {
+		g.AddNode(graph.NodeID(i), 0, 0)
+	}
+
+	// Main network: a two-way triangle 1-2-3 plus 4 on a two-way spur.
+	g.AddBidirectionalEdge(1, 2, 1)
+	g.AddBidirectionalEdge(2, 3, 1)
+	g.AddBidirectionalEdge(3, 1, 1)
+	g.AddBidirectionalEdge(3, 4, 1)
+	// A oneway leading out of the network can be entered but not left.
+	g.AddEdge(4, 5, 1)
+	// A detached courtyard.
+	g.AddBidirectionalEdge(6, 7, 1)
+	// 8 is isolated.
+
+	c := g.StronglyConnectedComponents()
+
+	if want := []int{4, 2, 1, 1}; !reflect.DeepEqual(c.Sizes, want) {
+		t.Fatalf("Sizes = %v, want %v", c.Sizes, want)
+	}
+	for _, id := range []graph.NodeID{1, 2, 3, 4} {
+		if !c.InMain(id) {
+			t.Errorf("node %d should be in the main component", id)
+		}
+	}
+	if c.InMain(5) || c.InMain(6) {
+		t.Error("nodes 5 and 6 should be outside the main component")
+	}
+	if c.Of[6] != c.Of[7] {
+		t.Error("nodes 6 and 7 should share a component")
+	}
+
+	if got, want := c.Below(2), []graph.NodeID{5, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Below(2) = %v, want %v", got, want)
+	}
+
+	g.RemoveNodes(c.Below(3))
+	if g.HasNode(5) || g.HasNode(7) {
+		t.Error("small components should be removed")
+	}
+	for _, e := range g.Neighbors(4) {
+		if e.To == 5 {
+			t.Error("edge into a removed node survived")
+		}
+	}
+}
+
+func TestNearestNodeWhere(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddNode(1, 0, 0)
+	g.AddNode(2, 0, 1)
+	dist := func(lat1, lon1, lat2, lon2 float64) float64 {
+		return (lat1-lat2)*(lat1-lat2) + (lon1-lon2)*(lon1-lon2)
+	}
+
+	if id, _ := g.NearestNodeWhere(0, 0.1, dist, func(id graph.NodeID) bool { return id != 1 }); id != 2 {
+		t.Errorf("expected node 2 when node 1 is excluded, got %d", id)
+	}
+	if _, d := g.NearestNodeWhere(0, 0, dist, func(graph.NodeID) bool { return false }); d >= 0 {
+		t.Errorf("expected negative distance when nothing qualifies, got %v", d)
+	}
+}
@@ -0,0 +1,154 @@
+package graph
+
+import (
+	"math"
+	"sort"
+
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// Differences smaller than these are rounding noise, not changes.
+const (
+	diffDistanceTolerance = 0.01 // metres
+	diffCostTolerance     = 0.01 // seconds
+)
+
+// EdgeKey identifies the connection from one node to another. Parallel
+// edges share a key and are compared by their cheapest member, the one a
+// router would use.
+type EdgeKey struct {
+	From NodeID
+	To   NodeID
+}
+
+// EdgeChange is a connection present in both graphs whose length or cost
+// changed.
+type EdgeChange struct {
+	EdgeKey
+	Before Edge
+	After  Edge
+}
+
+// DistanceDelta returns how many metres longer the edge became.
+func (c EdgeChange) DistanceDelta() float64 {
+	return c.After.DistanceM - c.Before.DistanceM
+}
+
+// CostDelta returns how much more expensive the edge became.
+func (c EdgeChange) CostDelta() time.Seconds {
+	return c.After.Cost - c.Before.Cost
+}
+
+// Diff describes how a graph differs from an earlier build. All lists are
+// sorted by node ID.
+type Diff struct {
+	AddedNodes   []NodeID
+	RemovedNodes []NodeID
+	// MovedNodes exist in both graphs at different coordinates.
+	MovedNodes []NodeID
+
+	AddedEdges   []EdgeKey
+	RemovedEdges []EdgeKey
+	ChangedEdges []EdgeChange
+
+	// ComponentsBefore and ComponentsAfter are the component sizes of each
+	// graph, largest first. JoinedMain and LeftMain list the nodes present
+	// in both graphs that entered or left the main component.
+	ComponentsBefore []int
+	ComponentsAfter  []int
+	JoinedMain       []NodeID
+	LeftMain         []NodeID
+}
+
+// Empty reports whether the graphs are equivalent for routing, which makes
+// Compare usable as a regression check.
+func (d Diff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.MovedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && len(d.ChangedEdges) == 0 &&
+		len(d.JoinedMain) == 0 && len(d.LeftMain) == 0
+}
+
+// Compare reports what changed from before to after.
+func Compare(before, after *Graph) Diff {
+	var d Diff
+
+	for id, n := range after.Nodes {
+		old, ok := before.Nodes[id]
+		switch {
+		case !ok:
+			d.AddedNodes = append(d.AddedNodes, id)
+		case old.Lat != n.Lat || old.Lon != n.Lon:
+			d.MovedNodes = append(d.MovedNodes, id)
+		}
+	}
+	for id := range before.Nodes {
+		if !after.HasNode(id) {
+			d.RemovedNodes = append(d.RemovedNodes, id)
+		}
+	}
+
+	beforeKeys, afterKeys := before.edgeKeys(), after.edgeKeys()
+	for key := range afterKeys {
+		if !beforeKeys[key] {
+			d.AddedEdges = append(d.AddedEdges, key)
+			continue
+		}
+		old, _ := before.EdgeBetween(key.From, key.To)
+		cur, _ := after.EdgeBetween(key.From, key.To)
+		if math.Abs(cur.DistanceM-old.DistanceM) > diffDistanceTolerance ||
+			math.Abs(float64(cur.Cost-old.Cost)) > diffCostTolerance {
+			d.ChangedEdges = append(d.ChangedEdges, EdgeChange{EdgeKey: key, Before: old, After: cur})
+		}
+	}
+	for key := range beforeKeys {
+		if !afterKeys[key] {
+			d.RemovedEdges = append(d.RemovedEdges, key)
+		}
+	}
+
+	cb, ca := before.StronglyConnectedComponents(), after.StronglyConnectedComponents()
+	d.ComponentsBefore, d.ComponentsAfter = cb.Sizes, ca.Sizes
+	for id := range after.Nodes {
+		if !before.HasNode(id) {
+			continue
+		}
+		switch was, is := cb.InMain(id), ca.InMain(id); {
+		case is && !was:
+			d.JoinedMain = append(d.JoinedMain, id)
+		case was && !is:
+			d.LeftMain = append(d.LeftMain, id)
+		}
+	}
+
+	for _, ids := range [][]NodeID{d.AddedNodes, d.RemovedNodes, d.MovedNodes, d.JoinedMain, d.LeftMain} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	for _, keys := range [][]EdgeKey{d.AddedEdges, d.RemovedEdges} {
+		sortKeys(keys)
+	}
+	sort.Slice(d.ChangedEdges, func(i, j int) bool {
+		return keyLess(d.ChangedEdges[i].EdgeKey, d.ChangedEdges[j].EdgeKey)
+	})
+	return d
+}
+
+func (g *Graph) edgeKeys() map[EdgeKey]bool {
+	keys := make(map[EdgeKey]bool)
+	for from, edges := range g.Edges {
+		for _, e := range edges {
+			keys[EdgeKey{From: from, To: e.To}] = true
+		}
+	}
+	return keys
+}
+
+func sortKeys(keys []EdgeKey) {
+	sort.Slice(keys, func(i, j int) bool { return keyLess(keys[i], keys[j]) })
+}
+
+func keyLess(a, b EdgeKey) bool {
+	if a.From != b.From {
+		return a.From < b.From
+	}
+	return a.To < b.To
+}
@@ -0,0 +1,67 @@
+package graph_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func diffBase() *graph.Graph {
+	g := graph.NewGraph()
+	for i := 1; i <= 4; i++ {
+		g.AddNode(graph.NodeID(i), 0, float64(i))
+	}
+	g.AddBidirectionalEdge(1, 2, 10)
+	g.AddBidirectionalEdge(2, 3, 10)
+	g.AddBidirectionalEdge(3, 4, 10)
+	return g
+}
+
+func TestCompareIdentical(t *testing.T) {
+	if d := graph.Compare(diffBase(), diffBase()); !d.Empty() {
+		t.Errorf("identical graphs differ: %+v", d)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := diffBase()
+	after := diffBase()
+
+	// Node 4 is cut off to a oneway spur, 5 is new, 2 moved and the
+	// 1-2 edge got longer.
+	after.RemoveNodes([]graph.NodeID{4})
+	after.AddNode(4, 0, 4)
+	after.AddEdge(3, 4, 10)
+	after.AddNode(5, 1, 1)
+	after.AddBidirectionalEdge(1, 5, 3)
+	after.AddNode(2, 0, 2.5)
+	after.Edges[1][0].DistanceM = 12
+
+	d := graph.Compare(before, after)
+
+	if want := []graph.NodeID{5}; !reflect.DeepEqual(d.AddedNodes, want) {
+		t.Errorf("AddedNodes = %v, want %v", d.AddedNodes, want)
+	}
+	if len(d.RemovedNodes) != 0 {
+		t.Errorf("RemovedNodes = %v, want none", d.RemovedNodes)
+	}
+	if want := []graph.NodeID{2}; !reflect.DeepEqual(d.MovedNodes, want) {
+		t.Errorf("MovedNodes = %v, want %v", d.MovedNodes, want)
+	}
+	if want := []graph.EdgeKey{{From: 1, To: 5}, {From: 5, To: 1}}; !reflect.DeepEqual(d.AddedEdges, want) {
+		t.Errorf("AddedEdges = %v, want %v", d.AddedEdges, want)
+	}
+	if want := []graph.EdgeKey{{From: 4, To: 3}}; !reflect.DeepEqual(d.RemovedEdges, want) {
+		t.Errorf("RemovedEdges = %v, want %v", d.RemovedEdges, want)
+	}
+	if len(d.ChangedEdges) != 1 || d.ChangedEdges[0].EdgeKey != (graph.EdgeKey{From: 1, To: 2}) || d.ChangedEdges[0].DistanceDelta() != 2 {
+		t.Errorf("ChangedEdges = %+v, want 1 -> 2 two metres longer", d.ChangedEdges)
+	}
+	if want := []graph.NodeID{4}; !reflect.DeepEqual(d.LeftMain, want) {
+		t.Errorf("LeftMain = %v, want %v", d.LeftMain, want)
+	}
+	if d.Empty() {
+		t.Error("Empty() = true for differing graphs")
+	}
+}
@@ -0,0 +1,35 @@
+package graph
+
+// Extract returns the subgraph of the nodes accepted by keep. Only edges
+// with both ends kept are copied, so every edge of the result points at
+// a node of the result. Profile, speed, notes and classes carry over and
+// edges share their geometry with g.
+func (g *Graph) Extract(keep func(Node) bool) *Graph {
+	sub := NewGraph()
+	sub.Profile = g.Profile
+	sub.Speed = g.Speed
+	sub.Notes = append([]string(nil), g.Notes...)
+	sub.Classes = append([]string(nil), g.Classes...)
+
+	for id, n := range g.Nodes {
+		if keep(n) {
+			sub.Nodes[id] = n
+		}
+	}
+	for from, edges := range g.Edges {
+		if !sub.HasNode(from) {
+			continue
+		}
+		for _, e := range edges {
+			if sub.HasNode(e.To) {
+				sub.AppendEdge(from, e)
+			}
+		}
+	}
+	for _, id := range g.Islands {
+		if sub.HasNode(id) {
+			sub.Islands = append(sub.Islands, id)
+		}
+	}
+	return sub
+}
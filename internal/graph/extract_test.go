@@ -0,0 +1,40 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func TestExtract(t *testing.T) {
+	g := graph.NewGraph()
+	g.Profile = "walking"
+	for i := 1; i <= 4; i++ {
+		g.AddNode(graph.NodeID(i), 0, float64(i))
+	}
+	g.AddBidirectionalEdge(1, 2, 1)
+	g.AddBidirectionalEdge(2, 3, 1)
+	g.AddBidirectionalEdge(3, 4, 1)
+	g.Islands = []graph.NodeID{1, 4}
+
+	sub := g.Extract(func(n graph.Node) bool { return n.Lon < 3.5 })
+
+	if sub.HasNode(4) || len(sub.Nodes) != 3 {
+		t.Fatalf("nodes = %v, want 1, 2 and 3", sub.Nodes)
+	}
+	if _, ok := sub.EdgeBetween(3, 4); ok {
+		t.Error("edges to dropped nodes must not be copied")
+	}
+	if _, ok := sub.EdgeBetween(3, 2); !ok {
+		t.Error("edges between kept nodes should be copied")
+	}
+	if sub.Profile != "walking" {
+		t.Errorf("Profile = %q, want walking", sub.Profile)
+	}
+	if len(sub.Islands) != 1 || sub.Islands[0] != 1 {
+		t.Errorf("Islands = %v, want [1]", sub.Islands)
+	}
+	if !g.HasNode(4) {
+		t.Error("Extract must not modify the original graph")
+	}
+}
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"encoding/gob"
+	"math"
 	"os"
 
 	"github.com/danielscoffee/pathcraft/internal/time"
@@ -10,20 +11,50 @@ import (
 type NodeID int64
 
 type Edge struct {
-	To        NodeID
+	To NodeID
+	// Cost is what routing minimises. It starts from Duration and may add
+	// profile preferences, such as avoiding busy roads on a bicycle.
 	Cost      time.Seconds
+	Duration  time.Seconds
 	DistanceM float64
+	// Ascent and Descent are the metres climbed and dropped along the edge
+	// in its direction of travel. Both are zero when no elevation data was used.
+	Ascent  float64
+	Descent float64
+	// Note refers to Graph.Notes, offset by one so that zero means none.
+	Note uint16
+	// Class refers to Graph.Classes in the same way.
+	Class uint16
+	// Geometry holds the shape points between the edge's ends, in travel
+	// order, when Simplify has merged a chain into this edge.
+	Geometry []Node
 }
 
 type Node struct {
 	ID  NodeID
 	Lat float64
 	Lon float64
+	Ele float64 // metres above sea level, zero when unknown
 }
 
 type Graph struct {
 	Nodes map[NodeID]Node
 	Edges map[NodeID][]Edge
+	// Profile names the mobility profile whose rules produced Edge.Cost and
+	// Speed is the nominal speed it was built with. Graphs assembled by hand
+	// leave both empty and carry distances only.
+	Profile string
+	Speed   float64
+	// Notes holds the distinct remarks profiles attached to edges, such as
+	// unverified accessibility. Edges refer to them through Edge.Note.
+	Notes []string
+	// Classes holds the highway values of the ways edges were built from,
+	// such as "footway", for statistics. Edges refer to them through
+	// Edge.Class.
+	Classes []string
+	// Islands lists nodes of components below the build's size threshold
+	// that were kept for inspection rather than dropped.
+	Islands []NodeID
 }
 
 func NewGraph() *Graph {
@@ -44,6 +75,72 @@ func (g *Graph) AddEdge(from, to NodeID, distanceM float64) {
 	})
 }
 
+// AppendEdge adds a fully described edge leaving from.
+func (g *Graph) AppendEdge(from NodeID, e Edge) {
+	g.Edges[from] = append(g.Edges[from], e)
+}
+
+// NoteID returns the Edge.Note value for the remarks parts, joined with
+// "; " leaving out empty and repeated ones, adding the text to Notes when
+// new. No text yields zero, and so does a new one once Notes holds as
+// many texts as Edge.Note can refer to.
+func (g *Graph) NoteID(parts ...string) uint16 {
+	var text string
+	seen := make(map[string]bool, len(parts))
+	for _, p := range parts {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if text != "" {
+			text += "; "
+		}
+		text += p
+	}
+	return tableID(&g.Notes, text)
+}
+
+// tableID returns the 1-based index of text in table, appending it when
+// new, or zero for an empty text or a full table.
+func tableID(table *[]string, text string) uint16 {
+	if text == "" {
+		return 0
+	}
+	for i, t := range *table {
+		if t == text {
+			return uint16(i + 1)
+		}
+	}
+	if len(*table) >= math.MaxUint16 {
+		return 0
+	}
+	*table = append(*table, text)
+	return uint16(len(*table))
+}
+
+// NoteText returns the remark attached to e, if any.
+func (g *Graph) NoteText(e Edge) string {
+	if e.Note == 0 || int(e.Note) > len(g.Notes) {
+		return ""
+	}
+	return g.Notes[e.Note-1]
+}
+
+// ClassID returns the Edge.Class value for a highway class, adding it to
+// Classes when new. An empty class yields zero, as does a new one once
+// Classes is full, see NoteID.
+func (g *Graph) ClassID(class string) uint16 {
+	return tableID(&g.Classes, class)
+}
+
+// ClassName returns the highway class of e, or "" when unknown.
+func (g *Graph) ClassName(e Edge) string {
+	if e.Class == 0 || int(e.Class) > len(g.Classes) {
+		return ""
+	}
+	return g.Classes[e.Class-1]
+}
+
 func (g *Graph) AddBidirectionalEdge(a, b NodeID, distanceM float64) {
 	g.AddEdge(a, b, distanceM)
 	g.AddEdge(b, a, distanceM)
@@ -53,13 +150,41 @@ func (g *Graph) Neighbors(id NodeID) []Edge {
 	return g.Edges[id]
 }
 
+// EdgeBetween returns the cheapest edge from a to b. Parallel edges can
+// exist when two ways share consecutive nodes, and a router always takes
+// the cheaper one.
+func (g *Graph) EdgeBetween(from, to NodeID) (Edge, bool) {
+	var best Edge
+	found := false
+	for _, e := range g.Edges[from] {
+		if e.To != to {
+			continue
+		}
+		if !found || e.Cost < best.Cost || (e.Cost == best.Cost && e.DistanceM < best.DistanceM) {
+			best = e
+			found = true
+		}
+	}
+	return best, found
+}
+
 // NearestNode returns the ID of the node closest to the given coordinates.
 // WARN: This is a linear search and should be optimized with a spatial index for large graphs.
 func (g *Graph) NearestNode(lat, lon float64, distanceFunc func(lat1, lon1, lat2, lon2 float64) float64) (NodeID, float64) {
+	return g.NearestNodeWhere(lat, lon, distanceFunc, nil)
+}
+
+// NearestNodeWhere is NearestNode restricted to nodes accepted by keep,
+// such as those in the main component. A nil keep accepts every node.
+// The distance is negative when no node qualifies.
+func (g *Graph) NearestNodeWhere(lat, lon float64, distanceFunc func(lat1, lon1, lat2, lon2 float64) float64, keep func(NodeID) bool) (NodeID, float64) {
 	var nearest NodeID
 	minDist := -1.0
 
 	for id, node := range g.Nodes {
+		if keep != nil && !keep(id) {
+			continue
+		}
 		dist := distanceFunc(lat, lon, node.Lat, node.Lon)
 		if minDist < 0 || dist < minDist {
 			minDist = dist
@@ -0,0 +1,49 @@
+package graph_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func TestNoteID(t *testing.T) {
+	g := graph.NewGraph()
+
+	if id := g.NoteID(); id != 0 {
+		t.Errorf("NoteID() = %d, want 0", id)
+	}
+	if id := g.NoteID("", ""); id != 0 {
+		t.Errorf("NoteID of empty notes = %d, want 0", id)
+	}
+
+	steps := g.NoteID("steps")
+	if got := g.NoteID("", "steps", "steps"); got != steps {
+		t.Errorf("NoteID with a repeated note = %d, want %d", got, steps)
+	}
+	both := g.NoteID("steps", "kerb not mapped")
+	if both == steps || g.NoteText(graph.Edge{Note: both}) != "steps; kerb not mapped" {
+		t.Errorf("joined note = %q", g.NoteText(graph.Edge{Note: both}))
+	}
+}
+
+func TestNoteID_FullTable(t *testing.T) {
+	g := graph.NewGraph()
+	for i := range math.MaxUint16 {
+		g.Notes = append(g.Notes, fmt.Sprint(i))
+	}
+
+	if id := g.NoteID("one too many"); id != 0 {
+		t.Errorf("NoteID on a full table = %d, want 0", id)
+	}
+	if id := g.NoteID("42"); id != 43 {
+		t.Errorf("NoteID of a known note = %d, want 43", id)
+	}
+	if id := g.ClassID("footway"); id != 1 {
+		t.Errorf("ClassID = %d, want 1", id)
+	}
+	if len(g.Notes) != math.MaxUint16 {
+		t.Errorf("Notes grew to %d", len(g.Notes))
+	}
+}
@@ -17,6 +17,7 @@ func Build(g *Graph) ViewModel {
 			ID:  n.ID,
 			Lat: n.Lat,
 			Lon: n.Lon,
+			Ele: n.Ele,
 		})
 	}
 
@@ -24,7 +25,7 @@ func Build(g *Graph) ViewModel {
 		Nodes: nodes,
 		Meta: Meta{
 			NodeCount: len(g.Nodes),
-			EdgeCount: len(g.Edges),
+			EdgeCount: g.edgeCount(),
 		},
 	}
 }
@@ -0,0 +1,205 @@
+package graph
+
+import "sort"
+
+// SimplifyStats reports what Simplify removed.
+type SimplifyStats struct {
+	NodesBefore, NodesAfter int
+	EdgesBefore, EdgesAfter int
+}
+
+// Simplify collapses chains of shape points into single edges. A node is
+// removed when keep rejects it, it links exactly two neighbours, and the
+// edges on either side can be joined without losing information: the same
+// directions are open and they carry the same note and class. The removed
+// nodes are stored in order in Edge.Geometry, and lengths, costs and
+// climbs are summed, so routes over the simplified graph expand to the
+// same path.
+func (g *Graph) Simplify(keep func(NodeID) bool) SimplifyStats {
+	stats := SimplifyStats{NodesBefore: len(g.Nodes), EdgesBefore: g.edgeCount()}
+
+	in := make(map[NodeID][]NodeID)
+	for from, edges := range g.Edges {
+		for _, e := range edges {
+			in[e.To] = append(in[e.To], from)
+		}
+	}
+
+	removable := make(map[NodeID]bool)
+	for id := range g.Nodes {
+		if (keep == nil || !keep(id)) && g.joinable(id, in[id]) {
+			removable[id] = true
+		}
+	}
+
+	ids := make([]NodeID, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	merged := make(map[NodeID][]Edge)
+	visited := make(map[NodeID]bool)
+	collapse := func(from NodeID) {
+		var out []Edge
+		for _, e := range g.Edges[from] {
+			prev := from
+			for removable[e.To] && e.To != from {
+				visited[e.To] = true
+				via := e.To
+				next, ok := g.continuation(via, prev)
+				if !ok {
+					break
+				}
+				e = joinEdges(e, g.Nodes[via], next)
+				prev = via
+			}
+			out = append(out, e)
+		}
+		merged[from] = out
+	}
+
+	for _, id := range ids {
+		if !removable[id] {
+			collapse(id)
+		}
+	}
+	// Rings made only of shape points, such as a detached loop path, have
+	// no kept node to start from; anchor each at its smallest ID.
+	for _, id := range ids {
+		if removable[id] && !visited[id] {
+			removable[id] = false
+			collapse(id)
+		}
+	}
+
+	for id := range removable {
+		if removable[id] {
+			delete(g.Nodes, id)
+		}
+	}
+	g.Edges = make(map[NodeID][]Edge, len(merged))
+	for from, edges := range merged {
+		if len(edges) > 0 {
+			g.Edges[from] = edges
+		}
+	}
+
+	if len(g.Islands) > 0 {
+		kept := g.Islands[:0]
+		for _, id := range g.Islands {
+			if g.HasNode(id) {
+				kept = append(kept, id)
+			}
+		}
+		g.Islands = kept
+	}
+
+	stats.NodesAfter = len(g.Nodes)
+	stats.EdgesAfter = g.edgeCount()
+	return stats
+}
+
+// joinable reports whether id is a shape point between two neighbours
+// whose edges can be merged through it.
+func (g *Graph) joinable(id NodeID, from []NodeID) bool {
+	out := g.Edges[id]
+	if len(out) > 2 || len(from) > 2 {
+		return false
+	}
+
+	neighbours := make(map[NodeID]bool, 2)
+	for _, e := range out {
+		if e.To == id {
+			return false
+		}
+		neighbours[e.To] = true
+	}
+	for _, f := range from {
+		if f == id {
+			return false
+		}
+		neighbours[f] = true
+	}
+	if len(neighbours) != 2 {
+		return false
+	}
+
+	var a, b NodeID
+	first := true
+	for n := range neighbours {
+		if first {
+			a, first = n, false
+		} else {
+			b = n
+		}
+	}
+
+	// Entering from a must allow leaving to b and vice versa, with no
+	// duplicates and matching notes.
+	edge := func(from, to NodeID) (Edge, int) {
+		var found Edge
+		count := 0
+		for _, e := range g.Edges[from] {
+			if e.To == to {
+				found = e
+				count++
+			}
+		}
+		return found, count
+	}
+	aIn, aInN := edge(a, id)
+	bOut, bOutN := edge(id, b)
+	bIn, bInN := edge(b, id)
+	aOut, aOutN := edge(id, a)
+	if aInN > 1 || bOutN > 1 || bInN > 1 || aOutN > 1 {
+		return false
+	}
+	if aInN != bOutN || bInN != aOutN {
+		return false
+	}
+	if aInN == 1 && (aIn.Note != bOut.Note || aIn.Class != bOut.Class) {
+		return false
+	}
+	if bInN == 1 && (bIn.Note != aOut.Note || bIn.Class != aOut.Class) {
+		return false
+	}
+	return aInN+bInN > 0
+}
+
+// continuation returns the edge leaving shape point id away from prev.
+func (g *Graph) continuation(id, prev NodeID) (Edge, bool) {
+	for _, e := range g.Edges[id] {
+		if e.To != prev {
+			return e, true
+		}
+	}
+	return Edge{}, false
+}
+
+func joinEdges(first Edge, via Node, second Edge) Edge {
+	geometry := make([]Node, 0, len(first.Geometry)+1+len(second.Geometry))
+	geometry = append(geometry, first.Geometry...)
+	geometry = append(geometry, via)
+	geometry = append(geometry, second.Geometry...)
+
+	return Edge{
+		To:        second.To,
+		Cost:      first.Cost + second.Cost,
+		Duration:  first.Duration + second.Duration,
+		DistanceM: first.DistanceM + second.DistanceM,
+		Ascent:    first.Ascent + second.Ascent,
+		Descent:   first.Descent + second.Descent,
+		Note:      first.Note,
+		Class:     first.Class,
+		Geometry:  geometry,
+	}
+}
+
+func (g *Graph) edgeCount() int {
+	count := 0
+	for _, edges := range g.Edges {
+		count += len(edges)
+	}
+	return count
+}
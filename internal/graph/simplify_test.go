@@ -0,0 +1,131 @@
+package graph_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func TestSimplifyCollapsesChains(t *testing.T) {
+	g := graph.NewGraph()
+	for i := 1; i <= 7; i++ {
+		g.AddNode(graph.NodeID(i), float64(i), 0)
+	}
+
+	// Junction 1 reaches junction 5 through shape points 2, 3 and 4;
+	// 6 and 7 hang off 1 so that it stays a junction.
+	g.AddBidirectionalEdge(1, 2, 10)
+	g.AddBidirectionalEdge(2, 3, 20)
+	g.AddBidirectionalEdge(3, 4, 30)
+	g.AddBidirectionalEdge(4, 5, 40)
+	g.AddBidirectionalEdge(1, 6, 1)
+	g.AddBidirectionalEdge(1, 7, 1)
+
+	stats := g.Simplify(func(id graph.NodeID) bool { return id == 3 })
+
+	if g.HasNode(2) || g.HasNode(4) {
+		t.Error("shape points 2 and 4 should be removed")
+	}
+	if !g.HasNode(3) {
+		t.Error("node 3 was kept by the caller and should survive")
+	}
+	if stats.NodesBefore != 7 || stats.NodesAfter != 5 {
+		t.Errorf("nodes %d -> %d, want 7 -> 5", stats.NodesBefore, stats.NodesAfter)
+	}
+	if stats.EdgesBefore != 12 || stats.EdgesAfter != 8 {
+		t.Errorf("edges %d -> %d, want 12 -> 8", stats.EdgesBefore, stats.EdgesAfter)
+	}
+
+	e, ok := g.EdgeBetween(1, 3)
+	if !ok {
+		t.Fatal("expected a merged edge 1 -> 3")
+	}
+	if e.DistanceM != 30 {
+		t.Errorf("DistanceM = %v, want 30", e.DistanceM)
+	}
+	if len(e.Geometry) != 1 || e.Geometry[0].ID != 2 {
+		t.Errorf("Geometry = %v, want [node 2]", e.Geometry)
+	}
+
+	back, ok := g.EdgeBetween(5, 3)
+	if !ok {
+		t.Fatal("expected a merged edge 5 -> 3")
+	}
+	if len(back.Geometry) != 1 || back.Geometry[0].ID != 4 || back.Geometry[0].Lat != 4 {
+		t.Errorf("Geometry = %v, want [node 4]", back.Geometry)
+	}
+}
+
+func TestSimplifyKeepsDirectionChanges(t *testing.T) {
+	g := graph.NewGraph()
+	for i := 1; i <= 4; i++ {
+		g.AddNode(graph.NodeID(i), 0, float64(i))
+	}
+
+	// A oneway chain collapses; 3 joins a two-way edge to a oneway one and
+	// must stay so that the direction change is not lost.
+	g.AddEdge(1, 2, 5)
+	g.AddEdge(2, 3, 5)
+	g.AddBidirectionalEdge(3, 4, 5)
+
+	g.Simplify(nil)
+
+	if g.HasNode(2) {
+		t.Error("node 2 on a oneway chain should be removed")
+	}
+	if !g.HasNode(3) {
+		t.Error("node 3 where the oneway ends should be kept")
+	}
+	if e, ok := g.EdgeBetween(1, 3); !ok || e.DistanceM != 10 {
+		t.Errorf("EdgeBetween(1, 3) = %+v, %v; want a 10 m edge", e, ok)
+	}
+	if _, ok := g.EdgeBetween(3, 1); ok {
+		t.Error("the oneway must not gain a reverse edge")
+	}
+}
+
+func TestSimplifyKeepsNotedBoundaries(t *testing.T) {
+	g := graph.NewGraph()
+	for i := 1; i <= 3; i++ {
+		g.AddNode(graph.NodeID(i), 0, float64(i))
+	}
+	note := g.NoteID("surface unknown")
+	g.AppendEdge(1, graph.Edge{To: 2, DistanceM: 1})
+	g.AppendEdge(2, graph.Edge{To: 1, DistanceM: 1})
+	g.AppendEdge(2, graph.Edge{To: 3, DistanceM: 1, Note: note})
+	g.AppendEdge(3, graph.Edge{To: 2, DistanceM: 1, Note: note})
+
+	g.Simplify(nil)
+
+	if !g.HasNode(2) {
+		t.Error("node 2 separates edges with different notes and should be kept")
+	}
+}
+
+func TestSimplifyDetachedRing(t *testing.T) {
+	g := graph.NewGraph()
+	for i := 1; i <= 4; i++ {
+		g.AddNode(graph.NodeID(i), float64(i), float64(i))
+	}
+	g.AddBidirectionalEdge(1, 2, 1)
+	g.AddBidirectionalEdge(2, 3, 1)
+	g.AddBidirectionalEdge(3, 4, 1)
+	g.AddBidirectionalEdge(4, 1, 1)
+
+	g.Simplify(nil)
+
+	if len(g.Nodes) != 1 || !g.HasNode(1) {
+		t.Fatalf("nodes = %v, want only the anchor 1", g.Nodes)
+	}
+	total := 0.0
+	for _, e := range g.Edges[1] {
+		if e.To != 1 || len(e.Geometry) != 3 {
+			t.Errorf("edge %+v should loop back to 1 through three shape points", e)
+		}
+		total += e.DistanceM
+	}
+	if math.Abs(total-8) > 1e-9 {
+		t.Errorf("ring length both ways = %v, want 8", total)
+	}
+}
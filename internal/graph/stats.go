@@ -0,0 +1,182 @@
+package graph
+
+import (
+	"math"
+	"sort"
+	"unsafe"
+)
+
+// ClassLength is the network length of one highway class.
+type ClassLength struct {
+	Class   string // "" for edges without a class
+	LengthM float64
+	Links   int
+}
+
+// BBox is the extent of a graph's nodes.
+type BBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// LatLon is a coordinate in degrees.
+type LatLon struct {
+	Lat float64
+	Lon float64
+}
+
+// NetworkStats summarises a graph as a street network.
+//
+// Lengths count each link between two nodes once, whichever directions it
+// can be travelled in, so a two-way street is not counted twice. Where
+// parallel edges join the same nodes the cheapest one stands for the link.
+type NetworkStats struct {
+	Nodes int
+	Edges int
+	Links int
+
+	TotalLengthM      float64
+	AvgEdgeLengthM    float64
+	OnewayLengthShare float64
+	// LengthByClass is sorted longest first.
+	LengthByClass []ClassLength
+
+	// Degrees[k] is the number of nodes linked to k distinct neighbours.
+	Degrees []int
+
+	BBox BBox
+	// Centroid is the mean of the node coordinates.
+	Centroid LatLon
+
+	// MemoryBytes estimates the heap used by the graph's nodes and edges.
+	MemoryBytes int64
+}
+
+// Stats computes NetworkStats in one pass over the graph.
+func (g *Graph) Stats() NetworkStats {
+	s := NetworkStats{
+		Nodes: len(g.Nodes),
+		Edges: g.edgeCount(),
+	}
+
+	type link struct {
+		edge              Edge
+		forward, backward bool
+	}
+	links := make(map[EdgeKey]*link)
+	totalEdgeLength := 0.0
+	for from, edges := range g.Edges {
+		for _, e := range edges {
+			totalEdgeLength += e.DistanceM
+			if e.To == from {
+				continue
+			}
+			key, forward := EdgeKey{From: from, To: e.To}, true
+			if key.From > key.To {
+				key, forward = EdgeKey{From: e.To, To: from}, false
+			}
+			l, ok := links[key]
+			if !ok {
+				l = &link{edge: e}
+				links[key] = l
+			} else if e.Cost < l.edge.Cost {
+				l.edge = e
+			}
+			if forward {
+				l.forward = true
+			} else {
+				l.backward = true
+			}
+		}
+	}
+	if s.Edges > 0 {
+		s.AvgEdgeLengthM = totalEdgeLength / float64(s.Edges)
+	}
+
+	byClass := make(map[string]*ClassLength)
+	degree := make(map[NodeID]int)
+	onewayLength := 0.0
+	for key, l := range links {
+		s.Links++
+		s.TotalLengthM += l.edge.DistanceM
+		if l.forward != l.backward {
+			onewayLength += l.edge.DistanceM
+		}
+
+		class := g.ClassName(l.edge)
+		c, ok := byClass[class]
+		if !ok {
+			c = &ClassLength{Class: class}
+			byClass[class] = c
+		}
+		c.LengthM += l.edge.DistanceM
+		c.Links++
+
+		degree[key.From]++
+		degree[key.To]++
+	}
+	if s.TotalLengthM > 0 {
+		s.OnewayLengthShare = onewayLength / s.TotalLengthM
+	}
+	for _, c := range byClass {
+		s.LengthByClass = append(s.LengthByClass, *c)
+	}
+	sort.Slice(s.LengthByClass, func(i, j int) bool {
+		a, b := s.LengthByClass[i], s.LengthByClass[j]
+		if a.LengthM != b.LengthM {
+			return a.LengthM > b.LengthM
+		}
+		return a.Class < b.Class
+	})
+
+	first := true
+	var sumLat, sumLon float64
+	for id, n := range g.Nodes {
+		d := degree[id]
+		for len(s.Degrees) <= d {
+			s.Degrees = append(s.Degrees, 0)
+		}
+		s.Degrees[d]++
+
+		sumLat += n.Lat
+		sumLon += n.Lon
+		if first {
+			s.BBox = BBox{MinLat: n.Lat, MinLon: n.Lon, MaxLat: n.Lat, MaxLon: n.Lon}
+			first = false
+			continue
+		}
+		s.BBox.MinLat = math.Min(s.BBox.MinLat, n.Lat)
+		s.BBox.MinLon = math.Min(s.BBox.MinLon, n.Lon)
+		s.BBox.MaxLat = math.Max(s.BBox.MaxLat, n.Lat)
+		s.BBox.MaxLon = math.Max(s.BBox.MaxLon, n.Lon)
+	}
+	if s.Nodes > 0 {
+		s.Centroid = LatLon{Lat: sumLat / float64(s.Nodes), Lon: sumLon / float64(s.Nodes)}
+	}
+
+	s.MemoryBytes = g.memoryEstimate()
+	return s
+}
+
+// Rough per-entry overhead of a Go map beyond its keys and values.
+const mapEntryOverhead = 16
+
+func (g *Graph) memoryEstimate() int64 {
+	var nodeID NodeID
+	var node Node
+	var edge Edge
+	var slice []Edge
+
+	bytes := int64(len(g.Nodes)) * int64(unsafe.Sizeof(nodeID)+unsafe.Sizeof(node)+mapEntryOverhead)
+	bytes += int64(len(g.Edges)) * int64(unsafe.Sizeof(nodeID)+unsafe.Sizeof(slice)+mapEntryOverhead)
+	for _, edges := range g.Edges {
+		bytes += int64(cap(edges)) * int64(unsafe.Sizeof(edge))
+		for _, e := range edges {
+			bytes += int64(cap(e.Geometry)) * int64(unsafe.Sizeof(node))
+		}
+	}
+	bytes += int64(len(g.Islands)) * int64(unsafe.Sizeof(nodeID))
+	return bytes
+}
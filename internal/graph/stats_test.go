@@ -0,0 +1,74 @@
+package graph_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+func TestStats(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddNode(1, 10, 20)
+	g.AddNode(2, 10, 22)
+	g.AddNode(3, 12, 22)
+	g.AddNode(4, 12, 20)
+
+	footway, steps := g.ClassID("footway"), g.ClassID("steps")
+	two := func(a, b graph.NodeID, d float64, class uint16) {
+		g.AppendEdge(a, graph.Edge{To: b, Cost: 1, DistanceM: d, Class: class})
+		g.AppendEdge(b, graph.Edge{To: a, Cost: 1, DistanceM: d, Class: class})
+	}
+	two(1, 2, 100, footway)
+	two(2, 3, 300, footway)
+	g.AppendEdge(3, graph.Edge{To: 4, Cost: 1, DistanceM: 200, Class: steps})
+	// A slower parallel edge must not count twice.
+	g.AppendEdge(3, graph.Edge{To: 4, Cost: 5, DistanceM: 250, Class: steps})
+
+	s := g.Stats()
+
+	if s.Nodes != 4 || s.Edges != 6 || s.Links != 3 {
+		t.Errorf("nodes, edges, links = %d, %d, %d, want 4, 6, 3", s.Nodes, s.Edges, s.Links)
+	}
+	if s.TotalLengthM != 600 {
+		t.Errorf("TotalLengthM = %v, want 600: two-way links count once", s.TotalLengthM)
+	}
+	if want := (2*100 + 2*300 + 200 + 250) / 6.0; math.Abs(s.AvgEdgeLengthM-want) > 1e-9 {
+		t.Errorf("AvgEdgeLengthM = %v, want %v", s.AvgEdgeLengthM, want)
+	}
+	if want := 200.0 / 600; math.Abs(s.OnewayLengthShare-want) > 1e-9 {
+		t.Errorf("OnewayLengthShare = %v, want %v", s.OnewayLengthShare, want)
+	}
+
+	want := []graph.ClassLength{{Class: "footway", LengthM: 400, Links: 2}, {Class: "steps", LengthM: 200, Links: 1}}
+	if len(s.LengthByClass) != len(want) {
+		t.Fatalf("LengthByClass = %v, want %v", s.LengthByClass, want)
+	}
+	for i := range want {
+		if s.LengthByClass[i] != want[i] {
+			t.Errorf("LengthByClass[%d] = %v, want %v", i, s.LengthByClass[i], want[i])
+		}
+	}
+
+	// Nodes 1 and 4 have one neighbour, 2 and 3 have two.
+	if len(s.Degrees) != 3 || s.Degrees[0] != 0 || s.Degrees[1] != 2 || s.Degrees[2] != 2 {
+		t.Errorf("Degrees = %v, want [0 2 2]", s.Degrees)
+	}
+
+	if s.BBox != (graph.BBox{MinLat: 10, MinLon: 20, MaxLat: 12, MaxLon: 22}) {
+		t.Errorf("BBox = %+v", s.BBox)
+	}
+	if s.Centroid != (graph.LatLon{Lat: 11, Lon: 21}) {
+		t.Errorf("Centroid = %+v, want 11, 21", s.Centroid)
+	}
+	if s.MemoryBytes <= 0 {
+		t.Errorf("MemoryBytes = %d, want a positive estimate", s.MemoryBytes)
+	}
+}
+
+func TestStats_Empty(t *testing.T) {
+	s := graph.NewGraph().Stats()
+	if s.Nodes != 0 || s.TotalLengthM != 0 || s.OnewayLengthShare != 0 || s.AvgEdgeLengthM != 0 {
+		t.Errorf("empty graph stats = %+v", s)
+	}
+}
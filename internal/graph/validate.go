@@ -0,0 +1,262 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// IssueKind names a class of graph defect.
+type IssueKind string
+
+const (
+	// IssueDanglingEdge is an edge to a node missing from Graph.Nodes.
+	IssueDanglingEdge IssueKind = "dangling_edge"
+	// IssueOrphanEdges is an entry of Graph.Edges for a missing node.
+	IssueOrphanEdges IssueKind = "orphan_edges"
+	// IssueBadCoordinate is a node at NaN, infinite or out of range
+	// coordinates, or at exactly (0, 0), where unset coordinates end up.
+	IssueBadCoordinate IssueKind = "bad_coordinate"
+	// IssueNegativeCost is an edge with a negative or NaN cost or
+	// duration, which breaks shortest path search.
+	IssueNegativeCost IssueKind = "negative_cost"
+	// IssueBadDistance is an edge with a negative or NaN length.
+	IssueBadDistance IssueKind = "bad_distance"
+	// IssueLengthMismatch is an edge whose length differs from the
+	// distance along its geometry.
+	IssueLengthMismatch IssueKind = "length_mismatch"
+	// IssueSelfLoop is an edge from a node to itself.
+	IssueSelfLoop IssueKind = "self_loop"
+	// IssueDuplicateEdge is a parallel edge identical to another.
+	IssueDuplicateEdge IssueKind = "duplicate_edge"
+	// IssueZeroDistance is an edge of zero length, usually between two
+	// nodes mapped on the same spot.
+	IssueZeroDistance IssueKind = "zero_distance"
+)
+
+// Severity tells whether an issue makes the graph unusable.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+var issueSeverity = map[IssueKind]Severity{
+	IssueDanglingEdge:   SeverityError,
+	IssueOrphanEdges:    SeverityError,
+	IssueBadCoordinate:  SeverityError,
+	IssueNegativeCost:   SeverityError,
+	IssueBadDistance:    SeverityError,
+	IssueLengthMismatch: SeverityWarning,
+	IssueSelfLoop:       SeverityWarning,
+	IssueDuplicateEdge:  SeverityWarning,
+	IssueZeroDistance:   SeverityWarning,
+}
+
+// Severity returns how serious issues of this kind are.
+func (k IssueKind) Severity() Severity {
+	return issueSeverity[k]
+}
+
+// Issue is one defect found by Validate. To is set for edge issues.
+type Issue struct {
+	Kind     IssueKind
+	Severity Severity
+	Node     NodeID
+	To       *NodeID
+	Message  string
+}
+
+// maxIssuesPerKind bounds the issues listed in a report; Counts always
+// has the full totals.
+const maxIssuesPerKind = 100
+
+// Lengths may differ from the geometry by this fraction plus lengthSlack
+// metres before they count as inconsistent.
+const (
+	lengthTolerance = 0.01
+	lengthSlack     = 1.0
+)
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	Nodes    int
+	Edges    int
+	Errors   int
+	Warnings int
+	Counts   map[IssueKind]int
+	Issues   []Issue
+}
+
+// ErrInvalidGraph is wrapped by ValidationReport.Err.
+var ErrInvalidGraph = errors.New("invalid graph")
+
+// Valid reports whether no errors were found. Warnings are allowed.
+func (r ValidationReport) Valid() bool {
+	return r.Errors == 0
+}
+
+// Err returns nil for a valid graph and otherwise an error wrapping
+// ErrInvalidGraph that counts the errors by kind.
+func (r ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	var parts []string
+	for _, kind := range sortedKinds(r.Counts) {
+		if kind.Severity() == SeverityError {
+			parts = append(parts, fmt.Sprintf("%d %s", r.Counts[kind], kind))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidGraph, strings.Join(parts, ", "))
+}
+
+func sortedKinds(counts map[IssueKind]int) []IssueKind {
+	kinds := make([]IssueKind, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+	return kinds
+}
+
+// Validate checks the graph's integrity: that edges connect existing nodes
+// at sane coordinates, and that lengths and costs are usable for routing.
+func (g *Graph) Validate() ValidationReport {
+	r := ValidationReport{
+		Nodes:  len(g.Nodes),
+		Edges:  g.edgeCount(),
+		Counts: make(map[IssueKind]int),
+		Issues: []Issue{},
+	}
+	add := func(kind IssueKind, node NodeID, to *NodeID, format string, args ...any) {
+		r.Counts[kind]++
+		if kind.Severity() == SeverityError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+		if r.Counts[kind] <= maxIssuesPerKind {
+			r.Issues = append(r.Issues, Issue{
+				Kind:     kind,
+				Severity: kind.Severity(),
+				Node:     node,
+				To:       to,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+	}
+
+	ids := make([]NodeID, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if msg := badCoordinate(g.Nodes[id]); msg != "" {
+			add(IssueBadCoordinate, id, nil, "node %d %s", id, msg)
+		}
+	}
+
+	from := make([]NodeID, 0, len(g.Edges))
+	for id := range g.Edges {
+		from = append(from, id)
+	}
+	sort.Slice(from, func(i, j int) bool { return from[i] < from[j] })
+
+	for _, id := range from {
+		edges := g.Edges[id]
+		if !g.HasNode(id) {
+			add(IssueOrphanEdges, id, nil, "%d edges leave node %d, which does not exist", len(edges), id)
+			continue
+		}
+		for i, e := range edges {
+			to := e.To
+			switch {
+			case !g.HasNode(e.To):
+				add(IssueDanglingEdge, id, &to, "edge %d -> %d leads to a missing node", id, e.To)
+				continue
+			case e.To == id:
+				add(IssueSelfLoop, id, &to, "edge %d -> %d is a self-loop", id, e.To)
+			}
+
+			if e.Cost < 0 || e.Duration < 0 || math.IsNaN(float64(e.Cost)) || math.IsNaN(float64(e.Duration)) {
+				add(IssueNegativeCost, id, &to, "edge %d -> %d has cost %v and duration %v", id, e.To, float64(e.Cost), float64(e.Duration))
+			}
+
+			switch {
+			case e.DistanceM < 0 || math.IsNaN(e.DistanceM):
+				add(IssueBadDistance, id, &to, "edge %d -> %d has length %v", id, e.To, e.DistanceM)
+			case e.DistanceM == 0 && e.To != id:
+				add(IssueZeroDistance, id, &to, "edge %d -> %d has zero length", id, e.To)
+			default:
+				if along := g.geometryLength(id, e); math.Abs(e.DistanceM-along) > along*lengthTolerance+lengthSlack {
+					add(IssueLengthMismatch, id, &to, "edge %d -> %d is %.1f m long but its geometry measures %.1f m", id, e.To, e.DistanceM, along)
+				}
+			}
+
+			for _, prev := range edges[:i] {
+				if sameEdge(prev, e) {
+					add(IssueDuplicateEdge, id, &to, "edge %d -> %d is listed twice", id, e.To)
+					break
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+func badCoordinate(n Node) string {
+	switch {
+	case math.IsNaN(n.Lat) || math.IsNaN(n.Lon):
+		return "has NaN coordinates"
+	case math.IsInf(n.Lat, 0) || math.IsInf(n.Lon, 0):
+		return "has infinite coordinates"
+	case n.Lat < -90 || n.Lat > 90 || n.Lon < -180 || n.Lon > 180:
+		return fmt.Sprintf("is out of range at (%v, %v)", n.Lat, n.Lon)
+	case n.Lat == 0 && n.Lon == 0:
+		return "is at (0, 0), usually a node whose coordinates were never set"
+	}
+	return ""
+}
+
+// geometryLength measures e from its start node through its shape points
+// to its end node.
+func (g *Graph) geometryLength(from NodeID, e Edge) float64 {
+	prev := g.Nodes[from]
+	length := 0.0
+	for _, p := range e.Geometry {
+		length += haversine(prev, p)
+		prev = p
+	}
+	return length + haversine(prev, g.Nodes[e.To])
+}
+
+func sameEdge(a, b Edge) bool {
+	if a.To != b.To || a.Cost != b.Cost || a.Duration != b.Duration || a.DistanceM != b.DistanceM ||
+		a.Ascent != b.Ascent || a.Descent != b.Descent || a.Note != b.Note || a.Class != b.Class || len(a.Geometry) != len(b.Geometry) {
+		return false
+	}
+	for i := range a.Geometry {
+		if a.Geometry[i] != b.Geometry[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// haversine is geo.HaversineDistance, which this package cannot import
+// because geo depends on it.
+func haversine(a, b Node) float64 {
+	const earthRadius = 6_371_000
+	const rad = math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
@@ -0,0 +1,84 @@
+package graph_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+// validGraph has two nodes about 111 m apart, joined both ways.
+func validGraph() *graph.Graph {
+	g := graph.NewGraph()
+	g.AddNode(1, 10, 10)
+	g.AddNode(2, 10.001, 10)
+	g.AppendEdge(1, graph.Edge{To: 2, DistanceM: 111.2, Cost: 80, Duration: 80})
+	g.AppendEdge(2, graph.Edge{To: 1, DistanceM: 111.2, Cost: 80, Duration: 80})
+	return g
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(g *graph.Graph)
+		kind   graph.IssueKind
+		valid  bool
+	}{
+		{"dangling edge", func(g *graph.Graph) { g.AddEdge(1, 9, 5) }, graph.IssueDanglingEdge, false},
+		{"orphan edges", func(g *graph.Graph) { g.AddEdge(9, 1, 5) }, graph.IssueOrphanEdges, false},
+		{"NaN coordinate", func(g *graph.Graph) { g.AddNode(3, math.NaN(), 0) }, graph.IssueBadCoordinate, false},
+		{"null island", func(g *graph.Graph) { g.AddNode(3, 0, 0) }, graph.IssueBadCoordinate, false},
+		{"out of range", func(g *graph.Graph) { g.AddNode(3, 91, 0) }, graph.IssueBadCoordinate, false},
+		{"negative distance", func(g *graph.Graph) { g.Edges[1][0].DistanceM = -1 }, graph.IssueBadDistance, false},
+		{"negative cost", func(g *graph.Graph) { g.Edges[1][0].Cost = -1 }, graph.IssueNegativeCost, false},
+		{"zero distance", func(g *graph.Graph) { g.Edges[1][0].DistanceM = 0 }, graph.IssueZeroDistance, true},
+		{"length mismatch", func(g *graph.Graph) { g.Edges[1][0].DistanceM = 300 }, graph.IssueLengthMismatch, true},
+		{"self loop", func(g *graph.Graph) { g.AddEdge(1, 1, 0) }, graph.IssueSelfLoop, true},
+		{"duplicate edge", func(g *graph.Graph) { g.AppendEdge(1, g.Edges[1][0]) }, graph.IssueDuplicateEdge, true},
+	}
+
+	if r := validGraph().Validate(); r.Errors+r.Warnings != 0 {
+		t.Fatalf("valid graph reported %+v", r.Issues)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := validGraph()
+			tt.mutate(g)
+			r := g.Validate()
+
+			if r.Counts[tt.kind] != 1 {
+				t.Errorf("Counts = %v, want one %s", r.Counts, tt.kind)
+			}
+			if r.Valid() != tt.valid {
+				t.Errorf("Valid() = %v, want %v", r.Valid(), tt.valid)
+			}
+			if err := r.Err(); tt.valid != (err == nil) || (err != nil && !errors.Is(err, graph.ErrInvalidGraph)) {
+				t.Errorf("Err() = %v", err)
+			}
+			if len(r.Issues) == 0 || r.Issues[0].Kind != tt.kind {
+				t.Errorf("Issues = %+v, want a %s issue", r.Issues, tt.kind)
+			}
+		})
+	}
+}
+
+func TestValidateSimplifiedGeometry(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddNode(1, 10, 10)
+	g.AddNode(2, 10.001, 10)
+	g.AddNode(3, 10.001, 10.001)
+	g.AddBidirectionalEdge(1, 2, 111.2)
+	g.AddBidirectionalEdge(2, 3, 109.5)
+	g.AddBidirectionalEdge(3, 1, 500) // joins 1 and 3 via another node
+	g.AddNode(4, 9, 9)
+	g.AddBidirectionalEdge(4, 1, 156000)
+	g.Simplify(func(id graph.NodeID) bool { return id == 1 || id == 3 || id == 4 })
+
+	// The merged 1 -> 3 edge is measured through its shape point, not
+	// in a straight line.
+	if r := g.Validate(); r.Counts[graph.IssueLengthMismatch] != 2 {
+		t.Errorf("Counts = %v, want only the two 500 m edges flagged", r.Counts)
+	}
+}
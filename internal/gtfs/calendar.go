@@ -0,0 +1,229 @@
+package gtfs
+
+import (
+	"fmt"
+	"io"
+	stdtime "time"
+
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+type ServiceID string
+
+// Date is a calendar day, written YYYYMMDD in GTFS files.
+type Date struct {
+	Year  int
+	Month stdtime.Month
+	Day   int
+}
+
+// ParseDate parses a GTFS date such as 20240115.
+func ParseDate(s string) (Date, error) {
+	t, err := stdtime.Parse("20060102", s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return DateOf(t), nil
+}
+
+// DateOf returns the day t falls on in t's location.
+func DateOf(t stdtime.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d%02d%02d", d.Year, d.Month, d.Day)
+}
+
+// AddDays returns the date n days later.
+func (d Date) AddDays(n int) Date {
+	return DateOf(stdtime.Date(d.Year, d.Month, d.Day+n, 12, 0, 0, 0, stdtime.UTC))
+}
+
+func (d Date) Weekday() stdtime.Weekday {
+	return stdtime.Date(d.Year, d.Month, d.Day, 12, 0, 0, 0, stdtime.UTC).Weekday()
+}
+
+func (d Date) Before(o Date) bool {
+	if d.Year != o.Year {
+		return d.Year < o.Year
+	}
+	if d.Month != o.Month {
+		return d.Month < o.Month
+	}
+	return d.Day < o.Day
+}
+
+// Start returns the instant GTFS times on this service day count from:
+// noon minus twelve hours, which is midnight except on days when daylight
+// saving time begins or ends.
+func (d Date) Start(loc *stdtime.Location) stdtime.Time {
+	return stdtime.Date(d.Year, d.Month, d.Day, 12, 0, 0, 0, loc).Add(-12 * stdtime.Hour)
+}
+
+// ServiceTime splits an instant into its service day in loc and the GTFS
+// time on that day.
+func ServiceTime(t stdtime.Time, loc *stdtime.Location) (Date, time.Time) {
+	d := DateOf(t.In(loc))
+	return d, time.Time(t.Sub(d.Start(loc)) / stdtime.Second)
+}
+
+// Calendar is a calendar.txt entry: the weekdays a service runs on
+// between two dates, inclusive.
+type Calendar struct {
+	ServiceID ServiceID
+	Weekdays  [7]bool // indexed by time.Weekday
+	StartDate Date
+	EndDate   Date
+}
+
+// ExceptionType tells whether a calendar_dates.txt entry adds or removes
+// service.
+type ExceptionType int
+
+const (
+	ServiceAdded   ExceptionType = 1
+	ServiceRemoved ExceptionType = 2
+)
+
+// CalendarDate is a calendar_dates.txt entry, an exception to Calendar.
+type CalendarDate struct {
+	ServiceID     ServiceID
+	Date          Date
+	ExceptionType ExceptionType
+}
+
+// Services answers which services run on a date.
+type Services struct {
+	calendars  map[ServiceID]Calendar
+	exceptions map[ServiceID]map[Date]ExceptionType
+}
+
+func NewServices(calendars []Calendar, dates []CalendarDate) *Services {
+	s := &Services{
+		calendars:  make(map[ServiceID]Calendar, len(calendars)),
+		exceptions: make(map[ServiceID]map[Date]ExceptionType),
+	}
+	for _, c := range calendars {
+		s.calendars[c.ServiceID] = c
+	}
+	for _, d := range dates {
+		if s.exceptions[d.ServiceID] == nil {
+			s.exceptions[d.ServiceID] = make(map[Date]ExceptionType)
+		}
+		s.exceptions[d.ServiceID][d.Date] = d.ExceptionType
+	}
+	return s
+}
+
+// Empty reports whether no calendars are known, as for feeds that leave
+// out both calendar files.
+func (s *Services) Empty() bool {
+	return len(s.calendars) == 0 && len(s.exceptions) == 0
+}
+
+// Runs reports whether service id runs on d. Exceptions override the
+// weekly calendar.
+func (s *Services) Runs(id ServiceID, d Date) bool {
+	switch s.exceptions[id][d] {
+	case ServiceAdded:
+		return true
+	case ServiceRemoved:
+		return false
+	}
+	c, ok := s.calendars[id]
+	if !ok || d.Before(c.StartDate) || c.EndDate.Before(d) {
+		return false
+	}
+	return c.Weekdays[d.Weekday()]
+}
+
+var weekdayColumns = [7]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+func ParseCalendar(r io.Reader) ([]Calendar, error) {
+	required := append([]string{"service_id", "start_date", "end_date"}, weekdayColumns[:]...)
+	t, err := newTable(r, required...)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []Calendar
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		c := Calendar{ServiceID: ServiceID(t.get(record, "service_id"))}
+		for day, col := range weekdayColumns {
+			switch v := t.get(record, col); v {
+			case "0":
+			case "1":
+				c.Weekdays[day] = true
+			default:
+				return nil, fmt.Errorf("line %d: %w: %s is %q, want 0 or 1", t.line, ErrInvalidData, col, v)
+			}
+		}
+
+		if c.StartDate, err = ParseDate(t.get(record, "start_date")); err != nil {
+			return nil, fmt.Errorf("line %d: start_date: %w", t.line, err)
+		}
+		if c.EndDate, err = ParseDate(t.get(record, "end_date")); err != nil {
+			return nil, fmt.Errorf("line %d: end_date: %w", t.line, err)
+		}
+
+		calendars = append(calendars, c)
+	}
+
+	return calendars, nil
+}
+
+func ParseCalendarFile(path string) ([]Calendar, error) {
+	return parseFile(path, ParseCalendar)
+}
+
+func ParseCalendarDates(r io.Reader) ([]CalendarDate, error) {
+	t, err := newTable(r, "service_id", "date", "exception_type")
+	if err != nil {
+		return nil, err
+	}
+
+	var dates []CalendarDate
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		d := CalendarDate{ServiceID: ServiceID(t.get(record, "service_id"))}
+		if d.Date, err = ParseDate(t.get(record, "date")); err != nil {
+			return nil, fmt.Errorf("line %d: date: %w", t.line, err)
+		}
+
+		exception, err := t.int(record, "exception_type", 0)
+		if err != nil {
+			return nil, err
+		}
+		d.ExceptionType = ExceptionType(exception)
+		if d.ExceptionType != ServiceAdded && d.ExceptionType != ServiceRemoved {
+			return nil, fmt.Errorf("line %d: %w: exception_type %d", t.line, ErrInvalidData, exception)
+		}
+
+		dates = append(dates, d)
+	}
+
+	return dates, nil
+}
+
+func ParseCalendarDatesFile(path string) ([]CalendarDate, error) {
+	return parseFile(path, ParseCalendarDates)
+}
@@ -0,0 +1,193 @@
+package gtfs_test
+
+import (
+	"strings"
+	"testing"
+	stdtime "time"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+func date(t *testing.T, s string) gtfs.Date {
+	t.Helper()
+	d, err := gtfs.ParseDate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestServices_Runs(t *testing.T) {
+	calendars, err := gtfs.ParseCalendar(strings.NewReader(`service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date
+WD,1,1,1,1,1,0,0,20250101,20251231
+WE,0,0,0,0,0,1,1,20250101,20251231
+`))
+	if err != nil {
+		t.Fatalf("ParseCalendar() error = %v", err)
+	}
+	dates, err := gtfs.ParseCalendarDates(strings.NewReader(`service_id,date,exception_type
+WD,20251225,2
+WE,20251225,1
+EXTRA,20250704,1
+`))
+	if err != nil {
+		t.Fatalf("ParseCalendarDates() error = %v", err)
+	}
+	services := gtfs.NewServices(calendars, dates)
+
+	tests := []struct {
+		service gtfs.ServiceID
+		date    string
+		want    bool
+	}{
+		{"WD", "20250310", true},  // Monday
+		{"WD", "20250308", false}, // Saturday
+		{"WE", "20250308", true},
+		{"WD", "20251225", false}, // removed on a Thursday
+		{"WE", "20251225", true},  // added on a Thursday
+		{"WD", "20241231", false}, // before start_date
+		{"WD", "20251231", true},  // end_date is inclusive
+		{"WD", "20260101", false},
+		{"EXTRA", "20250704", true}, // only in calendar_dates.txt
+		{"EXTRA", "20250705", false},
+		{"UNKNOWN", "20250310", false},
+	}
+	for _, tt := range tests {
+		if got := services.Runs(tt.service, date(t, tt.date)); got != tt.want {
+			t.Errorf("Runs(%s, %s) = %v, want %v", tt.service, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestParseCalendar_Invalid(t *testing.T) {
+	header := "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n"
+	for _, row := range []string{
+		"WD,1,1,1,1,yes,0,0,20250101,20251231",
+		"WD,1,1,1,1,1,0,0,2025-01-01,20251231",
+	} {
+		if _, err := gtfs.ParseCalendar(strings.NewReader(header + row + "\n")); err == nil {
+			t.Errorf("expected an error for %q", row)
+		}
+	}
+	if _, err := gtfs.ParseCalendarDates(strings.NewReader("service_id,date,exception_type\nWD,20250101,3\n")); err == nil {
+		t.Error("expected an error for exception_type 3")
+	}
+}
+
+func TestDate(t *testing.T) {
+	d := date(t, "20250228")
+	if got := d.AddDays(1).String(); got != "20250301" {
+		t.Errorf("AddDays(1) = %s, want 20250301", got)
+	}
+	if got := d.AddDays(-59).String(); got != "20241231" {
+		t.Errorf("AddDays(-59) = %s, want 20241231", got)
+	}
+	if d.Weekday() != stdtime.Friday {
+		t.Errorf("Weekday() = %v, want Friday", d.Weekday())
+	}
+}
+
+func TestServiceTime(t *testing.T) {
+	loc, err := stdtime.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name    string
+		at      stdtime.Time
+		wantDay string
+		want    time.Time
+	}{
+		{"ordinary day", stdtime.Date(2025, 3, 10, 8, 0, 0, 0, loc), "20250310", 8 * 3600},
+		// Service days start at noon minus twelve hours, an hour before
+		// midnight when clocks spring forward.
+		{"daylight saving starts", stdtime.Date(2025, 3, 9, 8, 0, 0, 0, loc), "20250309", 8 * 3600},
+		{"other zone", stdtime.Date(2025, 3, 10, 13, 0, 0, 0, stdtime.UTC), "20250310", 9 * 3600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, got := gtfs.ServiceTime(tt.at, loc)
+			if day.String() != tt.wantDay || got != tt.want {
+				t.Errorf("ServiceTime() = %s %s, want %s %s", day, got, tt.wantDay, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeed_IndexFor(t *testing.T) {
+	feed := &gtfs.Feed{
+		Trips: map[gtfs.TripID]*gtfs.Trip{
+			"DAY":   {ID: "DAY", RouteID: "R", ServiceID: "WD"},
+			"NIGHT": {ID: "NIGHT", RouteID: "R", ServiceID: "WD"},
+			"SUN":   {ID: "SUN", RouteID: "R", ServiceID: "SUN"},
+		},
+		StopTimes: []gtfs.StopTime{
+			{TripID: "DAY", StopID: "A", ArrivalTime: 8 * 3600, DepartureTime: 8 * 3600, StopSequence: 1},
+			{TripID: "DAY", StopID: "B", ArrivalTime: 9 * 3600, DepartureTime: 9 * 3600, StopSequence: 2},
+			{TripID: "NIGHT", StopID: "A", ArrivalTime: 23 * 3600, DepartureTime: 23 * 3600, StopSequence: 1},
+			{TripID: "NIGHT", StopID: "B", ArrivalTime: 25 * 3600, DepartureTime: 25 * 3600, StopSequence: 2},
+			{TripID: "SUN", StopID: "A", ArrivalTime: 10 * 3600, DepartureTime: 10 * 3600, StopSequence: 1},
+			{TripID: "SUN", StopID: "B", ArrivalTime: 11 * 3600, DepartureTime: 11 * 3600, StopSequence: 2},
+		},
+	}
+	week := [7]bool{false, true, true, true, true, true, false}
+	feed.Services = gtfs.NewServices([]gtfs.Calendar{
+		{ServiceID: "WD", Weekdays: week, StartDate: date(t, "20250101"), EndDate: date(t, "20251231")},
+		{ServiceID: "SUN", Weekdays: [7]bool{true}, StartDate: date(t, "20250101"), EndDate: date(t, "20251231")},
+	}, nil)
+
+	// Saturday: no weekday trips, but Friday's night trip is still running.
+	idx := feed.IndexFor(date(t, "20250308"))
+	trips := tripsAt(idx, "R:1", 1)
+	if len(trips) != 1 || trips[0].TripID != "NIGHT" || trips[0].ServiceDay != -1 || trips[0].ArrivalTime != 3600 {
+		t.Errorf("Saturday trips at B = %+v, want Friday's NIGHT arriving at 01:00:00", trips)
+	}
+
+	// Monday: both weekday trips, and not Sunday's.
+	idx = feed.IndexFor(date(t, "20250310"))
+	trips = tripsAt(idx, "R:1", 1)
+	if len(trips) != 2 || trips[0].TripID != "DAY" || trips[1].TripID != "NIGHT" || trips[1].ServiceDay != 0 {
+		t.Errorf("Monday trips at B = %+v, want DAY and NIGHT", trips)
+	}
+
+	// Tuesday: Monday's night trip and Tuesday's own share a trip ID.
+	idx = feed.IndexFor(date(t, "20250311"))
+	if got := idx.Pattern("R:1").NumTrips(); got != 3 {
+		t.Errorf("Tuesday has %d trips, want 3", got)
+	}
+}
+
+func TestFeed_IndexFor_NoCalendars(t *testing.T) {
+	feed := &gtfs.Feed{
+		Trips: map[gtfs.TripID]*gtfs.Trip{"T": {ID: "T", RouteID: "R", ServiceID: "WD"}},
+		StopTimes: []gtfs.StopTime{
+			{TripID: "T", StopID: "A", ArrivalTime: 8 * 3600, DepartureTime: 8 * 3600, StopSequence: 1},
+			{TripID: "T", StopID: "B", ArrivalTime: 9 * 3600, DepartureTime: 9 * 3600, StopSequence: 2},
+		},
+		Services: gtfs.NewServices(nil, nil),
+	}
+	if trips := tripsAt(feed.IndexFor(date(t, "20250308")), "R:1", 0); len(trips) != 1 {
+		t.Errorf("trips = %+v, want T running every day", trips)
+	}
+}
+
+// tripsAt returns the times of a pattern's trips at one of its stops.
+func tripsAt(idx *gtfs.StopTimeIndex, id gtfs.PatternID, stopIndex int) []gtfs.TripStopTime {
+	p := idx.Pattern(id)
+	if p == nil {
+		return nil
+	}
+	var times []gtfs.TripStopTime
+	for i := range p.NumTrips() {
+		ev := p.Trip(i)[stopIndex]
+		times = append(times, gtfs.TripStopTime{
+			TripID:        p.TripIDs[i],
+			ServiceDay:    int(p.ServiceDays[i]),
+			ArrivalTime:   ev.Arrival,
+			DepartureTime: ev.Departure,
+		})
+	}
+	return times
+}
@@ -0,0 +1,157 @@
+package gtfs
+
+import (
+	"fmt"
+	"io"
+)
+
+type FareID string
+
+// FareAttribute is a fare_attributes.txt entry: a price and the transfers
+// it allows.
+type FareAttribute struct {
+	ID       FareID
+	Price    float64
+	Currency string // ISO 4217 code, such as BRL
+	// Transfers is the number of transfers the fare allows, or -1 for
+	// unlimited.
+	Transfers int
+	// TransferDuration is how long the fare stays valid, in seconds, or
+	// zero when the feed does not say.
+	TransferDuration int
+}
+
+// FareRule is a fare_rules.txt entry telling which trips a fare applies
+// to. Empty fields match any.
+type FareRule struct {
+	FareID        FareID
+	RouteID       RouteID
+	OriginID      string
+	DestinationID string
+	ContainsID    string
+}
+
+func ParseFareAttributes(r io.Reader) ([]FareAttribute, error) {
+	t, err := newTable(r, "fare_id", "price", "currency_type")
+	if err != nil {
+		return nil, err
+	}
+
+	var fares []FareAttribute
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f := FareAttribute{
+			ID:       FareID(t.get(record, "fare_id")),
+			Currency: t.get(record, "currency_type"),
+		}
+		price, ok, err := t.float(record, "price")
+		if err != nil {
+			return nil, err
+		}
+		if !ok || price < 0 {
+			return nil, fmt.Errorf("line %d: %w: price must be a non-negative number", t.line, ErrInvalidData)
+		}
+		f.Price = price
+
+		// An empty transfers field means unlimited transfers.
+		if f.Transfers, err = t.int(record, "transfers", -1); err != nil {
+			return nil, err
+		}
+		if f.TransferDuration, err = t.int(record, "transfer_duration", 0); err != nil {
+			return nil, err
+		}
+
+		fares = append(fares, f)
+	}
+
+	return fares, nil
+}
+
+func ParseFareAttributesFile(path string) ([]FareAttribute, error) {
+	return parseFile(path, ParseFareAttributes)
+}
+
+func ParseFareRules(r io.Reader) ([]FareRule, error) {
+	t, err := newTable(r, "fare_id")
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []FareRule
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, FareRule{
+			FareID:        FareID(t.get(record, "fare_id")),
+			RouteID:       RouteID(t.get(record, "route_id")),
+			OriginID:      t.get(record, "origin_id"),
+			DestinationID: t.get(record, "destination_id"),
+			ContainsID:    t.get(record, "contains_id"),
+		})
+	}
+
+	return rules, nil
+}
+
+func ParseFareRulesFile(path string) ([]FareRule, error) {
+	return parseFile(path, ParseFareRules)
+}
+
+// RouteFares returns the price of boarding each route: the cheapest fare
+// whose rules name the route, or that has no rules and so applies to
+// every route. Zone rules are not told apart, so routes priced by zone
+// get their cheapest fare. Routes without a fare are left out.
+func (f *Feed) RouteFares() map[RouteID]float64 {
+	prices := make(map[FareID]float64, len(f.FareAttributes))
+	for _, fare := range f.FareAttributes {
+		prices[fare.ID] = fare.Price
+	}
+
+	fares := make(map[RouteID]float64)
+	set := func(route RouteID, price float64) {
+		if p, ok := fares[route]; !ok || price < p {
+			fares[route] = price
+		}
+	}
+
+	ruled := make(map[FareID]bool)
+	for _, rule := range f.FareRules {
+		ruled[rule.FareID] = true
+		price, ok := prices[rule.FareID]
+		if !ok {
+			continue
+		}
+		if rule.RouteID != "" {
+			set(rule.RouteID, price)
+			continue
+		}
+		// A rule by zone only applies to every route.
+		for id := range f.Routes {
+			set(id, price)
+		}
+	}
+	for _, fare := range f.FareAttributes {
+		if ruled[fare.ID] {
+			continue
+		}
+		for id := range f.Routes {
+			set(id, fare.Price)
+		}
+	}
+	return fares
+}
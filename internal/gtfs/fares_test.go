@@ -0,0 +1,74 @@
+package gtfs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+)
+
+func TestParseFareAttributes(t *testing.T) {
+	input := "fare_id,price,currency_type,payment_method,transfers,transfer_duration\n" +
+		"F1,4.25,BRL,1,,\n" +
+		"F2,2,BRL,0,1,3600\n"
+
+	fares, err := gtfs.ParseFareAttributes(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseFareAttributes() error = %v", err)
+	}
+
+	want := []gtfs.FareAttribute{
+		{ID: "F1", Price: 4.25, Currency: "BRL", Transfers: -1},
+		{ID: "F2", Price: 2, Currency: "BRL", Transfers: 1, TransferDuration: 3600},
+	}
+	if len(fares) != len(want) {
+		t.Fatalf("got %d fares, want %d", len(fares), len(want))
+	}
+	for i := range want {
+		if fares[i] != want[i] {
+			t.Errorf("fare %d = %+v, want %+v", i, fares[i], want[i])
+		}
+	}
+
+	for _, input := range []string{
+		"fare_id,price,currency_type\nF1,,BRL\n",
+		"fare_id,price,currency_type\nF1,-1,BRL\n",
+		"fare_id,price,currency_type\nF1,cheap,BRL\n",
+	} {
+		if _, err := gtfs.ParseFareAttributes(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseFareAttributes(%q): expected an error", input)
+		}
+	}
+	if _, err := gtfs.ParseFareAttributes(strings.NewReader("fare_id,price\nF1,1\n")); !errors.Is(err, gtfs.ErrMissingColumn) {
+		t.Errorf("fare without currency: error = %v, want ErrMissingColumn", err)
+	}
+}
+
+func TestFeed_RouteFares(t *testing.T) {
+	feed := &gtfs.Feed{
+		Routes: map[gtfs.RouteID]*gtfs.Route{"BUS": {ID: "BUS"}, "METRO": {ID: "METRO"}, "FERRY": {ID: "FERRY"}},
+		FareAttributes: []gtfs.FareAttribute{
+			{ID: "BUS", Price: 4.30},
+			{ID: "METRO", Price: 4.25},
+			{ID: "METRO_ZONE", Price: 3},
+			{ID: "ANY", Price: 10},
+		},
+		FareRules: []gtfs.FareRule{
+			{FareID: "BUS", RouteID: "BUS"},
+			{FareID: "METRO", RouteID: "METRO"},
+			{FareID: "METRO_ZONE", RouteID: "METRO", OriginID: "Z1"},
+		},
+	}
+
+	got := feed.RouteFares()
+	want := map[gtfs.RouteID]float64{"BUS": 4.30, "METRO": 3, "FERRY": 10}
+	if len(got) != len(want) {
+		t.Fatalf("RouteFares() = %v, want %v", got, want)
+	}
+	for route, price := range want {
+		if got[route] != price {
+			t.Errorf("fare of %s = %v, want %v", route, got[route], price)
+		}
+	}
+}
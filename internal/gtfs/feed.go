@@ -0,0 +1,341 @@
+package gtfs
+
+import (
+	"fmt"
+	"io/fs"
+	stdtime "time"
+
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+type AgencyID string
+
+// Agency is a transit operator from agency.txt.
+type Agency struct {
+	ID       AgencyID
+	Name     string
+	URL      string
+	Timezone string // IANA name, such as America/Recife
+	Lang     string
+	Phone    string
+}
+
+// Location loads the agency's time zone.
+func (a Agency) Location() (*stdtime.Location, error) {
+	return stdtime.LoadLocation(a.Timezone)
+}
+
+// LocationType is the kind of place a stops.txt entry describes.
+type LocationType int
+
+const (
+	LocationStop         LocationType = 0 // a stop or platform
+	LocationStation      LocationType = 1 // a station grouping platforms
+	LocationEntrance     LocationType = 2 // a station entrance or exit
+	LocationGenericNode  LocationType = 3 // a pathway node inside a station
+	LocationBoardingArea LocationType = 4 // a place on a platform
+)
+
+// WheelchairBoarding tells whether wheelchair users can board at a stop.
+type WheelchairBoarding int
+
+const (
+	WheelchairUnknown      WheelchairBoarding = 0 // inherited from the parent station, if any
+	WheelchairAccessible   WheelchairBoarding = 1
+	WheelchairInaccessible WheelchairBoarding = 2
+)
+
+// Stop is a stops.txt entry. Lat and Lon may be zero for generic nodes and
+// boarding areas, which are located by their parent station.
+type Stop struct {
+	ID                 StopID
+	Code               string
+	Name               string
+	Lat, Lon           float64
+	LocationType       LocationType
+	ParentStation      StopID
+	WheelchairBoarding WheelchairBoarding
+	ZoneID             string
+	PlatformCode       string
+}
+
+// RouteType is the route_type of routes.txt: a basic GTFS value such as
+// RouteBus or an extended one in the hundreds, such as 700 for bus service.
+type RouteType int
+
+const (
+	RouteTram       RouteType = 0
+	RouteSubway     RouteType = 1
+	RouteRail       RouteType = 2
+	RouteBus        RouteType = 3
+	RouteFerry      RouteType = 4
+	RouteCableTram  RouteType = 5
+	RouteAerialLift RouteType = 6
+	RouteFunicular  RouteType = 7
+	RouteTrolleybus RouteType = 11
+	RouteMonorail   RouteType = 12
+)
+
+var routeModes = map[RouteType]string{
+	RouteTram:       "tram",
+	RouteSubway:     "subway",
+	RouteRail:       "rail",
+	RouteBus:        "bus",
+	RouteFerry:      "ferry",
+	RouteCableTram:  "cable_tram",
+	RouteAerialLift: "aerial_lift",
+	RouteFunicular:  "funicular",
+	RouteTrolleybus: "trolleybus",
+	RouteMonorail:   "monorail",
+}
+
+// Extended route types, grouped by hundreds.
+var extendedModes = map[int]string{
+	1:  "rail",
+	2:  "coach",
+	4:  "subway",
+	5:  "subway",
+	6:  "subway",
+	7:  "bus",
+	8:  "trolleybus",
+	9:  "tram",
+	10: "ferry",
+	11: "air",
+	12: "ferry",
+	13: "aerial_lift",
+	14: "funicular",
+	15: "taxi",
+	17: "other",
+}
+
+// Mode returns a short lowercase name for the kind of vehicle, such as
+// "bus" or "subway", or "unknown".
+func (t RouteType) Mode() string {
+	if mode, ok := routeModes[t]; ok {
+		return mode
+	}
+	if mode, ok := extendedModes[int(t)/100]; ok && t >= 100 {
+		return mode
+	}
+	return "unknown"
+}
+
+// Route is a routes.txt entry. Colors are six hex digits without '#', or
+// empty when the feed gives none.
+type Route struct {
+	ID        RouteID
+	AgencyID  AgencyID
+	ShortName string
+	LongName  string
+	Type      RouteType
+	Color     string
+	TextColor string
+}
+
+// Name returns the name riders know the route by: the short name when
+// there is one, such as a line number, and otherwise the long name.
+func (r Route) Name() string {
+	switch {
+	case r.ShortName != "":
+		return r.ShortName
+	case r.LongName != "":
+		return r.LongName
+	}
+	return string(r.ID)
+}
+
+// Feed is a parsed GTFS feed.
+type Feed struct {
+	Agencies      []Agency
+	Stops         map[StopID]*Stop
+	Routes        map[RouteID]*Route
+	Trips         map[TripID]*Trip
+	StopTimes     []StopTime
+	Frequencies   []Frequency
+	Transfers     []Transfer
+	Calendars     []Calendar
+	CalendarDates []CalendarDate
+	// FareAttributes and FareRules price the trips, when the feed has them.
+	FareAttributes []FareAttribute
+	FareRules      []FareRule
+	// Services is built from Calendars and CalendarDates.
+	Services *Services
+}
+
+// Files of a feed. stop_times.txt and trips.txt are required; without the
+// others stops and routes are known by their IDs only, and without either
+// calendar file every trip runs every day.
+const (
+	AgencyFile         = "agency.txt"
+	StopsFile          = "stops.txt"
+	RoutesFile         = "routes.txt"
+	TripsFile          = "trips.txt"
+	StopTimesFile      = "stop_times.txt"
+	TransfersFile      = "transfers.txt"
+	FrequenciesFile    = "frequencies.txt"
+	CalendarFile       = "calendar.txt"
+	CalendarDatesFile  = "calendar_dates.txt"
+	FareAttributesFile = "fare_attributes.txt"
+	FareRulesFile      = "fare_rules.txt"
+)
+
+// ReadFeed parses the GTFS files in fsys, such as a directory or an
+// opened zip archive. Feeds packed inside a folder of the archive are
+// found there.
+func ReadFeed(fsys fs.FS) (*Feed, error) {
+	fsys, err := feedRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{
+		Stops:  make(map[StopID]*Stop),
+		Routes: make(map[RouteID]*Route),
+		Trips:  make(map[TripID]*Trip),
+	}
+
+	if feed.StopTimes, err = parseRequired(fsys, StopTimesFile, ParseStopTimes); err != nil {
+		return nil, err
+	}
+	trips, err := parseRequired(fsys, TripsFile, ParseTrips)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trips {
+		feed.Trips[trips[i].ID] = &trips[i]
+	}
+
+	if feed.Agencies, err = parseOptional(fsys, AgencyFile, ParseAgencies); err != nil {
+		return nil, err
+	}
+
+	stops, err := parseOptional(fsys, StopsFile, ParseStops)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stops {
+		feed.Stops[stops[i].ID] = &stops[i]
+	}
+
+	routes, err := parseOptional(fsys, RoutesFile, ParseRoutes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes {
+		feed.Routes[routes[i].ID] = &routes[i]
+	}
+
+	if feed.Frequencies, err = parseOptional(fsys, FrequenciesFile, ParseFrequencies); err != nil {
+		return nil, err
+	}
+	if feed.Transfers, err = parseOptional(fsys, TransfersFile, ParseTransfers); err != nil {
+		return nil, err
+	}
+	if feed.Calendars, err = parseOptional(fsys, CalendarFile, ParseCalendar); err != nil {
+		return nil, err
+	}
+	if feed.CalendarDates, err = parseOptional(fsys, CalendarDatesFile, ParseCalendarDates); err != nil {
+		return nil, err
+	}
+	feed.Services = NewServices(feed.Calendars, feed.CalendarDates)
+
+	if feed.FareAttributes, err = parseOptional(fsys, FareAttributesFile, ParseFareAttributes); err != nil {
+		return nil, err
+	}
+	if feed.FareRules, err = parseOptional(fsys, FareRulesFile, ParseFareRules); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// TripRoutes maps each trip to its route.
+func (f *Feed) TripRoutes() TripToRoute {
+	routes := make(TripToRoute, len(f.Trips))
+	for id, t := range f.Trips {
+		routes[id] = t.RouteID
+	}
+	return routes
+}
+
+// Runs reports whether a trip runs on service day d. Every trip runs every
+// day in feeds without calendars.
+func (f *Feed) Runs(trip TripID, d Date) bool {
+	t, ok := f.Trips[trip]
+	if !ok {
+		return false
+	}
+	return f.Services.Empty() || f.Services.Runs(t.ServiceID, d)
+}
+
+// IndexFor indexes the trips running on service day d for RAPTOR, with
+// times counted from the start of d. Trips of the previous day still
+// running after midnight, at times past 24:00:00, are included with their
+// times shifted back by a day.
+func (f *Feed) IndexFor(d Date) *StopTimeIndex {
+	frequencies := groupFrequencies(f.Frequencies)
+
+	// A trip runs past midnight when its last arrival does, or for
+	// frequency-based trips when its last run does.
+	end := make(map[TripID]time.Time)
+	first := make(map[TripID]time.Time)
+	for _, st := range f.StopTimes {
+		end[st.TripID] = max(end[st.TripID], st.ArrivalTime)
+		if t, ok := first[st.TripID]; !ok || st.DepartureTime < t {
+			first[st.TripID] = st.DepartureTime
+		}
+	}
+	for id, windows := range frequencies {
+		duration := end[id] - first[id]
+		for _, w := range windows {
+			end[id] = max(end[id], w.EndTime+duration)
+		}
+	}
+
+	yesterday := d.AddDays(-1)
+	overnight := func(id TripID) bool {
+		return end[id] >= SecondsPerDay && f.Runs(id, yesterday)
+	}
+
+	tripStops := make(map[tripRun][]StopTime)
+	groupTrips(tripStops, f.StopTimes, 0, func(id TripID) bool { return f.Runs(id, d) })
+	groupTrips(tripStops, f.StopTimes, -1, overnight)
+	return buildIndex(tripStops, f.TripRoutes(), frequencies)
+}
+
+// Location returns the feed's time zone, that of its agencies, in which
+// stop times are given.
+func (f *Feed) Location() (*stdtime.Location, error) {
+	if len(f.Agencies) == 0 {
+		return nil, fmt.Errorf("%w: no agency gives the feed's time zone", ErrInvalidData)
+	}
+	return f.Agencies[0].Location()
+}
+
+// StopName returns the stop's name, or its ID when the feed has none.
+func (f *Feed) StopName(id StopID) string {
+	if s, ok := f.Stops[id]; ok && s.Name != "" {
+		return s.Name
+	}
+	return string(id)
+}
+
+// Route returns the route of a trip, or nil when either is unknown.
+func (f *Feed) Route(trip TripID) *Route {
+	t, ok := f.Trips[trip]
+	if !ok {
+		return nil
+	}
+	return f.Routes[t.RouteID]
+}
+
+// Agency returns the route's agency. Feeds with a single agency may leave
+// agency_id out.
+func (f *Feed) Agency(r *Route) *Agency {
+	for i := range f.Agencies {
+		if f.Agencies[i].ID == r.AgencyID || len(f.Agencies) == 1 {
+			return &f.Agencies[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package gtfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+)
+
+func TestParseStops(t *testing.T) {
+	csvData := `stop_id,stop_name,stop_lat,stop_lon,location_type,parent_station,wheelchair_boarding
+STATION,Central,-8.1,-34.9,1,,1
+P1,Central Platform 1,-8.1001,-34.9001,0,STATION,
+NODE,Stairs,,,3,STATION,2
+`
+	stops, err := gtfs.ParseStops(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ParseStops() error = %v", err)
+	}
+	if len(stops) != 3 {
+		t.Fatalf("expected 3 stops, got %d", len(stops))
+	}
+
+	station, platform, node := stops[0], stops[1], stops[2]
+	if station.Name != "Central" || station.Lat != -8.1 || station.Lon != -34.9 {
+		t.Errorf("station = %+v", station)
+	}
+	if station.LocationType != gtfs.LocationStation || station.WheelchairBoarding != gtfs.WheelchairAccessible {
+		t.Errorf("station type, wheelchair = %d, %d", station.LocationType, station.WheelchairBoarding)
+	}
+	if platform.ParentStation != "STATION" || platform.WheelchairBoarding != gtfs.WheelchairUnknown {
+		t.Errorf("platform = %+v", platform)
+	}
+	if node.LocationType != gtfs.LocationGenericNode || node.WheelchairBoarding != gtfs.WheelchairInaccessible {
+		t.Errorf("node = %+v", node)
+	}
+}
+
+func TestParseStops_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		csvData string
+		wantErr error
+	}{
+		{"missing stop_id", "stop_name\nCentral\n", gtfs.ErrMissingColumn},
+		{"stop without coordinates", "stop_id,stop_lat,stop_lon\nA,,\n", gtfs.ErrInvalidData},
+		{"bad latitude", "stop_id,stop_lat,stop_lon\nA,north,1\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gtfs.ParseStops(strings.NewReader(tt.csvData))
+			if err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	csvData := `route_id,agency_id,route_short_name,route_long_name,route_type,route_color
+R1,A,42,Harbour - Airport,3,F7A600
+R2,A,,Airport Express,2,
+R3,A,,,715,
+`
+	routes, err := gtfs.ParseRoutes(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ParseRoutes() error = %v", err)
+	}
+
+	tests := []struct {
+		name, mode, color string
+	}{
+		{"42", "bus", "F7A600"},
+		{"Airport Express", "rail", ""},
+		{"R3", "bus", ""},
+	}
+	for i, tt := range tests {
+		r := routes[i]
+		if r.Name() != tt.name || r.Type.Mode() != tt.mode || r.Color != tt.color {
+			t.Errorf("route %s: name, mode, color = %q, %q, %q, want %q, %q, %q",
+				r.ID, r.Name(), r.Type.Mode(), r.Color, tt.name, tt.mode, tt.color)
+		}
+	}
+
+	if _, err := gtfs.ParseRoutes(strings.NewReader("route_id,route_type\nR1,\n")); !errors.Is(err, gtfs.ErrInvalidData) {
+		t.Errorf("route without route_type: error = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestParseAgencies(t *testing.T) {
+	csvData := "agency_name,agency_url,agency_timezone\nMetro,https://example.com,America/Recife\n"
+	agencies, err := gtfs.ParseAgencies(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ParseAgencies() error = %v", err)
+	}
+	if len(agencies) != 1 || agencies[0].Name != "Metro" || agencies[0].Timezone != "America/Recife" {
+		t.Errorf("agencies = %+v", agencies)
+	}
+
+	if _, err := gtfs.ParseAgencies(strings.NewReader("agency_name\nMetro\n")); !errors.Is(err, gtfs.ErrMissingColumn) {
+		t.Errorf("agency without timezone: error = %v, want ErrMissingColumn", err)
+	}
+}
+
+func TestLoadFeed(t *testing.T) {
+	feed, err := gtfs.LoadFeed("../../examples/gtfs")
+	if err != nil {
+		t.Fatalf("LoadFeed() error = %v", err)
+	}
+
+	if len(feed.StopTimes) == 0 || len(feed.Trips) == 0 || len(feed.Transfers) == 0 {
+		t.Errorf("feed has %d stop times, %d trips, %d transfers", len(feed.StopTimes), len(feed.Trips), len(feed.Transfers))
+	}
+	if got := feed.StopName("TI_JOANA_BEZERRA"); got != "Terminal Integrado Joana Bezerra" {
+		t.Errorf("StopName(TI_JOANA_BEZERRA) = %q", got)
+	}
+	if got := feed.StopName("NOWHERE"); got != "NOWHERE" {
+		t.Errorf("StopName of an unknown stop = %q, want its ID", got)
+	}
+
+	route := feed.Route("ML1_T01")
+	if route == nil || route.ID != "METRO_LINHA_1" || route.Type.Mode() != "subway" {
+		t.Fatalf("Route(ML1_T01) = %+v", route)
+	}
+	agency := feed.Agency(route)
+	if agency == nil || agency.Timezone != "America/Recife" {
+		t.Errorf("Agency = %+v", agency)
+	}
+
+	if fares := feed.RouteFares(); fares["METRO_SUL"] != 4.25 || fares["BRT_NORTE_SUL"] != 4.30 {
+		t.Errorf("RouteFares() = %v", fares)
+	}
+
+	for _, st := range feed.StopTimes {
+		if _, ok := feed.Stops[st.StopID]; !ok {
+			t.Errorf("stop %s of trip %s is missing from stops.txt", st.StopID, st.TripID)
+		}
+	}
+}
+
+func TestLoadFeed_OptionalFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "stop_times.txt", "trip_id,stop_id,arrival_time,departure_time,stop_sequence\nT1,A,08:00:00,08:00:00,1\n")
+	writeFile(t, dir, "trips.txt", "trip_id,route_id\nT1,R1\n")
+
+	feed, err := gtfs.LoadFeed(dir)
+	if err != nil {
+		t.Fatalf("LoadFeed() error = %v", err)
+	}
+	if len(feed.Stops) != 0 || len(feed.Routes) != 0 || feed.Route("T1") != nil {
+		t.Errorf("feed without stops.txt and routes.txt = %+v", feed)
+	}
+
+	if _, err := gtfs.LoadFeed(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without stop_times.txt")
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,288 @@
+package gtfs
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// Frequency is a frequencies.txt entry: the trip runs every Headway
+// seconds, starting from its first stop between StartTime and EndTime.
+// The trip's stop times then only give the time between stops.
+type Frequency struct {
+	TripID    TripID
+	StartTime time.Time
+	EndTime   time.Time
+	Headway   int // seconds
+	// ExactTimes is set for schedules that run exactly at StartTime plus
+	// multiples of Headway. Otherwise only the headway is promised.
+	ExactTimes bool
+}
+
+func ParseFrequencies(r io.Reader) ([]Frequency, error) {
+	t, err := newTable(r, "trip_id", "start_time", "end_time", "headway_secs")
+	if err != nil {
+		return nil, err
+	}
+
+	var frequencies []Frequency
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f := Frequency{TripID: TripID(t.get(record, "trip_id"))}
+		if f.StartTime, err = time.ParseTime(t.get(record, "start_time")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid start_time: %w", t.line, err)
+		}
+		if f.EndTime, err = time.ParseTime(t.get(record, "end_time")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid end_time: %w", t.line, err)
+		}
+		if f.Headway, err = t.int(record, "headway_secs", 0); err != nil {
+			return nil, err
+		}
+		if f.Headway <= 0 {
+			return nil, fmt.Errorf("line %d: %w: headway_secs must be positive", t.line, ErrInvalidData)
+		}
+
+		exact, err := t.int(record, "exact_times", 0)
+		if err != nil {
+			return nil, err
+		}
+		f.ExactTimes = exact == 1
+
+		frequencies = append(frequencies, f)
+	}
+
+	return frequencies, nil
+}
+
+func ParseFrequenciesFile(path string) ([]Frequency, error) {
+	return parseFile(path, ParseFrequencies)
+}
+
+func groupFrequencies(frequencies []Frequency) map[TripID][]Frequency {
+	byTrip := make(map[TripID][]Frequency)
+	for _, f := range frequencies {
+		byTrip[f.TripID] = append(byTrip[f.TripID], f)
+	}
+	return byTrip
+}
+
+// FrequencyTrip is a trip of a pattern that runs once per headway. Rather
+// than storing every run, it keeps the trip's times as a template and its
+// frequencies, from which EarliestBoarding finds the next run.
+type FrequencyTrip struct {
+	TripID     TripID
+	ServiceDay int // see TripStopTime.ServiceDay
+	// Times holds the template's times at each stop of the pattern.
+	Times []StopEvent
+	// Windows are sorted by start time and shifted like Times for trips of
+	// the previous service day.
+	Windows []Frequency
+}
+
+func newFrequencyTrip(times []TripStopTime, windows []Frequency, day int) FrequencyTrip {
+	f := FrequencyTrip{TripID: times[0].TripID, ServiceDay: day}
+	for _, st := range times {
+		f.Times = append(f.Times, StopEvent{Arrival: st.ArrivalTime, Departure: st.DepartureTime})
+	}
+	shift := time.Time(day * SecondsPerDay)
+	for _, w := range windows {
+		w.StartTime += shift
+		w.EndTime += shift
+		f.Windows = append(f.Windows, w)
+	}
+	sort.Slice(f.Windows, func(i, j int) bool { return f.Windows[i].StartTime < f.Windows[j].StartTime })
+	return f
+}
+
+func (f *FrequencyTrip) key() tripKey {
+	return tripKey{departure: f.Times[0].Departure, day: f.ServiceDay, trip: f.TripID}
+}
+
+// nextStart returns the start time, at the first stop, of the earliest run
+// leaving stop index i at or after t.
+//
+// Runs of exact schedules leave at their scheduled times. For the others
+// the rider is assumed to wait a full headway, since the vehicles' times
+// are not published; a plan then never counts on a vehicle that may not
+// come.
+func (f *FrequencyTrip) nextStart(i int, t time.Time) (time.Time, bool) {
+	offset := f.Times[i].Departure - f.Times[0].Departure
+	// Latest start time that still reaches stop i after t.
+	earliest := t - offset
+
+	for _, w := range f.Windows {
+		if w.EndTime <= earliest {
+			continue
+		}
+		headway := time.Time(w.Headway)
+		if w.ExactTimes {
+			start := w.StartTime
+			if earliest > start {
+				runs := (earliest - start + headway - 1) / headway
+				start += runs * headway
+			}
+			if start < w.EndTime {
+				return start, true
+			}
+			continue
+		}
+		return max(earliest, w.StartTime) + headway, true
+	}
+	return 0, false
+}
+
+// prevStart returns the start time, at the first stop, of the latest run
+// reaching stop index i at or before t. It mirrors nextStart: for
+// schedules without exact times the rider is assumed to need a full
+// headway to spare.
+func (f *FrequencyTrip) prevStart(i int, t time.Time) (time.Time, bool) {
+	offset := f.Times[i].Arrival - f.Times[0].Departure
+	// Latest start time that still reaches stop i by t.
+	latest := t - offset
+
+	for k := len(f.Windows) - 1; k >= 0; k-- {
+		w := f.Windows[k]
+		headway := time.Time(w.Headway)
+		start := latest
+		if !w.ExactTimes {
+			start -= headway
+		}
+		if start < w.StartTime {
+			continue
+		}
+		start = min(start, w.EndTime-1)
+		if w.ExactTimes {
+			start = w.StartTime + (start-w.StartTime)/headway*headway
+		}
+		return start, true
+	}
+	return 0, false
+}
+
+// Boarding is a run of a trip boarded on a pattern: its times at each stop
+// of the pattern are Times shifted by Shift.
+type Boarding struct {
+	Trip       TripID
+	ServiceDay int
+	Times      []StopEvent
+	Shift      time.Time
+}
+
+func (b *Boarding) Arrival(stopIndex int) time.Time {
+	return b.Times[stopIndex].Arrival + b.Shift
+}
+
+func (b *Boarding) Departure(stopIndex int) time.Time {
+	return b.Times[stopIndex].Departure + b.Shift
+}
+
+// EarliestBoarding returns the run of a scheduled or frequency-based trip
+// of pattern number pattern leaving stop index stopIndex first at or after
+// minDepartureTime.
+func (idx *StopTimeIndex) EarliestBoarding(pattern int, stopIndex int, minDepartureTime time.Time) (Boarding, bool) {
+	p := &idx.Patterns[pattern]
+
+	var best Boarding
+	found := false
+	if t := idx.EarliestTripIndex(pattern, stopIndex, minDepartureTime); t != -1 {
+		best = Boarding{Trip: p.TripIDs[t], ServiceDay: int(p.ServiceDays[t]), Times: p.Trip(t)}
+		found = true
+	}
+
+	for k := range p.Frequencies {
+		f := &p.Frequencies[k]
+		start, ok := f.nextStart(stopIndex, minDepartureTime)
+		if !ok {
+			continue
+		}
+		b := Boarding{Trip: f.TripID, ServiceDay: f.ServiceDay, Times: f.Times, Shift: start - f.Times[0].Departure}
+		if !found || b.Departure(stopIndex) < best.Departure(stopIndex) {
+			best, found = b, true
+		}
+	}
+	return best, found
+}
+
+// LatestAlighting returns the run of a scheduled or frequency-based trip
+// of pattern number pattern arriving at stop index stopIndex last at or
+// before maxArrivalTime. It is EarliestBoarding for searches running
+// backwards in time.
+func (idx *StopTimeIndex) LatestAlighting(pattern int, stopIndex int, maxArrivalTime time.Time) (Boarding, bool) {
+	p := &idx.Patterns[pattern]
+
+	var best Boarding
+	found := false
+	if t := idx.LatestTripIndex(pattern, stopIndex, maxArrivalTime); t != -1 {
+		best = Boarding{Trip: p.TripIDs[t], ServiceDay: int(p.ServiceDays[t]), Times: p.Trip(t)}
+		found = true
+	}
+
+	for k := range p.Frequencies {
+		f := &p.Frequencies[k]
+		start, ok := f.prevStart(stopIndex, maxArrivalTime)
+		if !ok {
+			continue
+		}
+		b := Boarding{Trip: f.TripID, ServiceDay: f.ServiceDay, Times: f.Times, Shift: start - f.Times[0].Departure}
+		if !found || b.Arrival(stopIndex) > best.Arrival(stopIndex) {
+			best, found = b, true
+		}
+	}
+	return best, found
+}
+
+// DeparturesAt returns the distinct times trips leave stop number s from
+// from to to inclusive, in order. Frequency-based trips are taken to leave
+// at the start of their window and every headway after, whether or not
+// their times are exact.
+func (idx *StopTimeIndex) DeparturesAt(s int, from, to time.Time) []time.Time {
+	var times []time.Time
+	for _, ps := range idx.PatternsAtStop(s) {
+		p := &idx.Patterns[ps.Pattern]
+		i := int(ps.Index)
+		if i == len(p.Stops)-1 {
+			// Trips end here.
+			continue
+		}
+
+		if t := idx.EarliestTripIndex(int(ps.Pattern), i, from); t != -1 {
+			for ; t < p.NumTrips(); t++ {
+				dep := p.Trip(t)[i].Departure
+				if dep > to {
+					break
+				}
+				times = append(times, dep)
+			}
+		}
+
+		for _, f := range p.Frequencies {
+			offset := f.Times[i].Departure - f.Times[0].Departure
+			for _, w := range f.Windows {
+				for start := w.StartTime; start < w.EndTime; start += time.Time(w.Headway) {
+					if dep := start + offset; dep >= from && dep <= to {
+						times = append(times, dep)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	distinct := times[:0]
+	for i, t := range times {
+		if i == 0 || t != times[i-1] {
+			distinct = append(distinct, t)
+		}
+	}
+	return distinct
+}
@@ -0,0 +1,194 @@
+package gtfs_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+func TestParseFrequencies(t *testing.T) {
+	csvData := `trip_id,start_time,end_time,headway_secs,exact_times
+BRT,06:00:00,09:00:00,300,1
+BRT,09:00:00,22:00:00,600,
+`
+	frequencies, err := gtfs.ParseFrequencies(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ParseFrequencies() error = %v", err)
+	}
+	want := []gtfs.Frequency{
+		{TripID: "BRT", StartTime: 6 * 3600, EndTime: 9 * 3600, Headway: 300, ExactTimes: true},
+		{TripID: "BRT", StartTime: 9 * 3600, EndTime: 22 * 3600, Headway: 600},
+	}
+	if len(frequencies) != len(want) {
+		t.Fatalf("got %d frequencies, want %d", len(frequencies), len(want))
+	}
+	for i := range want {
+		if frequencies[i] != want[i] {
+			t.Errorf("frequencies[%d] = %+v, want %+v", i, frequencies[i], want[i])
+		}
+	}
+
+	if _, err := gtfs.ParseFrequencies(strings.NewReader("trip_id,start_time,end_time,headway_secs\nBRT,06:00:00,09:00:00,0\n")); err == nil {
+		t.Error("expected an error for a zero headway")
+	}
+}
+
+// frequencyIndex indexes a route A - B - C whose template trip takes ten
+// minutes to B, with the given frequencies, next to a scheduled trip.
+func frequencyIndex(frequencies ...gtfs.Frequency) *gtfs.StopTimeIndex {
+	stopTimes := []gtfs.StopTime{
+		// The template's own times do not matter, only their differences.
+		{TripID: "F", StopID: "A", ArrivalTime: 0, DepartureTime: 0, StopSequence: 1},
+		{TripID: "F", StopID: "B", ArrivalTime: 600, DepartureTime: 660, StopSequence: 2},
+		{TripID: "F", StopID: "C", ArrivalTime: 1200, DepartureTime: 1200, StopSequence: 3},
+		{TripID: "S", StopID: "A", ArrivalTime: 7*3600 + 100, DepartureTime: 7*3600 + 100, StopSequence: 1},
+		{TripID: "S", StopID: "B", ArrivalTime: 7*3600 + 700, DepartureTime: 7*3600 + 760, StopSequence: 2},
+		{TripID: "S", StopID: "C", ArrivalTime: 7*3600 + 1300, DepartureTime: 7*3600 + 1300, StopSequence: 3},
+	}
+	return gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"F": "R", "S": "R"}, frequencies...)
+}
+
+func TestEarliestBoarding(t *testing.T) {
+	exact := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 8 * 3600, Headway: 900, ExactTimes: true})
+	inexact := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 8 * 3600, Headway: 900})
+
+	tests := []struct {
+		name      string
+		idx       *gtfs.StopTimeIndex
+		stopIndex int
+		at        time.Time
+		wantTrip  gtfs.TripID
+		wantDep   time.Time
+		wantArr   time.Time // at C
+	}{
+		{"before service", exact, 0, 5 * 3600, "F", 6 * 3600, 6*3600 + 1200},
+		{"on a run", exact, 0, 6*3600 + 900, "F", 6*3600 + 900, 6*3600 + 2100},
+		{"between runs", exact, 0, 6*3600 + 901, "F", 6*3600 + 1800, 6*3600 + 3000},
+		{"downstream stop", exact, 1, 6*3600 + 661, "F", 6*3600 + 900 + 660, 6*3600 + 2100},
+		{"scheduled trip is earlier", exact, 0, 7*3600 + 1, "S", 7*3600 + 100, 7*3600 + 1300},
+		{"after the last run", exact, 0, 8 * 3600, "", 0, 0},
+		{"inexact waits a headway", inexact, 0, 6*3600 + 60, "F", 6*3600 + 960, 6*3600 + 2160},
+		{"inexact before service", inexact, 0, 5 * 3600, "F", 6*3600 + 900, 6*3600 + 2100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ok := tt.idx.EarliestBoarding(0, tt.stopIndex, tt.at)
+			if tt.wantTrip == "" {
+				if ok {
+					t.Errorf("EarliestBoarding() = %s at %s, want none", b.Trip, b.Departure(tt.stopIndex))
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("EarliestBoarding() found no trip")
+			}
+			if b.Trip != tt.wantTrip || b.Departure(tt.stopIndex) != tt.wantDep || b.Arrival(2) != tt.wantArr {
+				t.Errorf("EarliestBoarding() = %s leaving %s, reaching C %s; want %s leaving %s, reaching C %s",
+					b.Trip, b.Departure(tt.stopIndex), b.Arrival(2), tt.wantTrip, tt.wantDep, tt.wantArr)
+			}
+		})
+	}
+}
+
+func TestLatestAlighting(t *testing.T) {
+	exact := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 8 * 3600, Headway: 900, ExactTimes: true})
+	inexact := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 8 * 3600, Headway: 900})
+
+	tests := []struct {
+		name      string
+		idx       *gtfs.StopTimeIndex
+		stopIndex int
+		at        time.Time
+		wantTrip  gtfs.TripID
+		wantDep   time.Time // at A
+		wantArr   time.Time
+	}{
+		{"after service", exact, 2, 9 * 3600, "F", 7*3600 + 2700, 8*3600 + 300},
+		{"on a run", exact, 2, 6*3600 + 2100, "F", 6*3600 + 900, 6*3600 + 2100},
+		{"between runs", exact, 2, 6*3600 + 2099, "F", 6 * 3600, 6*3600 + 1200},
+		{"upstream stop", exact, 1, 6*3600 + 1500, "F", 6*3600 + 900, 6*3600 + 1500},
+		{"scheduled trip is later", exact, 2, 7*3600 + 1300, "S", 7*3600 + 100, 7*3600 + 1300},
+		{"before the first run", exact, 2, 6*3600 + 1199, "", 0, 0},
+		{"inexact keeps a headway", inexact, 2, 6*3600 + 2100, "F", 6 * 3600, 6*3600 + 1200},
+		{"inexact before service", inexact, 2, 6*3600 + 2099, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ok := tt.idx.LatestAlighting(0, tt.stopIndex, tt.at)
+			if tt.wantTrip == "" {
+				if ok {
+					t.Errorf("LatestAlighting() = %s at %s, want none", b.Trip, b.Arrival(tt.stopIndex))
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("LatestAlighting() found no trip")
+			}
+			if b.Trip != tt.wantTrip || b.Departure(0) != tt.wantDep || b.Arrival(tt.stopIndex) != tt.wantArr {
+				t.Errorf("LatestAlighting() = %s leaving A %s, arriving %s; want %s leaving A %s, arriving %s",
+					b.Trip, b.Departure(0), b.Arrival(tt.stopIndex), tt.wantTrip, tt.wantDep, tt.wantArr)
+			}
+		})
+	}
+}
+
+func TestBuildIndex_FrequencyTripsAreNotScheduled(t *testing.T) {
+	idx := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 8 * 3600, Headway: 900})
+	pattern := idx.Pattern("R:1")
+	if got := pattern.NumTrips(); got != 1 {
+		t.Errorf("pattern has %d scheduled trips, want only S", got)
+	}
+	if got := len(pattern.Frequencies); got != 1 {
+		t.Errorf("pattern has %d frequency-based trips, want 1", got)
+	}
+	if trip := idx.EarliestTrip("R:1", 0, 0); trip == nil || trip.TripID != "S" {
+		t.Errorf("EarliestTrip() = %+v, want the scheduled trip S", trip)
+	}
+}
+
+func TestFeed_IndexFor_OvernightFrequencies(t *testing.T) {
+	feed := &gtfs.Feed{
+		Trips: map[gtfs.TripID]*gtfs.Trip{"F": {ID: "F", RouteID: "R", ServiceID: "FRI"}},
+		StopTimes: []gtfs.StopTime{
+			{TripID: "F", StopID: "A", ArrivalTime: 0, DepartureTime: 0, StopSequence: 1},
+			{TripID: "F", StopID: "B", ArrivalTime: 600, DepartureTime: 600, StopSequence: 2},
+		},
+		Frequencies: []gtfs.Frequency{{TripID: "F", StartTime: 22 * 3600, EndTime: 26 * 3600, Headway: 1800, ExactTimes: true}},
+	}
+	feed.Services = gtfs.NewServices([]gtfs.Calendar{
+		{ServiceID: "FRI", Weekdays: [7]bool{5: true}, StartDate: date(t, "20250101"), EndDate: date(t, "20251231")},
+	}, nil)
+
+	// Saturday 01:10 is Friday's 25:10; the next run leaves at 25:30.
+	idx := feed.IndexFor(date(t, "20250308"))
+	b, ok := idx.EarliestBoarding(0, 0, 3600+600)
+	if !ok || b.Departure(0) != 3600+1800 || b.ServiceDay != -1 {
+		t.Errorf("EarliestBoarding() = %+v, %v, want Friday's run at 01:30:00", b, ok)
+	}
+	if _, ok := idx.EarliestBoarding(0, 0, 2*3600); ok {
+		t.Error("Friday's service ends at 02:00:00")
+	}
+}
+
+func TestDeparturesAt(t *testing.T) {
+	idx := frequencyIndex(gtfs.Frequency{TripID: "F", StartTime: 6 * 3600, EndTime: 7 * 3600, Headway: 1200, ExactTimes: true})
+
+	a, _ := idx.StopNumber("A")
+	got := idx.DeparturesAt(a, 6*3600+1, 7*3600+100)
+	want := []time.Time{6*3600 + 1200, 6*3600 + 2400, 7*3600 + 100}
+	if len(got) != len(want) {
+		t.Fatalf("DeparturesAt(A) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("DeparturesAt(A)[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	c, _ := idx.StopNumber("C")
+	if got := idx.DeparturesAt(c, 0, 24*3600); len(got) != 0 {
+		t.Errorf("DeparturesAt(C) = %v, want none at the last stop", got)
+	}
+}
@@ -11,63 +11,141 @@ import (
 	"github.com/danielscoffee/pathcraft/internal/time"
 )
 
-func ParseStopTimes(r io.Reader) ([]StopTime, error) {
+// table reads a GTFS CSV file, locating columns by header name so that
+// files may order and extend their columns freely.
+type table struct {
+	r    *csv.Reader
+	cols map[string]int
+	line int
+}
+
+func newTable(r io.Reader, required ...string) (*table, error) {
 	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
 
 	header, err := csvReader.Read()
 	if err != nil {
 		return nil, fmt.Errorf("reading header: %w", err)
 	}
 
-	colIndex := make(map[string]int)
+	t := &table{r: csvReader, cols: make(map[string]int), line: 1}
 	for i, col := range header {
-		colIndex[strings.TrimSpace(col)] = i
+		if i == 0 {
+			// Spreadsheet exports often start with a UTF-8 byte order mark.
+			col = strings.TrimPrefix(col, "\ufeff")
+		}
+		t.cols[strings.TrimSpace(col)] = i
 	}
 
-	requiredCols := []string{"trip_id", "stop_id", "arrival_time", "departure_time", "stop_sequence"}
-	for _, col := range requiredCols {
-		if _, ok := colIndex[col]; !ok {
+	for _, col := range required {
+		if _, ok := t.cols[col]; !ok {
 			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, col)
 		}
 	}
+	return t, nil
+}
 
-	tripIdx := colIndex["trip_id"]
-	stopIdx := colIndex["stop_id"]
-	arrivalIdx := colIndex["arrival_time"]
-	departureIdx := colIndex["departure_time"]
-	seqIdx := colIndex["stop_sequence"]
+// next returns the next record, or io.EOF after the last one.
+func (t *table) next() ([]string, error) {
+	record, err := t.r.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	t.line++
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", t.line, err)
+	}
+	return record, nil
+}
+
+// has reports whether the file has the column.
+func (t *table) has(col string) bool {
+	_, ok := t.cols[col]
+	return ok
+}
+
+// get returns the trimmed value of col, or "" when the column is absent
+// or the record is short.
+func (t *table) get(record []string, col string) string {
+	i, ok := t.cols[col]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// int parses col as an integer, returning def when it is empty.
+func (t *table) int(record []string, col string, def int) (int, error) {
+	v := t.get(record, col)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid %s: %w", t.line, col, err)
+	}
+	return n, nil
+}
+
+// float parses col as a number, reporting whether it was present.
+func (t *table) float(record []string, col string) (float64, bool, error) {
+	v := t.get(record, col)
+	if v == "" {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("line %d: invalid %s: %w", t.line, col, err)
+	}
+	return f, true, nil
+}
+
+func parseFile[T any](path string, parse func(io.Reader) (T, error)) (T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer f.Close()
+
+	return parse(f)
+}
+
+func ParseStopTimes(r io.Reader) ([]StopTime, error) {
+	t, err := newTable(r, "trip_id", "stop_id", "arrival_time", "departure_time", "stop_sequence")
+	if err != nil {
+		return nil, err
+	}
 
 	var stopTimes []StopTime
 
-	lineNum := 1
 	for {
-		lineNum++
-		record, err := csvReader.Read()
+		record, err := t.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			return nil, err
 		}
 
-		arrivalTime, err := time.ParseTime(record[arrivalIdx])
+		arrivalTime, err := time.ParseTime(t.get(record, "arrival_time"))
 		if err != nil {
-			return nil, fmt.Errorf("line %d: invalid arrival_time: %w", lineNum, err)
+			return nil, fmt.Errorf("line %d: invalid arrival_time: %w", t.line, err)
 		}
 
-		departureTime, err := time.ParseTime(record[departureIdx])
+		departureTime, err := time.ParseTime(t.get(record, "departure_time"))
 		if err != nil {
-			return nil, fmt.Errorf("line %d: invalid departure_time: %w", lineNum, err)
+			return nil, fmt.Errorf("line %d: invalid departure_time: %w", t.line, err)
 		}
 
-		stopSequence, err := strconv.Atoi(strings.TrimSpace(record[seqIdx]))
+		stopSequence, err := strconv.Atoi(t.get(record, "stop_sequence"))
 		if err != nil {
-			return nil, fmt.Errorf("line %d: invalid stop_sequence: %w", lineNum, err)
+			return nil, fmt.Errorf("line %d: invalid stop_sequence: %w", t.line, err)
 		}
 
 		stopTimes = append(stopTimes, StopTime{
-			TripID:        TripID(strings.TrimSpace(record[tripIdx])),
-			StopID:        StopID(strings.TrimSpace(record[stopIdx])),
+			TripID:        TripID(t.get(record, "trip_id")),
+			StopID:        StopID(t.get(record, "stop_id")),
 			ArrivalTime:   arrivalTime,
 			DepartureTime: departureTime,
 			StopSequence:  stopSequence,
@@ -78,67 +156,54 @@ func ParseStopTimes(r io.Reader) ([]StopTime, error) {
 }
 
 func ParseStopTimesFile(path string) ([]StopTime, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	return ParseStopTimes(f)
+	return parseFile(path, ParseStopTimes)
 }
 
-func ParseTrips(r io.Reader) (TripToRoute, error) {
-	csvReader := csv.NewReader(r)
+// Trip is a trips.txt entry.
+type Trip struct {
+	ID          TripID
+	RouteID     RouteID
+	ServiceID   ServiceID
+	Headsign    string
+	DirectionID int
+}
 
-	header, err := csvReader.Read()
+func ParseTrips(r io.Reader) ([]Trip, error) {
+	t, err := newTable(r, "trip_id", "route_id")
 	if err != nil {
-		return nil, fmt.Errorf("reading header: %w", err)
-	}
-
-	colIndex := make(map[string]int)
-	for i, col := range header {
-		colIndex[strings.TrimSpace(col)] = i
-	}
-
-	requiredCols := []string{"trip_id", "route_id"}
-	for _, col := range requiredCols {
-		if _, ok := colIndex[col]; !ok {
-			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, col)
-		}
+		return nil, err
 	}
 
-	tripIdx := colIndex["trip_id"]
-	routeIdx := colIndex["route_id"]
-
-	tripRoutes := make(TripToRoute)
+	var trips []Trip
 
-	lineNum := 1
 	for {
-		lineNum++
-		record, err := csvReader.Read()
+		record, err := t.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			return nil, err
 		}
 
-		tripID := TripID(strings.TrimSpace(record[tripIdx]))
-		routeID := RouteID(strings.TrimSpace(record[routeIdx]))
-		tripRoutes[tripID] = routeID
+		direction, err := t.int(record, "direction_id", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		trips = append(trips, Trip{
+			ID:          TripID(t.get(record, "trip_id")),
+			RouteID:     RouteID(t.get(record, "route_id")),
+			ServiceID:   ServiceID(t.get(record, "service_id")),
+			Headsign:    t.get(record, "trip_headsign"),
+			DirectionID: direction,
+		})
 	}
 
-	return tripRoutes, nil
+	return trips, nil
 }
 
-func ParseTripsFile(path string) (TripToRoute, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	return ParseTrips(f)
+func ParseTripsFile(path string) ([]Trip, error) {
+	return parseFile(path, ParseTrips)
 }
 
 // Transfer represents a footpath transfer between two stops
@@ -149,69 +214,179 @@ type Transfer struct {
 	MinTransferTime int // seconds
 }
 
-func ParseTransfers(r io.Reader) ([]Transfer, error) {
-	csvReader := csv.NewReader(r)
+// TransferNotPossible is the transfer_type forbidding a transfer.
+const TransferNotPossible = 3
 
-	header, err := csvReader.Read()
+func ParseTransfers(r io.Reader) ([]Transfer, error) {
+	t, err := newTable(r, "from_stop_id", "to_stop_id")
 	if err != nil {
-		return nil, fmt.Errorf("reading header: %w", err)
+		return nil, err
 	}
 
-	colIndex := make(map[string]int)
-	for i, col := range header {
-		colIndex[strings.TrimSpace(col)] = i
-	}
+	var transfers []Transfer
 
-	requiredCols := []string{"from_stop_id", "to_stop_id"}
-	for _, col := range requiredCols {
-		if _, ok := colIndex[col]; !ok {
-			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, col)
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		transfer := Transfer{
+			FromStopID: StopID(t.get(record, "from_stop_id")),
+			ToStopID:   StopID(t.get(record, "to_stop_id")),
 		}
+		transfer.TransferType, _ = strconv.Atoi(t.get(record, "transfer_type"))
+		transfer.MinTransferTime, _ = strconv.Atoi(t.get(record, "min_transfer_time"))
+
+		transfers = append(transfers, transfer)
 	}
 
-	fromIdx := colIndex["from_stop_id"]
-	toIdx := colIndex["to_stop_id"]
-	typeIdx, hasType := colIndex["transfer_type"]
-	timeIdx, hasTime := colIndex["min_transfer_time"]
+	return transfers, nil
+}
 
-	var transfers []Transfer
+func ParseTransfersFile(path string) ([]Transfer, error) {
+	return parseFile(path, ParseTransfers)
+}
+
+func ParseStops(r io.Reader) ([]Stop, error) {
+	t, err := newTable(r, "stop_id")
+	if err != nil {
+		return nil, err
+	}
+
+	var stops []Stop
 
-	lineNum := 1
 	for {
-		lineNum++
-		record, err := csvReader.Read()
+		record, err := t.next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			return nil, err
 		}
 
-		transfer := Transfer{
-			FromStopID: StopID(strings.TrimSpace(record[fromIdx])),
-			ToStopID:   StopID(strings.TrimSpace(record[toIdx])),
+		stop := Stop{
+			ID:            StopID(t.get(record, "stop_id")),
+			Code:          t.get(record, "stop_code"),
+			Name:          t.get(record, "stop_name"),
+			ParentStation: StopID(t.get(record, "parent_station")),
+			ZoneID:        t.get(record, "zone_id"),
+			PlatformCode:  t.get(record, "platform_code"),
 		}
 
-		if hasType && typeIdx < len(record) {
-			transfer.TransferType, _ = strconv.Atoi(strings.TrimSpace(record[typeIdx]))
+		locationType, err := t.int(record, "location_type", 0)
+		if err != nil {
+			return nil, err
 		}
+		stop.LocationType = LocationType(locationType)
 
-		if hasTime && timeIdx < len(record) {
-			transfer.MinTransferTime, _ = strconv.Atoi(strings.TrimSpace(record[timeIdx]))
+		wheelchair, err := t.int(record, "wheelchair_boarding", 0)
+		if err != nil {
+			return nil, err
 		}
+		stop.WheelchairBoarding = WheelchairBoarding(wheelchair)
 
-		transfers = append(transfers, transfer)
+		lat, hasLat, err := t.float(record, "stop_lat")
+		if err != nil {
+			return nil, err
+		}
+		lon, hasLon, err := t.float(record, "stop_lon")
+		if err != nil {
+			return nil, err
+		}
+		// Coordinates are optional only for generic nodes and boarding
+		// areas, which take their position from the parent station.
+		if (!hasLat || !hasLon) && stop.LocationType <= LocationEntrance {
+			return nil, fmt.Errorf("line %d: %w: stop %s has no coordinates", t.line, ErrInvalidData, stop.ID)
+		}
+		stop.Lat, stop.Lon = lat, lon
+
+		stops = append(stops, stop)
 	}
 
-	return transfers, nil
+	return stops, nil
 }
 
-func ParseTransfersFile(path string) ([]Transfer, error) {
-	f, err := os.Open(path)
+func ParseStopsFile(path string) ([]Stop, error) {
+	return parseFile(path, ParseStops)
+}
+
+func ParseRoutes(r io.Reader) ([]Route, error) {
+	t, err := newTable(r, "route_id", "route_type")
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	return ParseTransfers(f)
+	var routes []Route
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		routeType, err := t.int(record, "route_type", -1)
+		if err != nil {
+			return nil, err
+		}
+		if routeType < 0 {
+			return nil, fmt.Errorf("line %d: %w: route %s has no route_type", t.line, ErrInvalidData, t.get(record, "route_id"))
+		}
+
+		routes = append(routes, Route{
+			ID:        RouteID(t.get(record, "route_id")),
+			AgencyID:  AgencyID(t.get(record, "agency_id")),
+			ShortName: t.get(record, "route_short_name"),
+			LongName:  t.get(record, "route_long_name"),
+			Type:      RouteType(routeType),
+			Color:     t.get(record, "route_color"),
+			TextColor: t.get(record, "route_text_color"),
+		})
+	}
+
+	return routes, nil
+}
+
+func ParseRoutesFile(path string) ([]Route, error) {
+	return parseFile(path, ParseRoutes)
+}
+
+func ParseAgencies(r io.Reader) ([]Agency, error) {
+	t, err := newTable(r, "agency_name", "agency_timezone")
+	if err != nil {
+		return nil, err
+	}
+
+	var agencies []Agency
+
+	for {
+		record, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		agencies = append(agencies, Agency{
+			ID:       AgencyID(t.get(record, "agency_id")),
+			Name:     t.get(record, "agency_name"),
+			URL:      t.get(record, "agency_url"),
+			Timezone: t.get(record, "agency_timezone"),
+			Lang:     t.get(record, "agency_lang"),
+			Phone:    t.get(record, "agency_phone"),
+		})
+	}
+
+	return agencies, nil
+}
+
+func ParseAgenciesFile(path string) ([]Agency, error) {
+	return parseFile(path, ParseAgencies)
 }
@@ -0,0 +1,178 @@
+package gtfs_test
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// trip returns the stop times of a trip leaving at start and taking ten
+// minutes between stops.
+func trip(id gtfs.TripID, start time.Time, stops ...gtfs.StopID) []gtfs.StopTime {
+	var times []gtfs.StopTime
+	for i, stop := range stops {
+		at := start + time.Time(i*600)
+		times = append(times, gtfs.StopTime{TripID: id, StopID: stop, ArrivalTime: at, DepartureTime: at, StopSequence: (i + 1) * 10})
+	}
+	return times
+}
+
+func TestBuildIndex_Patterns(t *testing.T) {
+	var stopTimes []gtfs.StopTime
+	for _, trips := range [][]gtfs.StopTime{
+		// Full line, three trips.
+		trip("full1", 8*3600, "A", "B", "C", "D"),
+		trip("full2", 9*3600, "A", "B", "C", "D"),
+		trip("full3", 10*3600, "A", "B", "C", "D"),
+		// Short turn at C and a branch to E.
+		trip("short", 8*3600+300, "A", "B", "C"),
+		trip("branch", 8*3600+900, "A", "B", "E"),
+		// The other direction under the same route_id.
+		trip("back1", 8*3600, "D", "C", "B", "A"),
+		trip("back2", 9*3600, "D", "C", "B", "A"),
+		// A trip of an unknown route and a single-stop trip.
+		trip("stray", 8*3600, "A", "B"),
+		trip("stub", 8*3600, "A"),
+	} {
+		stopTimes = append(stopTimes, trips...)
+	}
+	tripRoutes := gtfs.TripToRoute{
+		"full1": "L", "full2": "L", "full3": "L", "short": "L", "branch": "L", "back1": "L", "back2": "L", "stub": "L",
+	}
+
+	idx := gtfs.BuildIndex(stopTimes, tripRoutes)
+
+	want := []struct {
+		id    gtfs.PatternID
+		stops []gtfs.StopID
+		trips []gtfs.TripID
+	}{
+		{"L:1", []gtfs.StopID{"A", "B", "C", "D"}, []gtfs.TripID{"full1", "full2", "full3"}},
+		{"L:2", []gtfs.StopID{"D", "C", "B", "A"}, []gtfs.TripID{"back1", "back2"}},
+		{"L:3", []gtfs.StopID{"A", "B", "C"}, []gtfs.TripID{"short"}},
+		{"L:4", []gtfs.StopID{"A", "B", "E"}, []gtfs.TripID{"branch"}},
+	}
+	if got := idx.RoutePatterns["L"]; len(got) != len(want) {
+		t.Fatalf("RoutePatterns[L] = %v, want %d patterns", got, len(want))
+	}
+	for i, w := range want {
+		p := &idx.Patterns[idx.RoutePatterns["L"][i]]
+		stops := stopIDs(idx, p)
+		if p.ID != w.id || p.RouteID != "L" || !equal(stops, w.stops) || p.TripCount() != len(w.trips) {
+			t.Errorf("pattern %d = %s %v with %d trips, want %s %v with %d", i, p.ID, stops, p.TripCount(), w.id, w.stops, len(w.trips))
+			continue
+		}
+		for j, id := range w.trips {
+			if p.TripIDs[j] != id {
+				t.Errorf("%s trip %d = %s, want %s", p.ID, j, p.TripIDs[j], id)
+			}
+		}
+	}
+
+	if idx.SkippedTrips != 2 {
+		t.Errorf("SkippedTrips = %d, want 2", idx.SkippedTrips)
+	}
+	if visits := patternsAt(idx, "E"); len(visits) != 1 || idx.Patterns[visits[0].Pattern].ID != "L:4" || visits[0].Index != 2 {
+		t.Errorf("PatternsAtStop(E) = %v", visits)
+	}
+	if routes := idx.RoutesAtStop("B"); len(routes) != 1 || routes[0] != "L" {
+		t.Errorf("RoutesAtStop(B) = %v, want [L]", routes)
+	}
+}
+
+func TestBuildIndex_OvertakingTripsSplit(t *testing.T) {
+	// The express leaves after the local and arrives before it.
+	stopTimes := append(trip("local", 8*3600, "A", "B", "C"),
+		gtfs.StopTime{TripID: "express", StopID: "A", ArrivalTime: 8*3600 + 60, DepartureTime: 8*3600 + 60, StopSequence: 1},
+		gtfs.StopTime{TripID: "express", StopID: "B", ArrivalTime: 8*3600 + 300, DepartureTime: 8*3600 + 300, StopSequence: 2},
+		gtfs.StopTime{TripID: "express", StopID: "C", ArrivalTime: 8*3600 + 600, DepartureTime: 8*3600 + 600, StopSequence: 3},
+	)
+	stopTimes = append(stopTimes, trip("later", 9*3600, "A", "B", "C")...)
+
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"local": "L", "express": "L", "later": "L"})
+
+	if got := idx.RoutePatterns["L"]; len(got) != 2 {
+		t.Fatalf("RoutePatterns[L] = %v, want the express apart", got)
+	}
+	first, second := idx.Pattern("L:1"), idx.Pattern("L:2")
+	if first.NumTrips() != 2 || first.TripIDs[0] != "local" || first.TripIDs[1] != "later" {
+		t.Errorf("L:1 trips = %v, want local and later", first.TripIDs)
+	}
+	if second.NumTrips() != 1 || second.TripIDs[0] != "express" {
+		t.Errorf("L:2 trips = %v, want express", second.TripIDs)
+	}
+}
+
+func TestBuildIndex_Loop(t *testing.T) {
+	idx := gtfs.BuildIndex(trip("loop", 8*3600, "A", "B", "C", "A"), gtfs.TripToRoute{"loop": "L"})
+
+	visits := patternsAt(idx, "A")
+	if len(visits) != 2 || visits[0].Index != 0 || visits[1].Index != 3 {
+		t.Errorf("PatternsAtStop(A) = %v, want both visits of the loop", visits)
+	}
+}
+
+func TestBuildIndex_DenseNumbers(t *testing.T) {
+	stopTimes := append(trip("a", 8*3600, "A", "B", "C"), trip("b", 8*3600, "C", "D")...)
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"a": "L", "b": "M"})
+
+	if len(idx.Stops) != 4 {
+		t.Fatalf("Stops = %v, want 4 stops", idx.Stops)
+	}
+	for n, id := range idx.Stops {
+		if got, ok := idx.StopNumber(id); !ok || got != n {
+			t.Errorf("StopNumber(%s) = %d, %v, want %d", id, got, ok, n)
+		}
+	}
+	if _, ok := idx.StopNumber("Z"); ok {
+		t.Error("StopNumber(Z) found a stop the index does not serve")
+	}
+
+	for n, p := range idx.Patterns {
+		if idx.Pattern(p.ID) != &idx.Patterns[n] {
+			t.Errorf("Pattern(%s) is not pattern %d", p.ID, n)
+		}
+		if len(p.Times) != p.NumTrips()*len(p.Stops) {
+			t.Errorf("%s has %d times for %d trips of %d stops", p.ID, len(p.Times), p.NumTrips(), len(p.Stops))
+		}
+	}
+	if idx.Pattern("L:2") != nil {
+		t.Error("Pattern(L:2) found a pattern the index does not have")
+	}
+
+	// C is served by both routes.
+	if visits := patternsAt(idx, "C"); len(visits) != 2 {
+		t.Errorf("PatternsAtStop(C) = %v, want a visit of each route", visits)
+	}
+}
+
+// patternsAt returns the pattern visits at a stop given by ID.
+func patternsAt(idx *gtfs.StopTimeIndex, id gtfs.StopID) []gtfs.PatternStop {
+	s, ok := idx.StopNumber(id)
+	if !ok {
+		return nil
+	}
+	return idx.PatternsAtStop(s)
+}
+
+// stopIDs returns the IDs of a pattern's stops.
+func stopIDs(idx *gtfs.StopTimeIndex, p *gtfs.Pattern) []gtfs.StopID {
+	ids := make([]gtfs.StopID, len(p.Stops))
+	for i, s := range p.Stops {
+		ids[i] = idx.Stops[s]
+	}
+	return ids
+}
+
+func equal(a, b []gtfs.StopID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
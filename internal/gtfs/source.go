@@ -0,0 +1,88 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// LoadFeed parses a GTFS feed from a directory or a .zip archive. Archive
+// members are read as they are decompressed, without extracting them.
+func LoadFeed(path string) (*Feed, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadFeed(os.DirFS(path))
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer archive.Close()
+
+	return ReadFeed(archive)
+}
+
+// feedRoot returns the folder of fsys holding the feed: fsys itself, or
+// the folder containing stop_times.txt for archives that wrap the feed in
+// one. Folders such as __MACOSX, which hold metadata, are skipped.
+func feedRoot(fsys fs.FS) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, StopTimesFile); err == nil {
+		return fsys, nil
+	}
+
+	root := ""
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != "." && (strings.HasPrefix(d.Name(), "_") || strings.HasPrefix(d.Name(), ".")) {
+			return fs.SkipDir
+		}
+		if !d.IsDir() && d.Name() == StopTimesFile {
+			root = path.Dir(p)
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		// Let reading stop_times.txt report it missing.
+		return fsys, nil
+	}
+	return fs.Sub(fsys, root)
+}
+
+func parseRequired[T any](fsys fs.FS, name string, parse func(io.Reader) (T, error)) (T, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	defer f.Close()
+
+	items, err := parse(f)
+	if err != nil {
+		return items, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return items, nil
+}
+
+// parseOptional parses a file that feeds may leave out.
+func parseOptional[T any](fsys fs.FS, name string, parse func(io.Reader) ([]T, error)) ([]T, error) {
+	items, err := parseRequired(fsys, name, parse)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return items, err
+}
@@ -0,0 +1,99 @@
+package gtfs_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+)
+
+// writeZip packs the example feed into an archive, under prefix and with
+// a byte order mark in front of every file.
+func writeZip(t *testing.T, prefix string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "feed.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	entries, err := os.ReadDir("../../examples/gtfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("../../examples/gtfs", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(prefix + entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(append([]byte("\ufeff"), data...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Archives made on macOS carry copies of every file's metadata.
+	if _, err := zw.Create("__MACOSX/feed/._stop_times.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFeed_Zip(t *testing.T) {
+	want, err := gtfs.LoadFeed("../../examples/gtfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"", "feed/", "recife/gtfs/"} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			feed, err := gtfs.LoadFeed(writeZip(t, prefix))
+			if err != nil {
+				t.Fatalf("LoadFeed() error = %v", err)
+			}
+			if len(feed.StopTimes) != len(want.StopTimes) || len(feed.Trips) != len(want.Trips) ||
+				len(feed.Stops) != len(want.Stops) || len(feed.Routes) != len(want.Routes) ||
+				len(feed.Transfers) != len(want.Transfers) || len(feed.Calendars) != len(want.Calendars) {
+				t.Errorf("zip feed differs from the directory: %d stop times, %d trips, %d stops, %d routes",
+					len(feed.StopTimes), len(feed.Trips), len(feed.Stops), len(feed.Routes))
+			}
+			if feed.StopTimes[0] != want.StopTimes[0] {
+				t.Errorf("first stop time = %+v, want %+v", feed.StopTimes[0], want.StopTimes[0])
+			}
+		})
+	}
+}
+
+func TestLoadFeed_NotAFeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.zip")
+	if err := os.WriteFile(path, []byte("not a zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gtfs.LoadFeed(path); err == nil {
+		t.Error("expected an error for a file that is not a zip archive")
+	}
+	if _, err := gtfs.LoadFeed(filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestParseStopTimes_ByteOrderMark(t *testing.T) {
+	csvData := "\ufefftrip_id,arrival_time,departure_time,stop_id,stop_sequence\ntrip1,08:00:00,08:00:00,stopA,1\n"
+	stopTimes, err := gtfs.ParseStopTimes(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ParseStopTimes() error = %v", err)
+	}
+	if len(stopTimes) != 1 || stopTimes[0].TripID != "trip1" {
+		t.Errorf("stopTimes = %+v", stopTimes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/danielscoffee/pathcraft/internal/time"
 )
@@ -16,6 +17,10 @@ type TripID string
 
 type RouteID string
 
+// PatternID names a trip pattern as its route ID and a number, such as
+// "L1:2". A route's patterns are numbered from 1, busiest first.
+type PatternID string
+
 type StopTime struct {
 	TripID        TripID
 	StopID        StopID
@@ -24,99 +29,196 @@ type StopTime struct {
 	StopSequence  int
 }
 
-type RouteStop struct {
-	StopID   StopID
-	Sequence int
+type TripStopTime struct {
+	TripID TripID
+	// ServiceDay is -1 for a trip of the previous service day, whose times
+	// are shifted to count from the start of the indexed day.
+	ServiceDay    int
+	ArrivalTime   time.Time
+	DepartureTime time.Time
 }
 
-type RoutePattern struct {
+// StopEvent is when a trip arrives at and leaves a stop.
+type StopEvent struct {
+	Arrival   time.Time
+	Departure time.Time
+}
+
+// Pattern is a sequence of stops that trips of one route serve in exactly
+// that order, the unit RAPTOR scans. Branches, short turns and the two
+// directions of a route are separate patterns.
+//
+// The trips of a pattern never overtake each other: a trip leaving the
+// first stop later arrives later everywhere. Trips that would overtake are
+// put in another pattern with the same stops.
+type Pattern struct {
+	ID      PatternID
 	RouteID RouteID
-	Stops   []RouteStop
+	// Stops holds stop numbers, see StopTimeIndex.Stops.
+	Stops []int32
+	// Times holds the scheduled trips' times one trip after the other: trip
+	// t is at stop index i at Times[t*len(Stops)+i]. Trips are sorted by
+	// departure.
+	Times []StopEvent
+	// TripIDs and ServiceDays describe the trips of Times, see
+	// TripStopTime.ServiceDay.
+	TripIDs     []TripID
+	ServiceDays []int8
+	// Frequencies lists the frequency-based trips serving the pattern, which
+	// are not in Times. See EarliestBoarding.
+	Frequencies []FrequencyTrip
 }
 
-type TripStopTime struct {
-	TripID        TripID
-	ArrivalTime   time.Time
-	DepartureTime time.Time
+// NumTrips returns the number of scheduled trips.
+func (p *Pattern) NumTrips() int {
+	return len(p.TripIDs)
 }
 
-type StopTimeIndex struct {
-	StopRoutes          map[StopID][]RouteID
-	RoutePatterns       map[RouteID]*RoutePattern
-	RouteStopTrips      map[string][]TripStopTime
-	StopPositionInRoute map[string]int
-	// RouteTrips[routeID][tripIndex][stopIndex]
-	RouteTrips map[RouteID][][]TripStopTime
+// Trip returns the times of scheduled trip t at each stop.
+func (p *Pattern) Trip(t int) []StopEvent {
+	n := len(p.Stops)
+	return p.Times[t*n : (t+1)*n : (t+1)*n]
 }
 
-func NewStopTimeIndex() *StopTimeIndex {
-	return &StopTimeIndex{
-		StopRoutes:          make(map[StopID][]RouteID),
-		RoutePatterns:       make(map[RouteID]*RoutePattern),
-		RouteStopTrips:      make(map[string][]TripStopTime),
-		StopPositionInRoute: make(map[string]int),
-		RouteTrips:          make(map[RouteID][][]TripStopTime),
-	}
+// TripCount returns the number of trips in the pattern, counting each
+// frequency-based trip once.
+func (p *Pattern) TripCount() int {
+	return p.NumTrips() + len(p.Frequencies)
 }
 
-func (idx *StopTimeIndex) RoutesAtStop(stopID StopID) []RouteID {
-	return idx.StopRoutes[stopID]
+// PatternStop is a visit of a pattern to a stop, at Stops[Index].
+type PatternStop struct {
+	Pattern int32
+	Index   int32
 }
 
-func (idx *StopTimeIndex) StopsOnRoute(routeID RouteID) []RouteStop {
-	pattern := idx.RoutePatterns[routeID]
-	if pattern == nil {
+// StopTimeIndex holds the trip patterns of a feed in the form RAPTOR
+// searches. Stops and patterns are numbered densely from zero, and what a
+// search reads is kept in flat slices indexed by those numbers.
+type StopTimeIndex struct {
+	// Stops maps stop numbers to IDs. Stops are numbered along the
+	// patterns, so that stops served together are stored together.
+	Stops []StopID
+	// Patterns are numbered by position, ordered by route and pattern ID.
+	Patterns []Pattern
+	// RoutePatterns lists each route's pattern numbers in order of their ID.
+	RoutePatterns map[RouteID][]int
+	// SkippedTrips counts trips left out because their route is unknown or
+	// they serve fewer than two stops.
+	SkippedTrips int
+
+	stopNumbers    map[StopID]int
+	patternNumbers map[PatternID]int
+	// The pattern visits at stop s are
+	// stopVisits[stopVisitStart[s]:stopVisitStart[s+1]].
+	stopVisitStart []int32
+	stopVisits     []PatternStop
+}
+
+// StopNumber returns the number of a stop served by the index.
+func (idx *StopTimeIndex) StopNumber(id StopID) (int, bool) {
+	n, ok := idx.stopNumbers[id]
+	return n, ok
+}
+
+// Pattern returns a pattern by ID, or nil.
+func (idx *StopTimeIndex) Pattern(id PatternID) *Pattern {
+	n, ok := idx.patternNumbers[id]
+	if !ok {
 		return nil
 	}
-	return pattern.Stops
+	return &idx.Patterns[n]
 }
 
-func (idx *StopTimeIndex) TripsAtRouteStop(routeID RouteID, stopSequence int) []TripStopTime {
-	key := fmt.Sprintf("%s:%d", routeID, stopSequence)
-	return idx.RouteStopTrips[key]
+// PatternsAtStop returns the pattern visits at stop number s. A pattern
+// looping through a stop visits it more than once.
+func (idx *StopTimeIndex) PatternsAtStop(s int) []PatternStop {
+	return idx.stopVisits[idx.stopVisitStart[s]:idx.stopVisitStart[s+1]]
 }
 
-func (idx *StopTimeIndex) GetStopSequence(stopID StopID, routeID RouteID) int {
-	key := fmt.Sprintf("%s:%s", stopID, routeID)
-	seq, ok := idx.StopPositionInRoute[key]
+// RoutesAtStop returns the routes serving a stop.
+func (idx *StopTimeIndex) RoutesAtStop(stopID StopID) []RouteID {
+	s, ok := idx.stopNumbers[stopID]
 	if !ok {
-		return -1
+		return nil
+	}
+	var routes []RouteID
+	for _, ps := range idx.PatternsAtStop(s) {
+		routeID := idx.Patterns[ps.Pattern].RouteID
+		if !containsRoute(routes, routeID) {
+			routes = append(routes, routeID)
+		}
 	}
-	return seq
+	return routes
 }
 
-func (idx *StopTimeIndex) EarliestTrip(routeID RouteID, stopSequence int, minDepartureTime time.Time) *TripStopTime {
-	trips := idx.TripsAtRouteStop(routeID, stopSequence)
-	if len(trips) == 0 {
+// EarliestTrip returns the times at stop index stopIndex of the scheduled
+// trip of a pattern leaving there first at or after minDepartureTime.
+func (idx *StopTimeIndex) EarliestTrip(patternID PatternID, stopIndex int, minDepartureTime time.Time) *TripStopTime {
+	n, ok := idx.patternNumbers[patternID]
+	if !ok {
 		return nil
 	}
-
-	i := sort.Search(len(trips), func(i int) bool {
-		return trips[i].DepartureTime >= minDepartureTime
-	})
-
-	if i < len(trips) {
-		return &trips[i]
+	t := idx.EarliestTripIndex(n, stopIndex, minDepartureTime)
+	if t == -1 {
+		return nil
+	}
+	p := &idx.Patterns[n]
+	ev := p.Trip(t)[stopIndex]
+	return &TripStopTime{
+		TripID:        p.TripIDs[t],
+		ServiceDay:    int(p.ServiceDays[t]),
+		ArrivalTime:   ev.Arrival,
+		DepartureTime: ev.Departure,
 	}
-	return nil
 }
 
-func (idx *StopTimeIndex) EarliestTripIndex(routeID RouteID, stopIndex int, minDepartureTime time.Time) int {
-	trips := idx.RouteTrips[routeID]
-	if len(trips) == 0 {
-		return -1
+// EarliestTripIndex returns the scheduled trip of pattern number pattern
+// leaving stop index stopIndex first at or after minDepartureTime, or -1.
+func (idx *StopTimeIndex) EarliestTripIndex(pattern int, stopIndex int, minDepartureTime time.Time) int {
+	p := &idx.Patterns[pattern]
+	n, stride := p.NumTrips(), len(p.Stops)
+	times := p.Times
+
+	// sort.Search, without the closure, as this is the innermost loop of
+	// a search.
+	lo, hi := 0, n
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if times[mid*stride+stopIndex].Departure < minDepartureTime {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
 	}
 
-	i := sort.Search(len(trips), func(i int) bool {
-		return trips[i][stopIndex].DepartureTime >= minDepartureTime
-	})
-
-	if i < len(trips) {
-		return i
+	if lo < n {
+		return lo
 	}
 	return -1
 }
 
+// LatestTripIndex returns the scheduled trip of pattern number pattern
+// arriving at stop index stopIndex last at or before maxArrivalTime, or
+// -1. Trips of a pattern do not overtake, so their arrivals are in order
+// too.
+func (idx *StopTimeIndex) LatestTripIndex(pattern int, stopIndex int, maxArrivalTime time.Time) int {
+	p := &idx.Patterns[pattern]
+	n, stride := p.NumTrips(), len(p.Stops)
+	times := p.Times
+
+	lo, hi := 0, n
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if times[mid*stride+stopIndex].Arrival <= maxArrivalTime {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo - 1
+}
+
 // WARN: dedicate error packages?
 var (
 	ErrMissingColumn = errors.New("missing required column")
@@ -125,121 +227,275 @@ var (
 
 type TripToRoute map[TripID]RouteID
 
-func BuildIndex(stopTimes []StopTime, tripRoutes TripToRoute) *StopTimeIndex {
-	idx := NewStopTimeIndex()
-
-	tripStops := make(map[TripID][]StopTime)
-	for _, st := range stopTimes {
-		tripStops[st.TripID] = append(tripStops[st.TripID], st)
-	}
+// tripRun is a trip on one service day, relative to the indexed day.
+type tripRun struct {
+	trip TripID
+	day  int
+}
 
-	for tripID := range tripStops {
-		sort.Slice(tripStops[tripID], func(i, j int) bool {
-			return tripStops[tripID][i].StopSequence < tripStops[tripID][j].StopSequence
-		})
-	}
+// SecondsPerDay shifts the times of trips from one service day to the next.
+const SecondsPerDay = 24 * time.SecondsPerHour
 
-	routeStopsSet := make(map[RouteID]map[int]StopID)
-	routeStopTripsTemp := make(map[string][]TripStopTime)
+// BuildIndex groups trips into patterns for RAPTOR. Trips with frequencies
+// run once per headway, see FrequencyTrip, rather than at the times of
+// their stop times.
+func BuildIndex(stopTimes []StopTime, tripRoutes TripToRoute, frequencies ...Frequency) *StopTimeIndex {
+	tripStops := make(map[tripRun][]StopTime)
+	groupTrips(tripStops, stopTimes, 0, nil)
+	return buildIndex(tripStops, tripRoutes, groupFrequencies(frequencies))
+}
 
-	for tripID, stops := range tripStops {
-		routeID, ok := tripRoutes[tripID]
-		if !ok {
+// groupTrips adds the stop times of the trips accepted by keep to
+// tripStops, in stop order and with times shifted by day days. A nil keep
+// accepts every trip.
+func groupTrips(tripStops map[tripRun][]StopTime, stopTimes []StopTime, day int, keep func(TripID) bool) {
+	shift := time.Time(day * SecondsPerDay)
+	for _, st := range stopTimes {
+		if keep != nil && !keep(st.TripID) {
 			continue
 		}
+		st.ArrivalTime += shift
+		st.DepartureTime += shift
+		run := tripRun{trip: st.TripID, day: day}
+		tripStops[run] = append(tripStops[run], st)
+	}
 
-		if routeStopsSet[routeID] == nil {
-			routeStopsSet[routeID] = make(map[int]StopID)
+	for run, stops := range tripStops {
+		if run.day == day {
+			sort.Slice(stops, func(i, j int) bool {
+				return stops[i].StopSequence < stops[j].StopSequence
+			})
 		}
+	}
+}
 
-		for _, st := range stops {
-			routeStopsSet[routeID][st.StopSequence] = st.StopID
+// stopSequence gathers the trips of a route serving the same stops.
+type stopSequence struct {
+	route     RouteID
+	stops     []StopID
+	trips     [][]TripStopTime
+	templates []FrequencyTrip
+}
 
-			key := fmt.Sprintf("%s:%d", routeID, st.StopSequence)
-			routeStopTripsTemp[key] = append(routeStopTripsTemp[key], TripStopTime{
-				TripID:        tripID,
-				ArrivalTime:   st.ArrivalTime,
-				DepartureTime: st.DepartureTime,
-			})
+// lane is a pattern being built: trips of a stop sequence that do not
+// overtake each other.
+type lane struct {
+	seq       *stopSequence
+	trips     [][]TripStopTime
+	templates []FrequencyTrip
+}
 
-			posKey := fmt.Sprintf("%s:%s", st.StopID, routeID)
-			idx.StopPositionInRoute[posKey] = st.StopSequence
-		}
+func (l *lane) tripCount() int {
+	return len(l.trips) + len(l.templates)
+}
+
+func (l *lane) first() tripKey {
+	if len(l.trips) > 0 {
+		return keyOf(l.trips[0])
 	}
+	return l.templates[0].key()
+}
 
-	for routeID, seqToStop := range routeStopsSet {
-		var sequences []int
-		for seq := range seqToStop {
-			sequences = append(sequences, seq)
-		}
-		sort.Ints(sequences)
+func buildIndex(tripStops map[tripRun][]StopTime, tripRoutes TripToRoute, frequencies map[TripID][]Frequency) *StopTimeIndex {
+	idx := &StopTimeIndex{
+		RoutePatterns:  make(map[RouteID][]int),
+		stopNumbers:    make(map[StopID]int),
+		patternNumbers: make(map[PatternID]int),
+	}
 
-		pattern := &RoutePattern{
-			RouteID: routeID,
-			Stops:   make([]RouteStop, len(sequences)),
+	sequences := make(map[string]*stopSequence)
+	for run, stops := range tripStops {
+		routeID, ok := tripRoutes[run.trip]
+		if !ok || len(stops) < 2 {
+			idx.SkippedTrips++
+			continue
 		}
 
-		for i, seq := range sequences {
-			stopID := seqToStop[seq]
-			pattern.Stops[i] = RouteStop{
-				StopID:   stopID,
-				Sequence: seq,
+		ids := make([]string, len(stops))
+		times := make([]TripStopTime, len(stops))
+		for i, st := range stops {
+			ids[i] = string(st.StopID)
+			times[i] = TripStopTime{
+				TripID:        run.trip,
+				ServiceDay:    run.day,
+				ArrivalTime:   st.ArrivalTime,
+				DepartureTime: st.DepartureTime,
 			}
+		}
 
-			if !containsRoute(idx.StopRoutes[stopID], routeID) {
-				idx.StopRoutes[stopID] = append(idx.StopRoutes[stopID], routeID)
+		key := string(routeID) + "\x00" + strings.Join(ids, "\x00")
+		seq := sequences[key]
+		if seq == nil {
+			seq = &stopSequence{route: routeID}
+			for _, st := range stops {
+				seq.stops = append(seq.stops, st.StopID)
 			}
+			sequences[key] = seq
 		}
 
-		idx.RoutePatterns[routeID] = pattern
+		if windows, ok := frequencies[run.trip]; ok {
+			seq.templates = append(seq.templates, newFrequencyTrip(times, windows, run.day))
+		} else {
+			seq.trips = append(seq.trips, times)
+		}
 	}
 
-	for key, trips := range routeStopTripsTemp {
-		sort.Slice(trips, func(i, j int) bool {
-			return trips[i].DepartureTime < trips[j].DepartureTime
+	routeLanes := make(map[RouteID][]*lane)
+	var routes []RouteID
+	for _, seq := range sequences {
+		if routeLanes[seq.route] == nil {
+			routes = append(routes, seq.route)
+		}
+		routeLanes[seq.route] = append(routeLanes[seq.route], seq.lanes()...)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i] < routes[j] })
+
+	var numTimes, numTrips, numStops int
+	for _, lanes := range routeLanes {
+		for _, l := range lanes {
+			numTimes += len(l.trips) * len(l.seq.stops)
+			numTrips += len(l.trips)
+			numStops += len(l.seq.stops)
+		}
+	}
+	times := make([]StopEvent, 0, numTimes)
+	tripIDs := make([]TripID, 0, numTrips)
+	serviceDays := make([]int8, 0, numTrips)
+	patternStops := make([]int32, 0, numStops)
+
+	for _, routeID := range routes {
+		lanes := routeLanes[routeID]
+		sort.Slice(lanes, func(i, j int) bool {
+			a, b := lanes[i], lanes[j]
+			if a.tripCount() != b.tripCount() {
+				return a.tripCount() > b.tripCount()
+			}
+			return a.first().less(b.first())
 		})
-		idx.RouteStopTrips[key] = trips
-	}
-
-	for routeID, pattern := range idx.RoutePatterns {
-		firstStopSeq := pattern.Stops[0].Sequence
-		firstStopTrips := idx.TripsAtRouteStop(routeID, firstStopSeq)
-
-		routeTrips := make([][]TripStopTime, 0, len(firstStopTrips))
-		for _, firstStopTrip := range firstStopTrips {
-			tripID := firstStopTrip.TripID
-			tripData := make([]TripStopTime, len(pattern.Stops))
-
-			validTrip := true
-			for j, rs := range pattern.Stops {
-				allTripsAtStop := idx.TripsAtRouteStop(routeID, rs.Sequence)
-				found := false
-				for _, t := range allTripsAtStop {
-					if t.TripID == tripID {
-						tripData[j] = t
-						found = true
-						break
-					}
+
+		for n, l := range lanes {
+			p := Pattern{
+				ID:          PatternID(fmt.Sprintf("%s:%d", routeID, n+1)),
+				RouteID:     routeID,
+				Frequencies: l.templates,
+			}
+
+			start := len(patternStops)
+			for _, stopID := range l.seq.stops {
+				s, ok := idx.stopNumbers[stopID]
+				if !ok {
+					s = len(idx.Stops)
+					idx.stopNumbers[stopID] = s
+					idx.Stops = append(idx.Stops, stopID)
 				}
-				if !found {
-					validTrip = false
-					break
+				patternStops = append(patternStops, int32(s))
+			}
+			p.Stops = patternStops[start:len(patternStops):len(patternStops)]
+
+			start = len(times)
+			tripStart := len(tripIDs)
+			for _, trip := range l.trips {
+				tripIDs = append(tripIDs, trip[0].TripID)
+				serviceDays = append(serviceDays, int8(trip[0].ServiceDay))
+				for _, st := range trip {
+					times = append(times, StopEvent{Arrival: st.ArrivalTime, Departure: st.DepartureTime})
 				}
 			}
+			p.Times = times[start:len(times):len(times)]
+			p.TripIDs = tripIDs[tripStart:len(tripIDs):len(tripIDs)]
+			p.ServiceDays = serviceDays[tripStart:len(serviceDays):len(serviceDays)]
+
+			idx.patternNumbers[p.ID] = len(idx.Patterns)
+			idx.RoutePatterns[routeID] = append(idx.RoutePatterns[routeID], len(idx.Patterns))
+			idx.Patterns = append(idx.Patterns, p)
+		}
+	}
 
-			if validTrip {
-				routeTrips = append(routeTrips, tripData)
+	// Pattern visits by stop, counted first so that they fit in one slice.
+	idx.stopVisitStart = make([]int32, len(idx.Stops)+1)
+	for _, s := range patternStops {
+		idx.stopVisitStart[s+1]++
+	}
+	for s := range idx.Stops {
+		idx.stopVisitStart[s+1] += idx.stopVisitStart[s]
+	}
+	idx.stopVisits = make([]PatternStop, len(patternStops))
+	next := append([]int32(nil), idx.stopVisitStart[:len(idx.Stops)]...)
+	for n := range idx.Patterns {
+		for i, s := range idx.Patterns[n].Stops {
+			idx.stopVisits[next[s]] = PatternStop{Pattern: int32(n), Index: int32(i)}
+			next[s]++
+		}
+	}
+
+	return idx
+}
+
+// lanes splits the trips into lanes whose trips do not overtake each
+// other, placing each trip in the first lane it fits. Frequency-based
+// trips go with the first lane.
+func (seq *stopSequence) lanes() []*lane {
+	sort.Slice(seq.trips, func(i, j int) bool {
+		return keyOf(seq.trips[i]).less(keyOf(seq.trips[j]))
+	})
+	sort.Slice(seq.templates, func(i, j int) bool {
+		return seq.templates[i].key().less(seq.templates[j].key())
+	})
+
+	var lanes []*lane
+	for _, trip := range seq.trips {
+		var fit *lane
+		for _, l := range lanes {
+			if follows(trip, l.trips[len(l.trips)-1]) {
+				fit = l
+				break
 			}
 		}
+		if fit == nil {
+			fit = &lane{seq: seq}
+			lanes = append(lanes, fit)
+		}
+		fit.trips = append(fit.trips, trip)
+	}
 
-		sort.Slice(routeTrips, func(i, j int) bool {
-			return routeTrips[i][0].DepartureTime < routeTrips[j][0].DepartureTime
-		})
+	if len(seq.templates) > 0 {
+		if len(lanes) == 0 {
+			lanes = append(lanes, &lane{seq: seq})
+		}
+		lanes[0].templates = seq.templates
+	}
+	return lanes
+}
 
-		idx.RouteTrips[routeID] = routeTrips
+// follows reports whether trip b is nowhere earlier than trip a.
+func follows(b, a []TripStopTime) bool {
+	for i := range a {
+		if b[i].ArrivalTime < a[i].ArrivalTime || b[i].DepartureTime < a[i].DepartureTime {
+			return false
+		}
 	}
+	return true
+}
 
-	return idx
+// tripKey orders trips by departure from their first stop.
+type tripKey struct {
+	departure time.Time
+	day       int
+	trip      TripID
+}
+
+func keyOf(times []TripStopTime) tripKey {
+	return tripKey{departure: times[0].DepartureTime, day: times[0].ServiceDay, trip: times[0].TripID}
+}
+
+func (a tripKey) less(b tripKey) bool {
+	if a.departure != b.departure {
+		return a.departure < b.departure
+	}
+	if a.day != b.day {
+		return a.day < b.day
+	}
+	return a.trip < b.trip
 }
 
 func containsRoute(routes []RouteID, target RouteID) bool {
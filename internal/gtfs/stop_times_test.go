@@ -73,30 +73,36 @@ func TestBuildIndex(t *testing.T) {
 		t.Errorf("RoutesAtStop(stopA) = %v, want [routeR]", routes)
 	}
 
-	stops := idx.StopsOnRoute("routeR")
-	if len(stops) != 3 {
-		t.Errorf("StopsOnRoute(routeR) has %d stops, want 3", len(stops))
+	patterns := idx.RoutePatterns["routeR"]
+	if len(patterns) != 1 || idx.Patterns[patterns[0]].ID != "routeR:1" {
+		t.Fatalf("RoutePatterns[routeR] = %v, want [routeR:1]", patterns)
+	}
+	pattern := idx.Pattern("routeR:1")
+	if len(pattern.Stops) != 3 {
+		t.Errorf("pattern has %d stops, want 3", len(pattern.Stops))
 	}
 
-	seq := idx.GetStopSequence("stopB", "routeR")
-	if seq != 2 {
-		t.Errorf("GetStopSequence(stopB, routeR) = %d, want 2", seq)
+	visits := patternsAt(idx, "stopB")
+	if len(visits) != 1 || visits[0].Index != 1 {
+		t.Errorf("PatternsAtStop(stopB) = %v, want index 1 of routeR:1", visits)
 	}
 
-	trips := idx.TripsAtRouteStop("routeR", 1)
-	if len(trips) != 2 {
-		t.Errorf("TripsAtRouteStop(routeR, 1) has %d trips, want 2", len(trips))
+	if pattern.NumTrips() != 2 {
+		t.Errorf("pattern has %d trips, want 2", pattern.NumTrips())
 	}
-	if trips[0].TripID != "trip1" {
-		t.Errorf("first trip should be trip1 (earlier), got %s", trips[0].TripID)
+	if pattern.TripIDs[0] != "trip1" {
+		t.Errorf("first trip should be trip1 (earlier), got %s", pattern.TripIDs[0])
 	}
 }
 
 func TestEarliestTrip(t *testing.T) {
 	stopTimes := []gtfs.StopTime{
 		{TripID: "trip1", StopID: "stopA", ArrivalTime: 8 * 3600, DepartureTime: 8 * 3600, StopSequence: 1},
+		{TripID: "trip1", StopID: "stopB", ArrivalTime: 8*3600 + 600, DepartureTime: 8*3600 + 600, StopSequence: 2},
 		{TripID: "trip2", StopID: "stopA", ArrivalTime: 9 * 3600, DepartureTime: 9 * 3600, StopSequence: 1},
+		{TripID: "trip2", StopID: "stopB", ArrivalTime: 9*3600 + 600, DepartureTime: 9*3600 + 600, StopSequence: 2},
 		{TripID: "trip3", StopID: "stopA", ArrivalTime: 10 * 3600, DepartureTime: 10 * 3600, StopSequence: 1},
+		{TripID: "trip3", StopID: "stopB", ArrivalTime: 10*3600 + 600, DepartureTime: 10*3600 + 600, StopSequence: 2},
 	}
 
 	tripRoutes := gtfs.TripToRoute{
@@ -119,7 +125,7 @@ func TestEarliestTrip(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		trip := idx.EarliestTrip("routeR", 1, tt.minTime)
+		trip := idx.EarliestTrip("routeR:1", 0, tt.minTime)
 		if tt.expected == "" {
 			if trip != nil {
 				t.Errorf("EarliestTrip(minTime=%v) = %v, want nil", tt.minTime, trip.TripID)
@@ -1,6 +1,8 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -48,6 +50,7 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/route", s.handleRoute)
 	mux.HandleFunc("/nearest", s.handleNearest)
 	mux.HandleFunc("/graph", s.handleGraph)
+	mux.HandleFunc("/stats", s.handleStats)
 	mux.HandleFunc("/status", s.handleStatus)
 	mux.HandleFunc("/graph-visual", s.handleGraphVisual)
 	return mux
@@ -137,6 +140,80 @@ func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if s.engine.GetGraph() == nil {
+		http.Error(w, "graph not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newStatsResponse(s.engine.Stats())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// statsResponse is the JSON body of /stats.
+type statsResponse struct {
+	Nodes int `json:"nodes"`
+	Edges int `json:"edges"`
+	Links int `json:"links"`
+
+	TotalLengthM      float64       `json:"total_length_m"`
+	AvgEdgeLengthM    float64       `json:"avg_edge_length_m"`
+	OnewayLengthShare float64       `json:"oneway_length_share"`
+	LengthByClass     []classLength `json:"length_by_class"`
+	Degrees           []int         `json:"degrees"`
+
+	BBox        bbox   `json:"bbox"`
+	Centroid    latLon `json:"centroid"`
+	MemoryBytes int64  `json:"memory_bytes"`
+
+	Components []int  `json:"components"`
+	Islands    int    `json:"islands"`
+	Profile    string `json:"profile,omitempty"`
+}
+
+type classLength struct {
+	Class   string  `json:"class"`
+	LengthM float64 `json:"length_m"`
+	Links   int     `json:"links"`
+}
+
+type bbox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+type latLon struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func newStatsResponse(stats engine.GraphStats) statsResponse {
+	classes := make([]classLength, len(stats.LengthByClass))
+	for i, c := range stats.LengthByClass {
+		classes[i] = classLength{Class: c.Class, LengthM: c.LengthM, Links: c.Links}
+	}
+	return statsResponse{
+		Nodes:             stats.Nodes,
+		Edges:             stats.Edges,
+		Links:             stats.Links,
+		TotalLengthM:      stats.TotalLengthM,
+		AvgEdgeLengthM:    stats.AvgEdgeLengthM,
+		OnewayLengthShare: stats.OnewayLengthShare,
+		LengthByClass:     classes,
+		Degrees:           stats.Degrees,
+		BBox:              bbox(stats.BBox),
+		Centroid:          latLon(stats.Centroid),
+		MemoryBytes:       stats.MemoryBytes,
+		Components:        stats.Components,
+		Islands:           stats.Islands,
+		Profile:           stats.Profile,
+	}
+}
+
 // WARN: To works need to do fetch on client side with from and to parameters
 func (s *Server) handleRoute(w http.ResponseWriter, r *http.Request) {
 	fromStr := r.URL.Query().Get("from")
@@ -158,11 +235,25 @@ func (s *Server) handleRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	profile := s.engine.Profile()
+	if name := r.URL.Query().Get("profile"); name != "" {
+		profile, err = mobility.New(name, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	res, err := s.engine.Route(engine.RouteRequest{
-		From:    fromID,
-		To:      toID,
-		Profile: mobility.NewWalking(1.4),
+		From:             fromID,
+		To:               toID,
+		Profile:          profile,
+		IncludeElevation: true,
 	})
+	if errors.Is(err, engine.ErrProfileMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -181,7 +272,26 @@ func (s *Server) handleRoute(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	b := geojson.PathToGeoJSON(g, ids)
+	chart := make([][2]float64, len(res.ElevationProfile))
+	for i, p := range res.ElevationProfile {
+		chart[i] = [2]float64{p.DistanceM, p.ElevationM}
+	}
+
+	notes := make([]map[string]any, len(res.Notes))
+	for i, n := range res.Notes {
+		notes[i] = map[string]any{"from": n.From, "to": n.To, "note": n.Note}
+	}
+
+	b := geojson.RouteToGeoJSON(g, ids, map[string]any{
+		"route":             true,
+		"profile":           profile.Name(),
+		"notes":             notes,
+		"distance_m":        res.Distance,
+		"duration_s":        res.Duration.Seconds(),
+		"elevation_gain_m":  res.ElevationGain,
+		"elevation_loss_m":  res.ElevationLoss,
+		"elevation_profile": chart,
+	})
 
 	w.Write(b)
 }
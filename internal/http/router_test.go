@@ -1,6 +1,7 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -31,3 +32,32 @@ func TestServer_Status(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestServer_Stats(t *testing.T) {
+	e := engine.New()
+	handler := NewServer(e).Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a graph: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	if err := e.LoadOSM("../../examples/example.osm"); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var stats statsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Nodes != len(e.GetGraph().Nodes) || stats.TotalLengthM <= 0 || len(stats.LengthByClass) == 0 {
+		t.Errorf("stats = %+v, want the example network", stats)
+	}
+}
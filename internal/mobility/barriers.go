@@ -0,0 +1,181 @@
+package mobility
+
+// Mode selects the access tags and barrier rules that apply when passing a
+// node.
+type Mode string
+
+const (
+	ModeFoot       Mode = "foot"
+	ModeWheelchair Mode = "wheelchair"
+	ModeBicycle    Mode = "bicycle"
+	ModeCar        Mode = "car"
+)
+
+// Modes lists the valid modes.
+var Modes = []Mode{ModeFoot, ModeWheelchair, ModeBicycle, ModeCar}
+
+func validMode(m Mode) bool {
+	for _, valid := range Modes {
+		if m == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Waiting times, in seconds, at controlled places.
+const (
+	// ElevatorDelay covers calling an elevator and riding it.
+	ElevatorDelay = 45
+	// SignalCrossingDelay is the average wait for a pedestrian green.
+	SignalCrossingDelay = 20
+	// UncontrolledCrossingDelay is the time to check traffic at a zebra or
+	// marked crossing.
+	UncontrolledCrossingDelay = 5
+	// UnmarkedCrossingDelay is the longer wait for a gap in traffic where
+	// nothing gives pedestrians priority.
+	UnmarkedCrossingDelay = 8
+	// CrossingYieldDelay is a motorist's delay for giving way at a crossing.
+	CrossingYieldDelay = 2
+	// LevelCrossingDelay is the time to cross railway tracks carefully.
+	LevelCrossingDelay = 5
+)
+
+// modeAccessKeys lists, most specific first, the tags that can override a
+// barrier's default for each mode.
+var modeAccessKeys = map[Mode][]string{
+	ModeFoot:       {"foot", "access"},
+	ModeWheelchair: {"wheelchair", "foot", "access"},
+	ModeBicycle:    {"bicycle", "vehicle", "access"},
+	ModeCar:        {"motorcar", "motor_vehicle", "vehicle", "access"},
+}
+
+// barrierRule is the default behaviour of a barrier type per mode. Modes
+// missing from delay cannot pass.
+type barrierRule map[Mode]float64
+
+var barrierRules = map[string]barrierRule{
+	"gate":          {ModeFoot: 5, ModeWheelchair: 10, ModeBicycle: 5, ModeCar: 15},
+	"swing_gate":    {ModeFoot: 5, ModeWheelchair: 10, ModeBicycle: 5, ModeCar: 15},
+	"lift_gate":     {ModeFoot: 0, ModeWheelchair: 0, ModeBicycle: 2, ModeCar: 10},
+	"bollard":       {ModeFoot: 0, ModeWheelchair: 0, ModeBicycle: 0},
+	"block":         {ModeFoot: 0, ModeWheelchair: 0, ModeBicycle: 0},
+	"cycle_barrier": {ModeFoot: 0, ModeWheelchair: 5, ModeBicycle: 5},
+	"chain":         {ModeFoot: 2, ModeBicycle: 5},
+	"kissing_gate":  {ModeFoot: 5},
+	"turnstile":     {ModeFoot: 3},
+	"stile":         {ModeFoot: 10},
+	"toll_booth":    {ModeFoot: 0, ModeWheelchair: 0, ModeBicycle: 0, ModeCar: 20},
+	"entrance":      {ModeFoot: 0, ModeWheelchair: 0, ModeBicycle: 0, ModeCar: 0},
+}
+
+// NodePassage applies the node semantics shared by every built-in
+// profile: barriers with their access overrides, elevators, and the wait
+// at crossings and traffic signals.
+//
+// Crossing delays apply to every edge entering the node, including one
+// following the road past it, which slightly overstates the time for
+// pedestrians walking along a carriageway.
+func NodePassage(tags map[string]string, mode Mode) Cost {
+	if barrier := tags["barrier"]; barrier != "" {
+		if c, ok := barrierPassage(barrier, tags, mode); ok {
+			return c
+		}
+	}
+
+	if tags["highway"] == "elevator" {
+		return elevatorPassage(tags, mode)
+	}
+
+	return Cost{Seconds: crossingDelay(tags, mode)}
+}
+
+// barrierPassage reports ok=false for barriers it does not know, such as
+// kerbs, which are left to the profile.
+func barrierPassage(barrier string, tags map[string]string, mode Mode) (Cost, bool) {
+	rule, known := barrierRules[barrier]
+	if !known {
+		return Cost{}, false
+	}
+	if tags["locked"] == "yes" {
+		return Cost{Forbidden: true}, true
+	}
+
+	delay, pass := rule[mode]
+	if v, ok := modeAccess(tags, mode); ok {
+		switch {
+		case isNo(v):
+			return Cost{Forbidden: true}, true
+		case isYes(v) && !pass:
+			// Explicitly opened to a mode that would not fit by default,
+			// e.g. a wide bollard gap tagged motor_vehicle=yes.
+			return Cost{}, true
+		}
+	}
+	if !pass {
+		return Cost{Forbidden: true}, true
+	}
+	return Cost{Seconds: delay}, true
+}
+
+func elevatorPassage(tags map[string]string, mode Mode) Cost {
+	if mode == ModeCar {
+		return Cost{Forbidden: true}
+	}
+	if v, ok := modeAccess(tags, mode); ok && isNo(v) {
+		return Cost{Forbidden: true}
+	}
+
+	c := Cost{Seconds: ElevatorDelay}
+	if mode == ModeWheelchair && tags["wheelchair"] == "" {
+		c.Note = "elevator not confirmed wheelchair accessible"
+	}
+	return c
+}
+
+func crossingDelay(tags map[string]string, mode Mode) float64 {
+	if tags["railway"] == "level_crossing" || tags["railway"] == "crossing" {
+		return LevelCrossingDelay
+	}
+
+	signals := tags["highway"] == "traffic_signals" || tags["crossing"] == "traffic_signals"
+	if mode == ModeCar {
+		switch {
+		case signals:
+			return TrafficSignalDelay
+		case isCrossing(tags) && tags["crossing"] != "no" && tags["crossing"] != "unmarked":
+			return CrossingYieldDelay
+		}
+		return 0
+	}
+
+	switch {
+	case signals:
+		return SignalCrossingDelay
+	case tags["crossing"] == "unmarked":
+		return UnmarkedCrossingDelay
+	case isCrossing(tags) && tags["crossing"] != "no":
+		return UncontrolledCrossingDelay
+	}
+	return 0
+}
+
+// modeAccess returns the most specific access tag set for mode.
+func modeAccess(tags map[string]string, mode Mode) (string, bool) {
+	for _, key := range modeAccessKeys[mode] {
+		if v, ok := tags[key]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// combine adds the costs of two independent rules for the same node.
+func (c Cost) combine(o Cost) Cost {
+	return Cost{
+		Seconds:   c.Seconds + o.Seconds,
+		Penalty:   c.Penalty + o.Penalty,
+		Forbidden: c.Forbidden || o.Forbidden,
+		Note:      joinNotes(c.Note, o.Note),
+	}
+}
@@ -0,0 +1,92 @@
+package mobility_test
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+func TestNodePassage(t *testing.T) {
+	tags := func(kv ...string) map[string]string {
+		m := map[string]string{}
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		mode      mobility.Mode
+		forbidden bool
+		seconds   float64
+	}{
+		{"plain node", tags(), mobility.ModeFoot, false, 0},
+		{"gate on foot", tags("barrier", "gate"), mobility.ModeFoot, false, 5},
+		{"gate by car", tags("barrier", "gate"), mobility.ModeCar, false, 15},
+		{"locked gate", tags("barrier", "gate", "locked", "yes"), mobility.ModeFoot, true, 0},
+		{"private gate", tags("barrier", "gate", "access", "private"), mobility.ModeFoot, true, 0},
+		{"private gate foot=yes", tags("barrier", "gate", "access", "private", "foot", "yes"), mobility.ModeFoot, false, 5},
+		{"bollard on foot", tags("barrier", "bollard"), mobility.ModeFoot, false, 0},
+		{"bollard by car", tags("barrier", "bollard"), mobility.ModeCar, true, 0},
+		{"bollard motor_vehicle=yes", tags("barrier", "bollard", "motor_vehicle", "yes"), mobility.ModeCar, false, 0},
+		{"lift gate by car", tags("barrier", "lift_gate"), mobility.ModeCar, false, 10},
+		{"kissing gate on foot", tags("barrier", "kissing_gate"), mobility.ModeFoot, false, 5},
+		{"kissing gate by bicycle", tags("barrier", "kissing_gate"), mobility.ModeBicycle, true, 0},
+		{"kissing gate in wheelchair", tags("barrier", "kissing_gate"), mobility.ModeWheelchair, true, 0},
+		{"unknown barrier", tags("barrier", "kerb"), mobility.ModeFoot, false, 0},
+		{"elevator on foot", tags("highway", "elevator"), mobility.ModeFoot, false, mobility.ElevatorDelay},
+		{"elevator by car", tags("highway", "elevator"), mobility.ModeCar, true, 0},
+		{"elevator bicycle=no", tags("highway", "elevator", "bicycle", "no"), mobility.ModeBicycle, true, 0},
+		{"signals on foot", tags("highway", "traffic_signals"), mobility.ModeFoot, false, mobility.SignalCrossingDelay},
+		{"signals by car", tags("highway", "traffic_signals"), mobility.ModeCar, false, mobility.TrafficSignalDelay},
+		{"zebra on foot", tags("highway", "crossing", "crossing", "zebra"), mobility.ModeFoot, false, mobility.UncontrolledCrossingDelay},
+		{"zebra by car", tags("highway", "crossing", "crossing", "zebra"), mobility.ModeCar, false, mobility.CrossingYieldDelay},
+		{"unmarked on foot", tags("highway", "crossing", "crossing", "unmarked"), mobility.ModeFoot, false, mobility.UnmarkedCrossingDelay},
+		{"unmarked by car", tags("highway", "crossing", "crossing", "unmarked"), mobility.ModeCar, false, 0},
+		{"level crossing", tags("railway", "level_crossing"), mobility.ModeCar, false, mobility.LevelCrossingDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mobility.NodePassage(tt.tags, tt.mode)
+			if c.Forbidden != tt.forbidden {
+				t.Errorf("Forbidden = %v, want %v", c.Forbidden, tt.forbidden)
+			}
+			if !tt.forbidden && c.Seconds != tt.seconds {
+				t.Errorf("Seconds = %v, want %v", c.Seconds, tt.seconds)
+			}
+		})
+	}
+}
+
+func TestNodeCost_Profiles(t *testing.T) {
+	gate := mobility.NodeInfo{Tags: map[string]string{"barrier": "gate", "locked": "yes"}, Degree: 2}
+	for _, name := range mobility.Available() {
+		p, err := mobility.New(name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := mobility.NodeCost(p, gate); !c.Forbidden {
+			t.Errorf("%s passes a locked gate", name)
+		}
+	}
+
+	wheelchair := mobility.NewWheelchair(0)
+	lift := mobility.NodeInfo{Tags: map[string]string{"highway": "elevator"}}
+	c := mobility.NodeCost(wheelchair, lift)
+	if c.Forbidden || c.Seconds != mobility.ElevatorDelay || c.Note == "" {
+		t.Errorf("unverified elevator = %+v, want delay with a note", c)
+	}
+
+	crossing := mobility.NodeInfo{Tags: map[string]string{"highway": "crossing", "crossing": "zebra", "kerb": "rolled"}, Degree: 4}
+	if c := mobility.NodeCost(wheelchair, crossing); c.Seconds <= mobility.UncontrolledCrossingDelay {
+		t.Errorf("rolled kerb at zebra = %vs, want kerb and crossing time combined", c.Seconds)
+	}
+
+	driving := mobility.NewDriving(0)
+	if c := mobility.NodeCost(driving, mobility.NodeInfo{Tags: map[string]string{"barrier": "bollard"}, Degree: 2}); !c.Forbidden {
+		t.Error("driving passes a bollard")
+	}
+}
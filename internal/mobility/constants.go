@@ -3,4 +3,9 @@ package mobility
 const (
 	DefaultWalkingSpeedMPS = 1.4
 	DefaultDrivingSpeedMPS = 13.9
+	// DefaultWheelchairSpeedMPS is a typical manual wheelchair pace on
+	// smooth, level pavement.
+	DefaultWheelchairSpeedMPS = 1.0
+	// DefaultCyclingSpeedMPS is a relaxed urban cruising speed (15 km/h).
+	DefaultCyclingSpeedMPS = 4.2
 )
@@ -0,0 +1,226 @@
+package mobility
+
+import "math"
+
+// CyclableHighways lists the highway classes bicycles may use unless tagged
+// otherwise. Footways and pedestrian areas need an explicit bicycle tag.
+var CyclableHighways = map[string]bool{
+	"cycleway":       true,
+	"path":           true,
+	"track":          true,
+	"residential":    true,
+	"living_street":  true,
+	"service":        true,
+	"unclassified":   true,
+	"road":           true,
+	"tertiary":       true,
+	"tertiary_link":  true,
+	"secondary":      true,
+	"secondary_link": true,
+	"primary":        true,
+	"primary_link":   true,
+}
+
+// Classes cyclists may use only with an explicit bicycle=yes or similar.
+var cyclingOnlyIfTagged = map[string]bool{
+	"footway":    true,
+	"pedestrian": true,
+	"bridleway":  true,
+	"steps":      true,
+	"trunk":      true,
+	"trunk_link": true,
+}
+
+// cyclingSurfaceFactor scales the flat speed by surface. Unlisted surfaces
+// are assumed paved.
+var cyclingSurfaceFactor = map[string]float64{
+	"paving_stones":      0.85,
+	"compacted":          0.8,
+	"fine_gravel":        0.75,
+	"wood":               0.8,
+	"metal":              0.8,
+	"sett":               0.6,
+	"cobblestone":        0.5,
+	"unhewn_cobblestone": 0.4,
+	"gravel":             0.6,
+	"pebblestone":        0.5,
+	"unpaved":            0.6,
+	"ground":             0.5,
+	"dirt":               0.5,
+	"earth":              0.5,
+	"grass":              0.4,
+	"grass_paver":        0.6,
+	"sand":               0.3,
+	"mud":                0.3,
+}
+
+// cyclingStress rates how unpleasant a highway class is to ride without
+// dedicated infrastructure, as a fraction of extra perceived time.
+var cyclingStress = map[string]float64{
+	"living_street":  0,
+	"residential":    0.2,
+	"service":        0.2,
+	"track":          0.2,
+	"path":           0.1,
+	"unclassified":   0.3,
+	"road":           0.3,
+	"tertiary":       0.6,
+	"tertiary_link":  0.6,
+	"secondary":      1.0,
+	"secondary_link": 1.0,
+	"primary":        1.5,
+	"primary_link":   1.5,
+	"trunk":          2.0,
+	"trunk_link":     2.0,
+}
+
+// CyclingOptions tunes the cycling profile.
+type CyclingOptions struct {
+	// Speed is the flat-ground cruising speed in m/s.
+	Speed float64
+	// InfrastructurePreference trades speed for comfort: 0 takes the
+	// fastest path, 1 accepts a detour of up to about 2.5 times the riding
+	// time on busy roads to stay on cycleways and quiet streets.
+	InfrastructurePreference float64
+}
+
+// DefaultInfrastructurePreference leans towards cycleways without
+// sending riders on long detours.
+const DefaultInfrastructurePreference = 0.5
+
+type cyclingProfile struct {
+	basicProfile
+	preference float64
+}
+
+func NewCycling(speed float64) Profile {
+	return NewCyclingWithOptions(CyclingOptions{
+		Speed:                    speed,
+		InfrastructurePreference: DefaultInfrastructurePreference,
+	})
+}
+
+func NewCyclingWithOptions(opts CyclingOptions) Profile {
+	if opts.Speed <= 0 {
+		opts.Speed = DefaultCyclingSpeedMPS
+	}
+	return cyclingProfile{
+		basicProfile: basicProfile{name: "cycling", speed: opts.Speed},
+		preference:   math.Max(0, math.Min(1, opts.InfrastructurePreference)),
+	}
+}
+
+func (p cyclingProfile) WayAccess(tags map[string]string) (bool, bool) {
+	highway := tags["highway"]
+	bicycle := tags["bicycle"]
+
+	switch {
+	case isNo(bicycle):
+		return false, false
+	case isYes(bicycle) || bicycle == "dismount":
+		if highway == "" || highway == "motorway" || highway == "motorway_link" {
+			return false, false
+		}
+	case CyclableHighways[highway]:
+		if isNo(tags["access"]) || tags["vehicle"] == "no" {
+			return false, false
+		}
+	case cyclingOnlyIfTagged[highway]:
+		return false, false
+	default:
+		return false, false
+	}
+
+	return p.direction(tags)
+}
+
+// direction applies oneway rules, honouring the cycling exceptions that
+// let bicycles ride against the traffic flow.
+func (p cyclingProfile) direction(tags map[string]string) (bool, bool) {
+	oneway := tags["oneway"]
+	if v, ok := tags["oneway:bicycle"]; ok {
+		oneway = v
+	}
+	if oneway == "" && tags["junction"] == "roundabout" {
+		oneway = "yes"
+	}
+
+	switch oneway {
+	case "yes", "true", "1":
+		if contraflow(tags) {
+			return true, true
+		}
+		return true, false
+	case "-1", "reverse":
+		if contraflow(tags) {
+			return true, true
+		}
+		return false, true
+	}
+	return true, true
+}
+
+func contraflow(tags map[string]string) bool {
+	for _, key := range []string{"cycleway", "cycleway:left", "cycleway:right", "cycleway:both"} {
+		switch tags[key] {
+		case "opposite", "opposite_lane", "opposite_track", "opposite_share_busway":
+			return true
+		}
+	}
+	return false
+}
+
+// dedicated reports whether a way has infrastructure reserved for bicycles.
+func dedicated(tags map[string]string) bool {
+	if tags["highway"] == "cycleway" || tags["bicycle"] == "designated" {
+		return true
+	}
+	for _, key := range []string{"cycleway", "cycleway:left", "cycleway:right", "cycleway:both"} {
+		switch tags[key] {
+		case "lane", "track", "opposite_lane", "opposite_track", "share_busway":
+			return true
+		}
+	}
+	return false
+}
+
+func (p cyclingProfile) SegmentCost(s Segment) Cost {
+	if p.speed <= 0 {
+		return Cost{}
+	}
+
+	speed := p.speed
+	if s.Tags["bicycle"] == "dismount" || s.Tags["highway"] == "steps" {
+		speed = DefaultWalkingSpeedMPS
+	} else {
+		if f, ok := cyclingSurfaceFactor[s.Tags["surface"]]; ok {
+			speed *= f
+		}
+		speed *= cyclingGradeFactor(s.Grade)
+	}
+
+	seconds := s.DistanceM / speed
+
+	stress := 0.0
+	if !dedicated(s.Tags) {
+		stress = cyclingStress[s.Tags["highway"]]
+	}
+
+	return Cost{
+		Seconds: seconds,
+		Penalty: seconds * stress * p.preference,
+	}
+}
+
+func (p cyclingProfile) NodeCost(n NodeInfo) Cost {
+	return NodePassage(n.Tags, ModeBicycle)
+}
+
+// cyclingGradeFactor slows riders on climbs and lets them roll faster on
+// descents, capped so that a steep hill does not look like a motorway.
+func cyclingGradeFactor(grade float64) float64 {
+	if grade >= 0 {
+		return 1 / (1 + 10*grade)
+	}
+	return math.Min(1.5, 1-5*grade)
+}
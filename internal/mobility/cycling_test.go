@@ -0,0 +1,80 @@
+package mobility_test
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+func TestCycling_WayAccess(t *testing.T) {
+	p := mobility.NewCycling(0).(mobility.WayFilter)
+
+	tests := []struct {
+		name              string
+		tags              map[string]string
+		forward, backward bool
+	}{
+		{"cycleway", map[string]string{"highway": "cycleway"}, true, true},
+		{"residential", map[string]string{"highway": "residential"}, true, true},
+		{"footway", map[string]string{"highway": "footway"}, false, false},
+		{"footway bicycle=yes", map[string]string{"highway": "footway", "bicycle": "yes"}, true, true},
+		{"pedestrian dismount", map[string]string{"highway": "pedestrian", "bicycle": "dismount"}, true, true},
+		{"bicycle=no", map[string]string{"highway": "residential", "bicycle": "no"}, false, false},
+		{"motorway", map[string]string{"highway": "motorway"}, false, false},
+		{"motorway bicycle=yes", map[string]string{"highway": "motorway", "bicycle": "yes"}, false, false},
+		{"oneway", map[string]string{"highway": "residential", "oneway": "yes"}, true, false},
+		{"reverse oneway", map[string]string{"highway": "residential", "oneway": "-1"}, false, true},
+		{"oneway:bicycle=no", map[string]string{"highway": "residential", "oneway": "yes", "oneway:bicycle": "no"}, true, true},
+		{"contraflow lane", map[string]string{"highway": "residential", "oneway": "yes", "cycleway:left": "opposite_lane"}, true, true},
+		{"roundabout", map[string]string{"highway": "tertiary", "junction": "roundabout"}, true, false},
+		{"private", map[string]string{"highway": "service", "access": "private"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, backward := p.WayAccess(tt.tags)
+			if forward != tt.forward || backward != tt.backward {
+				t.Errorf("WayAccess(%v) = (%v, %v), want (%v, %v)", tt.tags, forward, backward, tt.forward, tt.backward)
+			}
+		})
+	}
+}
+
+func TestCycling_SegmentCost(t *testing.T) {
+	fastest := mobility.NewCyclingWithOptions(mobility.CyclingOptions{Speed: 5})
+	comfort := mobility.NewCyclingWithOptions(mobility.CyclingOptions{Speed: 5, InfrastructurePreference: 1})
+
+	seg := func(p mobility.Profile, grade float64, tags map[string]string) mobility.Cost {
+		return mobility.SegmentCost(p, mobility.Segment{DistanceM: 100, Grade: grade, Tags: tags})
+	}
+
+	asphalt := seg(fastest, 0, map[string]string{"highway": "primary"})
+	if asphalt.Seconds != 20 || asphalt.Penalty != 0 {
+		t.Errorf("fastest on primary = %+v, want 20s without penalty", asphalt)
+	}
+
+	gravel := seg(fastest, 0, map[string]string{"highway": "track", "surface": "gravel"})
+	if gravel.Seconds <= asphalt.Seconds {
+		t.Errorf("gravel (%vs) should be slower than asphalt (%vs)", gravel.Seconds, asphalt.Seconds)
+	}
+
+	busy := seg(comfort, 0, map[string]string{"highway": "primary"})
+	lane := seg(comfort, 0, map[string]string{"highway": "primary", "cycleway:right": "lane"})
+	if busy.Seconds != lane.Seconds {
+		t.Errorf("preference must not change riding time: %v vs %v", busy.Seconds, lane.Seconds)
+	}
+	if busy.Penalty <= 0 || lane.Penalty != 0 {
+		t.Errorf("penalties busy=%v lane=%v, want busy > 0 and lane = 0", busy.Penalty, lane.Penalty)
+	}
+
+	dismount := seg(fastest, 0, map[string]string{"highway": "pedestrian", "bicycle": "dismount"})
+	if want := 100 / mobility.DefaultWalkingSpeedMPS; dismount.Seconds != want {
+		t.Errorf("dismount = %vs, want walking time %vs", dismount.Seconds, want)
+	}
+
+	climb := seg(fastest, 0.05, map[string]string{"highway": "residential"})
+	descent := seg(fastest, -0.05, map[string]string{"highway": "residential"})
+	if !(climb.Seconds > asphalt.Seconds && descent.Seconds < asphalt.Seconds) {
+		t.Errorf("climb=%vs flat=%vs descent=%vs, want climb slowest and descent fastest", climb.Seconds, asphalt.Seconds, descent.Seconds)
+	}
+}
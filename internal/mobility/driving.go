@@ -0,0 +1,143 @@
+package mobility
+
+// DrivingHighwaySpeeds holds the fallback speed in km/h for each drivable
+// highway class when no usable maxspeed is tagged.
+var DrivingHighwaySpeeds = map[string]float64{
+	"motorway":       110,
+	"motorway_link":  60,
+	"trunk":          90,
+	"trunk_link":     50,
+	"primary":        60,
+	"primary_link":   40,
+	"secondary":      50,
+	"secondary_link": 40,
+	"tertiary":       40,
+	"tertiary_link":  30,
+	"unclassified":   30,
+	"residential":    30,
+	"road":           30,
+	"living_street":  10,
+	"service":        15,
+}
+
+// Node delays in seconds, averaged over arrivals on green and red.
+const (
+	TrafficSignalDelay = 8
+	StopSignDelay      = 4
+	GiveWayDelay       = 2
+	JunctionDelay      = 2
+)
+
+// motorVehicleAccessKeys are checked from most to least specific; the
+// first one present decides.
+var motorVehicleAccessKeys = []string{"motorcar", "motor_vehicle", "vehicle", "access"}
+
+type drivingProfile struct {
+	basicProfile
+}
+
+// NewDriving returns a car profile. Speed is only the nominal speed used to
+// scale reported durations; edge speeds come from maxspeed and road class.
+func NewDriving(speed float64) Profile {
+	if speed <= 0 {
+		speed = DefaultDrivingSpeedMPS
+	}
+	return drivingProfile{basicProfile{name: "driving", speed: speed}}
+}
+
+func (p drivingProfile) WayAccess(tags map[string]string) (bool, bool) {
+	highway := tags["highway"]
+	_, drivable := DrivingHighwaySpeeds[highway]
+	if highway == "track" {
+		// Tracks are only for cars when explicitly opened to them.
+		drivable = false
+		for _, key := range motorVehicleAccessKeys[:3] {
+			if isYes(tags[key]) {
+				drivable = true
+			}
+		}
+	}
+	if !drivable || !motorVehicleAllowed(tags) {
+		return false, false
+	}
+	return drivingDirection(tags)
+}
+
+func motorVehicleAllowed(tags map[string]string) bool {
+	for _, key := range motorVehicleAccessKeys {
+		v, ok := tags[key]
+		if !ok {
+			continue
+		}
+		switch v {
+		case "no", "private", "agricultural", "forestry", "emergency", "psv", "bus", "delivery":
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+func drivingDirection(tags map[string]string) (bool, bool) {
+	oneway := tags["oneway"]
+	if oneway == "" {
+		switch {
+		case tags["junction"] == "roundabout", tags["junction"] == "circular",
+			tags["highway"] == "motorway", tags["highway"] == "motorway_link":
+			oneway = "yes"
+		}
+	}
+
+	switch oneway {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	case "reversible", "alternating":
+		// Direction changes over the day; without the schedule the way
+		// cannot be relied on in either direction.
+		return false, false
+	}
+	return true, true
+}
+
+// SpeedKmh returns the speed a car travels along a way in the given
+// direction: the tagged maxspeed when it parses, otherwise the road class
+// fallback.
+func (p drivingProfile) SpeedKmh(tags map[string]string, reverse bool) float64 {
+	directional := "maxspeed:forward"
+	if reverse {
+		directional = "maxspeed:backward"
+	}
+	for _, key := range []string{directional, "maxspeed", "maxspeed:type", "source:maxspeed"} {
+		if speed, ok := ParseMaxSpeed(tags[key]); ok {
+			return speed
+		}
+	}
+	if speed, ok := DrivingHighwaySpeeds[tags["highway"]]; ok {
+		return speed
+	}
+	return DrivingHighwaySpeeds["road"]
+}
+
+func (p drivingProfile) SegmentCost(s Segment) Cost {
+	speed := p.SpeedKmh(s.Tags, s.Reverse) / 3.6
+	return Cost{Seconds: s.DistanceM / speed}
+}
+
+func (p drivingProfile) NodeCost(n NodeInfo) Cost {
+	c := NodePassage(n.Tags, ModeCar)
+	if c.Forbidden || c.Seconds > 0 {
+		return c
+	}
+	switch n.Tags["highway"] {
+	case "stop":
+		return Cost{Seconds: StopSignDelay}
+	case "give_way":
+		return Cost{Seconds: GiveWayDelay}
+	}
+	if n.IsJunction() {
+		return Cost{Seconds: JunctionDelay}
+	}
+	return c
+}
@@ -0,0 +1,123 @@
+package mobility_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+func TestParseMaxSpeed(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"50", 50, true},
+		{"50 km/h", 50, true},
+		{"30 mph", 48.28032, true},
+		{"10 knots", 18.52, true},
+		{"walk", 6, true},
+		{"DE:urban", 50, true},
+		{"FR:rural", 80, true},
+		{"BR:urban", 60, true},
+		{"BR:rural", 100, true},
+		{"BR:motorway", 110, true},
+		{"DE:zone30", 30, true},
+		{"DE:zone:20", 20, true},
+		{"60;40", 60, true},
+		{"none", 0, false},
+		{"signals", 0, false},
+		{"XX:urban", 0, false},
+		{"", 0, false},
+		{"NaN", 0, false},
+		{"inf", 0, false},
+		{"Infinity km/h", 0, false},
+		{"-inf mph", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := mobility.ParseMaxSpeed(tt.in)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("ParseMaxSpeed(%q) = (%v, %v), want (%v, %v)", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDriving_WayAccess(t *testing.T) {
+	p := mobility.NewDriving(0).(mobility.WayFilter)
+
+	tests := []struct {
+		name              string
+		tags              map[string]string
+		forward, backward bool
+	}{
+		{"residential", map[string]string{"highway": "residential"}, true, true},
+		{"footway", map[string]string{"highway": "footway"}, false, false},
+		{"cycleway", map[string]string{"highway": "cycleway"}, false, false},
+		{"motorway implies oneway", map[string]string{"highway": "motorway"}, true, false},
+		{"roundabout", map[string]string{"highway": "primary", "junction": "roundabout"}, true, false},
+		{"oneway -1", map[string]string{"highway": "secondary", "oneway": "-1"}, false, true},
+		{"reversible", map[string]string{"highway": "primary", "oneway": "reversible"}, false, false},
+		{"access=no", map[string]string{"highway": "service", "access": "no"}, false, false},
+		{"motorcar overrides access", map[string]string{"highway": "service", "access": "no", "motorcar": "yes"}, true, true},
+		{"motor_vehicle=no", map[string]string{"highway": "residential", "motor_vehicle": "no"}, false, false},
+		{"destination", map[string]string{"highway": "residential", "motor_vehicle": "destination"}, true, true},
+		{"track", map[string]string{"highway": "track"}, false, false},
+		{"track opened to cars", map[string]string{"highway": "track", "motor_vehicle": "yes"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, backward := p.WayAccess(tt.tags)
+			if forward != tt.forward || backward != tt.backward {
+				t.Errorf("WayAccess(%v) = (%v, %v), want (%v, %v)", tt.tags, forward, backward, tt.forward, tt.backward)
+			}
+		})
+	}
+}
+
+func TestDriving_SegmentCost(t *testing.T) {
+	p := mobility.NewDriving(0)
+
+	seg := func(tags map[string]string, reverse bool) float64 {
+		return mobility.SegmentCost(p, mobility.Segment{DistanceM: 1000, Tags: tags, Reverse: reverse}).Seconds
+	}
+
+	if got := seg(map[string]string{"highway": "residential", "maxspeed": "36"}, false); math.Abs(got-100) > 1e-9 {
+		t.Errorf("1 km at 36 km/h = %vs, want 100s", got)
+	}
+	if got := seg(map[string]string{"highway": "residential"}, false); math.Abs(got-120) > 1e-9 {
+		t.Errorf("1 km of residential fallback = %vs, want 120s", got)
+	}
+
+	tags := map[string]string{"highway": "primary", "maxspeed": "60", "maxspeed:backward": "36"}
+	if got := seg(tags, true); math.Abs(got-100) > 1e-9 {
+		t.Errorf("backward maxspeed = %vs, want 100s", got)
+	}
+	if got := seg(tags, false); math.Abs(got-60) > 1e-9 {
+		t.Errorf("forward maxspeed = %vs, want 60s", got)
+	}
+}
+
+func TestDriving_NodeCost(t *testing.T) {
+	p := mobility.NewDriving(0)
+
+	tests := []struct {
+		name string
+		node mobility.NodeInfo
+		want float64
+	}{
+		{"signals", mobility.NodeInfo{Tags: map[string]string{"highway": "traffic_signals"}, Degree: 2}, mobility.TrafficSignalDelay},
+		{"junction", mobility.NodeInfo{Degree: 3}, mobility.JunctionDelay},
+		{"plain", mobility.NodeInfo{Degree: 2}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := mobility.NodeCost(p, tt.node).Seconds; got != tt.want {
+			t.Errorf("%s: NodeCost = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
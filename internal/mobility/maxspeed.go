@@ -0,0 +1,85 @@
+package mobility
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	kmhPerMph  = 1.609344
+	kmhPerKnot = 1.852
+	// walkSpeedKmh is the speed implied by maxspeed=walk.
+	walkSpeedKmh = 6
+)
+
+// ImplicitMaxSpeeds maps the implicit maxspeed values from the OSM wiki,
+// such as "DE:urban", to km/h. Motorways without a general limit use the
+// advisory speed. Brazil's urban limit depends on the road's category,
+// from 30 km/h on local streets to 80 on expressways; "BR:urban" takes
+// the arterial 60, and "BR:rural" the single carriageway highway limit.
+var ImplicitMaxSpeeds = map[string]float64{
+	"AT:urban": 50, "AT:rural": 100, "AT:motorway": 130,
+	"BR:urban": 60, "BR:rural": 100, "BR:motorway": 110,
+	"CH:urban": 50, "CH:rural": 80, "CH:motorway": 120,
+	"DE:urban": 50, "DE:rural": 100, "DE:motorway": 130,
+	"DE:living_street": 7, "DE:bicycle_road": 30,
+	"ES:urban": 50, "ES:rural": 90, "ES:motorway": 120,
+	"FR:urban": 50, "FR:rural": 80, "FR:motorway": 130,
+	"GB:nsl_single": 60 * kmhPerMph, "GB:nsl_dual": 70 * kmhPerMph, "GB:motorway": 70 * kmhPerMph,
+	"IT:urban": 50, "IT:rural": 90, "IT:motorway": 130,
+	"NL:urban": 50, "NL:rural": 80, "NL:motorway": 100,
+	"PL:urban": 50, "PL:rural": 90, "PL:motorway": 140,
+	"PT:urban": 50, "PT:rural": 90, "PT:motorway": 120,
+	"RU:urban": 60, "RU:rural": 90, "RU:motorway": 110,
+}
+
+var zonePattern = regexp.MustCompile(`^[A-Z]{2}:zone:?(\d+)$`)
+
+// ParseMaxSpeed converts an OSM maxspeed value to km/h. It understands
+// plain numbers (km/h), explicit km/h, mph and knots units, "walk",
+// implicit country values such as "DE:urban" and zones such as "DE:zone30".
+// Values without a usable number, like "none", "signals" or "variable",
+// report ok=false so callers fall back to the road class speed. Multiple
+// values separated by ";" use the first.
+func ParseMaxSpeed(v string) (kmh float64, ok bool) {
+	v = strings.TrimSpace(v)
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+
+	if v == "walk" {
+		return walkSpeedKmh, true
+	}
+	if speed, ok := ImplicitMaxSpeeds[v]; ok {
+		return speed, true
+	}
+	if m := zonePattern.FindStringSubmatch(v); m != nil {
+		speed, err := strconv.ParseFloat(m[1], 64)
+		return speed, err == nil && speed > 0
+	}
+
+	factor := 1.0
+	lower := strings.ToLower(v)
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{
+		{"km/h", 1}, {"kmh", 1}, {"kph", 1}, {"mph", kmhPerMph}, {"knots", kmhPerKnot},
+	} {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSpace(strings.TrimSuffix(lower, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	// ParseFloat also reads "nan" and "inf", which would price edges at
+	// NaN or nothing.
+	speed, err := strconv.ParseFloat(lower, 64)
+	if err != nil || math.IsNaN(speed) || math.IsInf(speed, 0) || speed <= 0 {
+		return 0, false
+	}
+	return speed * factor, true
+}
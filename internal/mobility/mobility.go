@@ -25,6 +25,89 @@ func (p basicProfile) TravelTime(dist float64) float64 {
 	return dist / p.speed
 }
 
+// Segment is the stretch of a way between two consecutive OSM nodes, in the
+// direction it is being travelled, as seen while the graph is built.
+type Segment struct {
+	DistanceM float64
+	Grade     float64 // rise over run, positive uphill
+	Tags      map[string]string
+	// Reverse is set when travelling against the way's drawing direction,
+	// which selects tags such as maxspeed:backward.
+	Reverse bool
+}
+
+// NodeInfo describes an OSM node the graph passes through.
+type NodeInfo struct {
+	Tags map[string]string
+	// Degree counts the distinct nodes the profile's ways connect this node
+	// to. Three or more make it a junction.
+	Degree int
+}
+
+// IsJunction reports whether several ways meet at the node.
+func (n NodeInfo) IsJunction() bool {
+	return n.Degree >= 3
+}
+
+// Cost is a profile's verdict on a segment or node.
+type Cost struct {
+	Seconds float64
+	// Penalty is added to Seconds when choosing routes but is not travel
+	// time, so preferences do not inflate reported durations.
+	Penalty float64
+	// Forbidden removes the segment, or every edge through the node, from
+	// the graph.
+	Forbidden bool
+	// Note explains anything the router should tell the user, such as an
+	// accessibility attribute that could not be verified.
+	Note string
+}
+
+// SegmentCoster is implemented by profiles whose travel time depends on
+// more than distance, such as slope or surface.
+type SegmentCoster interface {
+	SegmentCost(s Segment) Cost
+}
+
+// NodeCoster is implemented by profiles that react to nodes, such as kerbs,
+// barriers or junctions. The returned seconds are added when passing the
+// node.
+type NodeCoster interface {
+	NodeCost(n NodeInfo) Cost
+}
+
+// WayFilter is implemented by profiles with their own access rules. Ways
+// for other profiles are selected by the pedestrian filter in package osm.
+type WayFilter interface {
+	WayAccess(tags map[string]string) (forward, backward bool)
+}
+
+// TurnCoster is implemented by profiles that charge for turning at
+// junctions. Deflection is the change of heading in degrees, positive for
+// right turns and ±180 for a U-turn.
+type TurnCoster interface {
+	TurnCost(deflection float64) float64
+	HasTurnCosts() bool
+}
+
+// SegmentCost prices s for p, falling back to distance over speed for
+// profiles that do not inspect segments.
+func SegmentCost(p Profile, s Segment) Cost {
+	if c, ok := p.(SegmentCoster); ok {
+		return c.SegmentCost(s)
+	}
+	return Cost{Seconds: p.TravelTime(s.DistanceM)}
+}
+
+// NodeCost prices passing n. Profiles that ignore nodes pass every node
+// for free.
+func NodeCost(p Profile, n NodeInfo) Cost {
+	if c, ok := p.(NodeCoster); ok {
+		return c.NodeCost(n)
+	}
+	return Cost{}
+}
+
 type Factory func(speed float64) Profile
 
 var registry = map[string]Factory{}
@@ -60,21 +143,9 @@ func Available() []string {
 	return keys
 }
 
-func NewWalking(speed float64) Profile {
-	if speed <= 0 {
-		speed = DefaultWalkingSpeedMPS
-	}
-	return basicProfile{name: "walking", speed: speed}
-}
-
-func NewDriving(speed float64) Profile {
-	if speed <= 0 {
-		speed = DefaultDrivingSpeedMPS
-	}
-	return basicProfile{name: "driving", speed: speed}
-}
-
 func init() {
 	Register("walking", NewWalking)
 	Register("driving", NewDriving)
+	Register("wheelchair", NewWheelchair)
+	Register("cycling", NewCycling)
 }
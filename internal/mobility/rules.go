@@ -0,0 +1,334 @@
+package mobility
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TagMatch selects OSM elements by tag. Every key must match one of its
+// listed values; "*" accepts any non-empty value and "" accepts an absent
+// tag.
+type TagMatch map[string][]string
+
+func (m TagMatch) Matches(tags map[string]string) bool {
+	for key, values := range m {
+		v := tags[key]
+		ok := false
+		for _, want := range values {
+			if want == v || (want == "*" && v != "") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m TagMatch) validate(field string) []error {
+	var errs []error
+	for _, key := range sortedKeys(m) {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("%s: tag key must not be empty", field))
+		} else if len(m[key]) == 0 {
+			errs = append(errs, fmt.Errorf("%s.%s: must list at least one value", field, key))
+		}
+	}
+	return errs
+}
+
+// WayRule adjusts the cost of ways whose tags match. All matching rules
+// apply, so a surface rule and a highway rule combine.
+type WayRule struct {
+	Match TagMatch
+	// SpeedFactor multiplies the speed; zero leaves it unchanged.
+	SpeedFactor float64
+	// Penalty adds this fraction of the travel time as routing preference.
+	Penalty float64
+	Forbid  bool
+	Note    string
+}
+
+// NodeRule prices nodes whose tags match. The first matching rule wins,
+// which lets an exception such as an open gate precede the general
+// barrier rule.
+type NodeRule struct {
+	Match TagMatch
+	// Junction restricts the rule to nodes where several ways meet.
+	Junction bool
+	Forbid   bool
+	Delay    float64 // seconds
+	Note     string
+}
+
+// TurnCosts are the seconds added for each kind of turn at a junction.
+type TurnCosts struct {
+	Straight float64
+	Right    float64
+	Left     float64
+	UTurn    float64
+}
+
+// RuleSpec describes a profile entirely through data, so profiles can be
+// tuned without recompiling. Speeds are in km/h.
+type RuleSpec struct {
+	Name string
+	// SpeedKmh is used for ways without a class speed or maxspeed.
+	SpeedKmh float64
+	// Highways replaces the pedestrian way filter.
+	Highways map[string]bool
+	// AccessKeys are checked from most to least specific; the first one
+	// present decides whether the way is open.
+	AccessKeys []string
+	// Oneway enables oneway restrictions. OnewayKeys lists the tags read,
+	// first present wins, e.g. "oneway:bicycle" before "oneway".
+	Oneway     bool
+	OnewayKeys []string
+	// HighwaySpeedsKmh sets the speed per highway class.
+	HighwaySpeedsKmh map[string]float64
+	// UseMaxSpeed lets a parsable maxspeed tag override the class speed,
+	// capped at MaxSpeedKmh when that is set.
+	UseMaxSpeed bool
+	MaxSpeedKmh float64
+	WayRules    []WayRule
+	NodeRules   []NodeRule
+	// Mode applies the built-in barrier, elevator and crossing rules of
+	// NodePassage to nodes no node rule matches. Empty disables them.
+	Mode  Mode
+	Turns *TurnCosts
+}
+
+// Turn angles in degrees separating straight on, turns and U-turns.
+const (
+	straightAngle = 30
+	uTurnAngle    = 150
+)
+
+// Validate reports every problem in the spec at once, naming the field at
+// fault so that profile authors can fix a file in one pass.
+func (s RuleSpec) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if strings.TrimSpace(s.Name) == "" {
+		add("name: must not be empty")
+	}
+	if s.SpeedKmh <= 0 {
+		add("speed_kmh: must be positive, got %v", s.SpeedKmh)
+	}
+	if len(s.Highways) == 0 {
+		add("access.highways: at least one highway class is required")
+	}
+	for _, class := range sortedKeys(s.HighwaySpeedsKmh) {
+		if v := s.HighwaySpeedsKmh[class]; v <= 0 {
+			add("highway_speeds_kmh.%s: must be positive, got %v", class, v)
+		} else if !s.Highways[class] {
+			add("highway_speeds_kmh.%s: class is not in access.highways", class)
+		}
+	}
+	if s.MaxSpeedKmh < 0 {
+		add("max_speed_kmh: must not be negative, got %v", s.MaxSpeedKmh)
+	}
+	if s.MaxSpeedKmh > 0 && !s.UseMaxSpeed {
+		add("max_speed_kmh: only applies with use_maxspeed")
+	}
+
+	for i, r := range s.WayRules {
+		if len(r.Match) == 0 {
+			add("way_rules[%d].match: must name at least one tag", i)
+		}
+		errs = append(errs, r.Match.validate(fmt.Sprintf("way_rules[%d].match", i))...)
+		if r.SpeedFactor < 0 {
+			add("way_rules[%d].speed_factor: must not be negative, got %v", i, r.SpeedFactor)
+		}
+		if r.Penalty < 0 {
+			add("way_rules[%d].penalty: must not be negative, got %v", i, r.Penalty)
+		}
+		if r.Forbid && (r.SpeedFactor != 0 || r.Penalty != 0) {
+			add("way_rules[%d]: forbid cannot be combined with speed_factor or penalty", i)
+		}
+		if !r.Forbid && r.SpeedFactor == 0 && r.Penalty == 0 && r.Note == "" {
+			add("way_rules[%d]: rule has no effect", i)
+		}
+	}
+
+	for i, r := range s.NodeRules {
+		if len(r.Match) == 0 && !r.Junction {
+			add("node_rules[%d].match: must name a tag or set junction", i)
+		}
+		errs = append(errs, r.Match.validate(fmt.Sprintf("node_rules[%d].match", i))...)
+		if r.Delay < 0 {
+			add("node_rules[%d].delay: must not be negative, got %v", i, r.Delay)
+		}
+		if r.Forbid && r.Delay != 0 {
+			add("node_rules[%d]: forbid cannot be combined with delay", i)
+		}
+	}
+
+	if s.Mode != "" && !validMode(s.Mode) {
+		add("mode: unknown mode %q, want one of %v", s.Mode, Modes)
+	}
+
+	if t := s.Turns; t != nil {
+		for _, c := range []struct {
+			name  string
+			value float64
+		}{{"straight", t.Straight}, {"right", t.Right}, {"left", t.Left}, {"u_turn", t.UTurn}} {
+			if c.value < 0 {
+				add("turn_costs.%s: must not be negative, got %v", c.name, c.value)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ruleProfile is a profile driven by a RuleSpec.
+type ruleProfile struct {
+	basicProfile
+	spec RuleSpec
+}
+
+// NewRuleProfile validates spec and returns the profile it describes.
+func NewRuleProfile(spec RuleSpec) (Profile, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if spec.Oneway && len(spec.OnewayKeys) == 0 {
+		spec.OnewayKeys = []string{"oneway"}
+	}
+	return ruleProfile{
+		basicProfile: basicProfile{name: spec.Name, speed: spec.SpeedKmh / 3.6},
+		spec:         spec,
+	}, nil
+}
+
+func (p ruleProfile) WayAccess(tags map[string]string) (bool, bool) {
+	if !p.spec.Highways[tags["highway"]] {
+		return false, false
+	}
+	for _, key := range p.spec.AccessKeys {
+		if v, ok := tags[key]; ok {
+			if isNo(v) {
+				return false, false
+			}
+			break
+		}
+	}
+	for _, r := range p.spec.WayRules {
+		if r.Forbid && r.Match.Matches(tags) {
+			return false, false
+		}
+	}
+
+	if !p.spec.Oneway {
+		return true, true
+	}
+
+	oneway := ""
+	for _, key := range p.spec.OnewayKeys {
+		if v, ok := tags[key]; ok {
+			oneway = v
+			break
+		}
+	}
+	if oneway == "" && (tags["junction"] == "roundabout" || tags["junction"] == "circular") {
+		oneway = "yes"
+	}
+	switch oneway {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	}
+	return true, true
+}
+
+func (p ruleProfile) SegmentCost(s Segment) Cost {
+	speedKmh := p.spec.SpeedKmh
+	if v, ok := p.spec.HighwaySpeedsKmh[s.Tags["highway"]]; ok {
+		speedKmh = v
+	}
+	if p.spec.UseMaxSpeed {
+		if v, ok := ParseMaxSpeed(s.Tags["maxspeed"]); ok {
+			speedKmh = v
+			if p.spec.MaxSpeedKmh > 0 {
+				speedKmh = math.Min(speedKmh, p.spec.MaxSpeedKmh)
+			}
+		}
+	}
+
+	penalty := 0.0
+	note := ""
+	for _, r := range p.spec.WayRules {
+		if !r.Match.Matches(s.Tags) {
+			continue
+		}
+		if r.Forbid {
+			return Cost{Forbidden: true}
+		}
+		if r.SpeedFactor > 0 {
+			speedKmh *= r.SpeedFactor
+		}
+		penalty += r.Penalty
+		note = joinNotes(note, r.Note)
+	}
+
+	seconds := s.DistanceM / (speedKmh / 3.6)
+	return Cost{Seconds: seconds, Penalty: seconds * penalty, Note: note}
+}
+
+func (p ruleProfile) NodeCost(n NodeInfo) Cost {
+	for _, r := range p.spec.NodeRules {
+		if r.Junction && !n.IsJunction() {
+			continue
+		}
+		if !r.Match.Matches(n.Tags) {
+			continue
+		}
+		return Cost{Seconds: r.Delay, Forbidden: r.Forbid, Note: r.Note}
+	}
+	if p.spec.Mode != "" {
+		return NodePassage(n.Tags, p.spec.Mode)
+	}
+	return Cost{}
+}
+
+func (p ruleProfile) TurnCost(deflection float64) float64 {
+	t := p.spec.Turns
+	if t == nil {
+		return 0
+	}
+	switch a := math.Abs(deflection); {
+	case a <= straightAngle:
+		return t.Straight
+	case a >= uTurnAngle:
+		return t.UTurn
+	case deflection > 0:
+		return t.Right
+	default:
+		return t.Left
+	}
+}
+
+// HasTurnCosts lets callers skip turn-aware routing when a rule profile
+// declares no turn costs.
+func (p ruleProfile) HasTurnCosts() bool {
+	return p.spec.Turns != nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
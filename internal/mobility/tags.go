@@ -0,0 +1,71 @@
+package mobility
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseIncline reads an OSM incline value such as "8%", "-5 %" or "4°"
+// into a grade (rise over run). Values without a magnitude, like "up" or
+// "down", report ok=false.
+func ParseIncline(v string) (grade float64, ok bool) {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasSuffix(v, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, "%")), 64)
+		if err != nil {
+			return 0, false
+		}
+		return pct / 100, true
+	case strings.HasSuffix(v, "°"):
+		deg, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, "°")), 64)
+		if err != nil {
+			return 0, false
+		}
+		return math.Tan(deg * math.Pi / 180), true
+	}
+	return 0, false
+}
+
+// isYes reports whether an access-style tag grants permission.
+func isYes(v string) bool {
+	switch v {
+	case "yes", "designated", "permissive", "true", "1":
+		return true
+	}
+	return false
+}
+
+// isNo reports whether an access-style tag denies permission.
+func isNo(v string) bool {
+	switch v {
+	case "no", "private", "false", "0":
+		return true
+	}
+	return false
+}
+
+// isCrossing reports whether a node marks a place to cross a road or rail.
+func isCrossing(tags map[string]string) bool {
+	return tags["highway"] == "crossing" || tags["crossing"] != "" || tags["railway"] == "crossing"
+}
+
+// footAllowed applies the pedestrian access rules shared by the on-foot
+// profiles: an explicit foot tag wins over the general access tag.
+func footAllowed(tags map[string]string) bool {
+	if foot := tags["foot"]; foot != "" {
+		return !isNo(foot)
+	}
+	return tags["access"] != "private" && tags["access"] != "no"
+}
+
+func joinNotes(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "; " + b
+}
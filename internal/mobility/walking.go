@@ -0,0 +1,48 @@
+package mobility
+
+import "github.com/danielscoffee/pathcraft/internal/elevation"
+
+// PedestrianHighways lists the highway classes open to people on foot.
+var PedestrianHighways = map[string]bool{
+	"footway":       true,
+	"path":          true,
+	"pedestrian":    true,
+	"steps":         true,
+	"elevator":      true,
+	"residential":   true,
+	"living_street": true,
+	"service":       true,
+	"track":         true,
+	"unclassified":  true,
+	"tertiary":      true,
+	"secondary":     true,
+	"primary":       true,
+	"trunk":         true,
+}
+
+func NewWalking(speed float64) Profile {
+	if speed <= 0 {
+		speed = DefaultWalkingSpeedMPS
+	}
+	return walkingProfile{basicProfile{name: "walking", speed: speed}}
+}
+
+// walkingProfile slows down on slopes following Tobler's hiking function.
+// On flat ground, or without elevation data, it matches basicProfile.
+type walkingProfile struct {
+	basicProfile
+}
+
+func (p walkingProfile) SegmentCost(s Segment) Cost {
+	if p.speed <= 0 {
+		return Cost{}
+	}
+	if s.Tags["highway"] == "elevator" {
+		return Cost{Seconds: ElevatorDelay}
+	}
+	return Cost{Seconds: s.DistanceM / elevation.ToblerSpeed(p.speed, s.Grade)}
+}
+
+func (p walkingProfile) NodeCost(n NodeInfo) Cost {
+	return NodePassage(n.Tags, ModeFoot)
+}
@@ -0,0 +1,187 @@
+package mobility
+
+import "math"
+
+const (
+	// MaxWheelchairIncline is the steepest grade accepted, matching the
+	// common 1:12 ramp guideline.
+	MaxWheelchairIncline = 0.083
+	// comfortableIncline is the grade below which slope costs nothing.
+	comfortableIncline = 0.05
+	// rolledKerbPenalty is the extra time to negotiate a rolled kerb.
+	rolledKerbPenalty = 10
+)
+
+// Surfaces that stop a manual wheelchair.
+var wheelchairImpassableSurfaces = map[string]bool{
+	"gravel":          true,
+	"pebblestone":     true,
+	"sand":            true,
+	"grass":           true,
+	"ground":          true,
+	"dirt":            true,
+	"earth":           true,
+	"mud":             true,
+	"woodchips":       true,
+	"rock":            true,
+	"stepping_stones": true,
+	"unpaved":         true,
+}
+
+// Surfaces that are passable with effort, as a fraction of the flat speed.
+var wheelchairRoughSurfaces = map[string]float64{
+	"compacted":          0.8,
+	"fine_gravel":        0.7,
+	"paving_stones":      0.9,
+	"sett":               0.6,
+	"cobblestone":        0.5,
+	"unhewn_cobblestone": 0.4,
+	"grass_paver":        0.5,
+	"metal_grid":         0.7,
+}
+
+var wheelchairSmoothness = map[string]float64{
+	"excellent":    1,
+	"good":         1,
+	"intermediate": 0.9,
+	"bad":          0.6,
+}
+
+// Highways that are often unsurfaced, where a missing surface tag leaves
+// accessibility in doubt.
+var wheelchairUnverifiedHighways = map[string]bool{
+	"path":  true,
+	"track": true,
+}
+
+// wheelchairProfile is a step-free pedestrian profile. Segments that are
+// merely hard going are penalised; segments that cannot be used are
+// forbidden; anything it cannot verify is reported through Cost.Note.
+type wheelchairProfile struct {
+	basicProfile
+}
+
+func NewWheelchair(speed float64) Profile {
+	if speed <= 0 {
+		speed = DefaultWheelchairSpeedMPS
+	}
+	return wheelchairProfile{basicProfile{name: "wheelchair", speed: speed}}
+}
+
+func (p wheelchairProfile) WayAccess(tags map[string]string) (bool, bool) {
+	highway := tags["highway"]
+	if !PedestrianHighways[highway] || !footAllowed(tags) {
+		return false, false
+	}
+	if isNo(tags["wheelchair"]) {
+		return false, false
+	}
+	if highway == "steps" && !isYes(tags["ramp:wheelchair"]) {
+		return false, false
+	}
+	return true, true
+}
+
+func (p wheelchairProfile) SegmentCost(s Segment) Cost {
+	if p.speed <= 0 {
+		return Cost{}
+	}
+	if s.Tags["highway"] == "elevator" {
+		// WayAccess has already dropped elevators tagged wheelchair=no.
+		c := Cost{Seconds: ElevatorDelay}
+		if s.Tags["wheelchair"] == "" {
+			c.Note = "elevator not confirmed wheelchair accessible"
+		}
+		return c
+	}
+
+	factor := 1.0
+	note := ""
+
+	// A surveyed wheelchair=yes already vouches for the segment, so what
+	// is left unmapped about it is not worth a note.
+	verified := s.Tags["wheelchair"] == "yes"
+	if s.Tags["wheelchair"] == "limited" {
+		factor *= 0.7
+		note = joinNotes(note, "wheelchair access is limited")
+	}
+
+	if s.Tags["highway"] == "steps" {
+		// Only reachable with ramp:wheelchair=yes; ramps beside steps are
+		// usually steep and narrow.
+		factor *= 0.5
+		note = joinNotes(note, "steps with a wheelchair ramp")
+	}
+
+	surface := s.Tags["surface"]
+	switch {
+	case wheelchairImpassableSurfaces[surface]:
+		return Cost{Forbidden: true}
+	case surface == "" && wheelchairUnverifiedHighways[s.Tags["highway"]] && !verified:
+		note = joinNotes(note, "surface not mapped")
+	case surface != "":
+		if f, ok := wheelchairRoughSurfaces[surface]; ok {
+			factor *= f
+		}
+	}
+
+	if smoothness := s.Tags["smoothness"]; smoothness != "" {
+		f, ok := wheelchairSmoothness[smoothness]
+		if !ok {
+			return Cost{Forbidden: true}
+		}
+		factor *= f
+	}
+
+	grade := math.Abs(s.Grade)
+	if incline := s.Tags["incline"]; incline != "" {
+		if tagged, ok := ParseIncline(incline); ok {
+			grade = math.Max(grade, math.Abs(tagged))
+		} else if incline != "no" && incline != "0" && !verified {
+			note = joinNotes(note, "incline not measured")
+		}
+	}
+	if grade > MaxWheelchairIncline {
+		return Cost{Forbidden: true}
+	}
+	if grade > comfortableIncline {
+		// Slow down linearly to half speed at the steepest accepted grade.
+		excess := (grade - comfortableIncline) / (MaxWheelchairIncline - comfortableIncline)
+		factor *= 1 - 0.5*excess
+	}
+
+	return Cost{
+		Seconds: s.DistanceM / (p.speed * factor),
+		Note:    note,
+	}
+}
+
+func (p wheelchairProfile) NodeCost(n NodeInfo) Cost {
+	if isNo(n.Tags["wheelchair"]) {
+		return Cost{Forbidden: true}
+	}
+	return kerbCost(n.Tags).combine(NodePassage(n.Tags, ModeWheelchair))
+}
+
+func kerbCost(tags map[string]string) Cost {
+	kerb := tags["kerb"]
+	if kerb == "" && tags["barrier"] == "kerb" {
+		kerb = "raised"
+	}
+
+	switch kerb {
+	case "lowered", "flush", "no":
+		return Cost{}
+	case "rolled":
+		return Cost{Seconds: rolledKerbPenalty}
+	case "raised", "yes":
+		return Cost{Forbidden: true}
+	case "":
+		if isCrossing(tags) {
+			return Cost{Note: "kerb height at crossing not mapped"}
+		}
+		return Cost{}
+	default:
+		return Cost{Note: "kerb type " + kerb + " not recognised"}
+	}
+}
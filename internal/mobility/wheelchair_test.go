@@ -0,0 +1,133 @@
+package mobility_test
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+func TestWheelchair_WayAccess(t *testing.T) {
+	p := mobility.NewWheelchair(0).(mobility.WayFilter)
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want bool
+	}{
+		{"footway", map[string]string{"highway": "footway"}, true},
+		{"steps", map[string]string{"highway": "steps"}, false},
+		{"steps with ramp", map[string]string{"highway": "steps", "ramp:wheelchair": "yes"}, true},
+		{"wheelchair=no", map[string]string{"highway": "footway", "wheelchair": "no"}, false},
+		{"foot=no", map[string]string{"highway": "residential", "foot": "no"}, false},
+		{"private", map[string]string{"highway": "service", "access": "private"}, false},
+		{"motorway", map[string]string{"highway": "motorway"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, backward := p.WayAccess(tt.tags)
+			if forward != tt.want || backward != tt.want {
+				t.Errorf("WayAccess(%v) = (%v, %v), want %v both ways", tt.tags, forward, backward, tt.want)
+			}
+		})
+	}
+}
+
+func TestWheelchair_SegmentCost(t *testing.T) {
+	p := mobility.NewWheelchair(1.0)
+
+	seg := func(grade float64, tags map[string]string) mobility.Cost {
+		return mobility.SegmentCost(p, mobility.Segment{DistanceM: 100, Grade: grade, Tags: tags})
+	}
+
+	flat := seg(0, map[string]string{"highway": "footway", "surface": "asphalt"})
+	if flat.Forbidden || flat.Seconds != 100 || flat.Note != "" {
+		t.Errorf("smooth flat footway = %+v, want 100s with no note", flat)
+	}
+
+	if c := seg(0, map[string]string{"highway": "footway", "surface": "gravel"}); !c.Forbidden {
+		t.Error("gravel should be forbidden")
+	}
+	if c := seg(0, map[string]string{"highway": "footway", "smoothness": "horrible"}); !c.Forbidden {
+		t.Error("horrible smoothness should be forbidden")
+	}
+	if c := seg(0, map[string]string{"highway": "footway", "incline": "12%"}); !c.Forbidden {
+		t.Error("12% incline should be forbidden")
+	}
+	if c := seg(-0.1, map[string]string{"highway": "footway"}); !c.Forbidden {
+		t.Error("a 10% descent from elevation data should be forbidden")
+	}
+
+	cobbles := seg(0, map[string]string{"highway": "footway", "surface": "cobblestone"})
+	if cobbles.Forbidden || cobbles.Seconds <= flat.Seconds {
+		t.Errorf("cobblestone = %+v, want a penalty over %vs", cobbles, flat.Seconds)
+	}
+
+	ramp := seg(0.07, map[string]string{"highway": "footway"})
+	if ramp.Forbidden || ramp.Seconds <= flat.Seconds {
+		t.Errorf("7%% ramp = %+v, want a penalty over %vs", ramp, flat.Seconds)
+	}
+
+	unknown := seg(0, map[string]string{"highway": "path"})
+	if unknown.Forbidden || unknown.Note == "" {
+		t.Errorf("untagged path = %+v, want allowed with a note", unknown)
+	}
+	verified := seg(0, map[string]string{"highway": "path", "wheelchair": "yes", "incline": "up"})
+	if verified.Forbidden || verified.Note != "" {
+		t.Errorf("untagged path with wheelchair=yes = %+v, want allowed with no note", verified)
+	}
+
+	limited := seg(0, map[string]string{"highway": "footway", "wheelchair": "limited"})
+	if limited.Note == "" || limited.Seconds <= flat.Seconds {
+		t.Errorf("wheelchair=limited = %+v, want penalty and note", limited)
+	}
+}
+
+func TestWheelchair_NodeCost(t *testing.T) {
+	p := mobility.NewWheelchair(0)
+
+	tests := []struct {
+		name      string
+		tags      map[string]string
+		forbidden bool
+		noted     bool
+	}{
+		{"lowered kerb", map[string]string{"highway": "crossing", "kerb": "lowered"}, false, false},
+		{"raised kerb", map[string]string{"kerb": "raised"}, true, false},
+		{"kerb barrier", map[string]string{"barrier": "kerb"}, true, false},
+		{"unmapped crossing", map[string]string{"highway": "crossing"}, false, true},
+		{"plain node", map[string]string{"name": "Corner"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := mobility.NodeCost(p, mobility.NodeInfo{Tags: tt.tags})
+			if c.Forbidden != tt.forbidden {
+				t.Errorf("Forbidden = %v, want %v", c.Forbidden, tt.forbidden)
+			}
+			if (c.Note != "") != tt.noted {
+				t.Errorf("Note = %q, want noted=%v", c.Note, tt.noted)
+			}
+		})
+	}
+}
+
+func TestParseIncline(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"8%", 0.08, true},
+		{"-5 %", -0.05, true},
+		{"up", 0, false},
+		{"steep", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := mobility.ParseIncline(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseIncline(%q) = (%v, %v), want (%v, %v)", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package osm
+
+import (
+	"math"
+	"sort"
+
+	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+// pedestrianAreaHighways are the highway classes that describe an open
+// space, rather than a ring of path, when mapped as an area.
+var pedestrianAreaHighways = map[string]bool{
+	"pedestrian": true,
+	"footway":    true,
+	"path":       true,
+}
+
+// Area is a walkable open space such as a plaza. Rings are closed lists
+// of node IDs whose first and last entries are equal.
+type Area struct {
+	ID    int64 // the closed way or multipolygon relation
+	Tags  map[string]string
+	Outer []int64
+	Inner [][]int64
+	// way is set when the area is a single closed way, whose outline is
+	// already part of the graph.
+	way *Way
+}
+
+func (a *Area) rings() [][]int64 {
+	return append([][]int64{a.Outer}, a.Inner...)
+}
+
+// isAreaTagged reports whether tags describe an open space. A closed
+// highway=pedestrian way without area=yes is a loop of road, not a square.
+func isAreaTagged(tags map[string]string) bool {
+	return pedestrianAreaHighways[tags["highway"]] &&
+		(tags["area"] == "yes" || tags["type"] == "multipolygon")
+}
+
+// PedestrianAreas returns the open spaces in d that the filter's profile
+// may cross, from closed ways tagged area=yes and from multipolygon
+// relations, whose inner rings are holes.
+func (d *Data) PedestrianAreas(f *Filter) []*Area {
+	if f == nil {
+		f = DefaultFilter()
+	}
+
+	allowed := func(tags map[string]string) bool {
+		forward, backward := f.Allows(&Way{Tags: tags})
+		return forward || backward
+	}
+
+	var areas []*Area
+	for _, w := range d.Ways {
+		if !isAreaTagged(w.Tags) || !isClosed(w.NodeIDs) || !allowed(w.Tags) {
+			continue
+		}
+		areas = append(areas, &Area{ID: w.ID, Tags: w.Tags, Outer: w.NodeIDs, way: w})
+	}
+
+	var ways map[int64]*Way
+	for _, r := range d.Relations {
+		if !isAreaTagged(r.Tags) || r.Tags["type"] != "multipolygon" || !allowed(r.Tags) {
+			continue
+		}
+		if ways == nil {
+			ways = make(map[int64]*Way, len(d.Ways))
+			for _, w := range d.Ways {
+				ways[w.ID] = w
+			}
+		}
+
+		var outer, inner [][]int64
+		for _, m := range r.Members {
+			w, ok := ways[m.Ref]
+			if m.Type != "way" || !ok {
+				continue
+			}
+			if m.Role == "inner" {
+				inner = append(inner, w.NodeIDs)
+			} else {
+				outer = append(outer, w.NodeIDs)
+			}
+		}
+
+		holes := assembleRings(inner)
+		for _, ring := range assembleRings(outer) {
+			a := &Area{ID: r.ID, Tags: r.Tags, Outer: ring}
+			poly, ok := d.project(ring, ring)
+			if !ok {
+				continue
+			}
+			for _, hole := range holes {
+				if p, ok := d.project(ring, hole); ok && pointInRing(p[0], poly) {
+					a.Inner = append(a.Inner, hole)
+				}
+			}
+			areas = append(areas, a)
+		}
+	}
+
+	return areas
+}
+
+func isClosed(ids []int64) bool {
+	return len(ids) >= 4 && ids[0] == ids[len(ids)-1]
+}
+
+// assembleRings joins way fragments end to end into closed rings.
+// Fragments that cannot be closed are dropped.
+func assembleRings(parts [][]int64) [][]int64 {
+	var rings [][]int64
+	var open [][]int64
+	for _, p := range parts {
+		if len(p) < 2 {
+			continue
+		}
+		if isClosed(p) {
+			rings = append(rings, p)
+		} else {
+			open = append(open, p)
+		}
+	}
+
+	for len(open) > 0 {
+		ring := append([]int64(nil), open[0]...)
+		open = open[1:]
+
+		for !isClosed(ring) {
+			end := ring[len(ring)-1]
+			joined := false
+			for i, p := range open {
+				switch end {
+				case p[0]:
+					ring = append(ring, p[1:]...)
+				case p[len(p)-1]:
+					for j := len(p) - 2; j >= 0; j-- {
+						ring = append(ring, p[j])
+					}
+				default:
+					continue
+				}
+				open = append(open[:i], open[i+1:]...)
+				joined = true
+				break
+			}
+			if !joined {
+				break
+			}
+		}
+
+		if isClosed(ring) {
+			rings = append(rings, ring)
+		}
+	}
+
+	return rings
+}
+
+type point struct{ x, y float64 }
+
+// project maps ring onto a local plane in metres centred on ref, which is
+// accurate enough for anything the size of a square.
+func (d *Data) project(ref, ring []int64) ([]point, bool) {
+	origin, ok := d.Nodes[ref[0]]
+	if !ok {
+		return nil, false
+	}
+	pts := make([]point, len(ring))
+	for i, id := range ring {
+		n, ok := d.Nodes[id]
+		if !ok {
+			return nil, false
+		}
+		pts[i] = planar(origin.Lat, origin.Lon, n.Lat, n.Lon)
+	}
+	return pts, true
+}
+
+func planar(lat0, lon0, lat, lon float64) point {
+	return point{
+		x: (lon - lon0) * geo.DegreesToRadians * geo.EarthRadiusMeters * math.Cos(lat0*geo.DegreesToRadians),
+		y: (lat - lat0) * geo.DegreesToRadians * geo.EarthRadiusMeters,
+	}
+}
+
+// areaWays finds the ways that can lead into an area without scanning
+// them all for every area: those sharing a node with its rings, and those
+// ending within its bounding box.
+type areaWays struct {
+	byNode map[int64][]*Way
+	// ends holds the first and last nodes of every way, by longitude.
+	ends []wayEnd
+}
+
+type wayEnd struct {
+	lat, lon float64
+	way      *Way
+}
+
+func newAreaWays(d *Data, ways []*Way) *areaWays {
+	aw := &areaWays{byNode: make(map[int64][]*Way)}
+	for _, w := range ways {
+		for _, id := range w.NodeIDs {
+			aw.byNode[id] = append(aw.byNode[id], w)
+		}
+		if len(w.NodeIDs) == 0 {
+			continue
+		}
+		for _, id := range []int64{w.NodeIDs[0], w.NodeIDs[len(w.NodeIDs)-1]} {
+			if n, ok := d.Nodes[id]; ok {
+				aw.ends = append(aw.ends, wayEnd{lat: n.Lat, lon: n.Lon, way: w})
+			}
+		}
+	}
+	sort.Slice(aw.ends, func(i, j int) bool { return aw.ends[i].lon < aw.ends[j].lon })
+	return aw
+}
+
+// near returns the ways touching a's rings or ending within its bounding
+// box, each once.
+func (aw *areaWays) near(d *Data, a *Area) []*Way {
+	var ways []*Way
+	seen := make(map[*Way]bool)
+	add := func(w *Way) {
+		if !seen[w] {
+			seen[w] = true
+			ways = append(ways, w)
+		}
+	}
+
+	minLat, minLon := math.Inf(1), math.Inf(1)
+	maxLat, maxLon := math.Inf(-1), math.Inf(-1)
+	for _, ring := range a.rings() {
+		for _, id := range ring {
+			for _, w := range aw.byNode[id] {
+				add(w)
+			}
+			if n, ok := d.Nodes[id]; ok {
+				minLat, maxLat = math.Min(minLat, n.Lat), math.Max(maxLat, n.Lat)
+				minLon, maxLon = math.Min(minLon, n.Lon), math.Max(maxLon, n.Lon)
+			}
+		}
+	}
+
+	for i := sort.Search(len(aw.ends), func(i int) bool { return aw.ends[i].lon >= minLon }); i < len(aw.ends) && aw.ends[i].lon <= maxLon; i++ {
+		if e := aw.ends[i]; e.lat >= minLat && e.lat <= maxLat {
+			add(e.way)
+		}
+	}
+	return ways
+}
+
+// addAreaEdges links the vertices of a that matter for routing with
+// straight edges wherever they can see each other across the open space.
+// The vertices are entrances (ring nodes shared with other ways), ends of
+// ways inside the area, and the reflex corners a shortest path bends
+// around. Multipolygon outlines are not ways, so their rings are added as
+// well. ways are the ways that may lead into a, see areaWays.
+func addAreaEdges(g *graph.Graph, profile mobility.Profile, a *Area, ways []*Way, nodeCosts map[graph.NodeID]mobility.Cost) {
+	origin, ok := g.Nodes[graph.NodeID(a.Outer[0])]
+	if !ok {
+		return
+	}
+	at := func(id int64) (point, bool) {
+		n, ok := g.Nodes[graph.NodeID(id)]
+		if !ok {
+			return point{}, false
+		}
+		return planar(origin.Lat, origin.Lon, n.Lat, n.Lon), true
+	}
+
+	// Rings with a removed node (a forbidden barrier) cannot be trusted
+	// as boundaries, so the whole area is skipped.
+	rings := a.rings()
+	shapes := make([][]point, len(rings))
+	for i, ring := range rings {
+		for _, id := range ring {
+			p, ok := at(id)
+			if !ok {
+				return
+			}
+			shapes[i] = append(shapes[i], p)
+		}
+	}
+
+	area := &Way{ID: a.ID, Tags: a.Tags}
+	link := func(from, to graph.Node) {
+		distance := geo.HaversineDistance(from.Lat, from.Lon, to.Lat, to.Lon)
+		if e, ok := segmentEdge(g, profile, area, from, to, distance, false, nodeCosts[to.ID]); ok {
+			g.AppendEdge(from.ID, e)
+		}
+	}
+
+	if a.way == nil {
+		for _, ring := range rings {
+			for i := 0; i+1 < len(ring); i++ {
+				from, to := g.Nodes[graph.NodeID(ring[i])], g.Nodes[graph.NodeID(ring[i+1])]
+				link(from, to)
+				link(to, from)
+			}
+		}
+	}
+
+	onRing := make(map[int64]bool)
+	neighbours := make(map[[2]int64]bool)
+	for _, ring := range rings {
+		for i := 0; i+1 < len(ring); i++ {
+			onRing[ring[i]] = true
+			neighbours[[2]int64{ring[i], ring[i+1]}] = true
+			neighbours[[2]int64{ring[i+1], ring[i]}] = true
+		}
+	}
+
+	vertices := make(map[int64]point)
+	for _, w := range ways {
+		if w == a.way || alongRing(w, onRing) {
+			continue
+		}
+		for i, id := range w.NodeIDs {
+			if onRing[id] {
+				vertices[id], _ = at(id)
+				continue
+			}
+			if i != 0 && i != len(w.NodeIDs)-1 {
+				continue
+			}
+			if p, ok := at(id); ok && insideArea(p, shapes) {
+				vertices[id] = p
+			}
+		}
+	}
+	if len(vertices) < 2 {
+		return
+	}
+	for i, ring := range rings {
+		for _, id := range reflexVertices(ring, shapes[i], i > 0) {
+			vertices[id] = shapes[i][indexOf(ring, id)]
+		}
+	}
+
+	ids := make([]int64, 0, len(vertices))
+	for id := range vertices {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			u, v := ids[i], ids[j]
+			if neighbours[[2]int64{u, v}] || !visible(u, v, vertices[u], vertices[v], rings, shapes) {
+				continue
+			}
+			from, to := g.Nodes[graph.NodeID(u)], g.Nodes[graph.NodeID(v)]
+			link(from, to)
+			link(to, from)
+		}
+	}
+}
+
+// alongRing reports whether w only traces the outline, as the member ways
+// of a multipolygon often do, rather than leading into the area.
+func alongRing(w *Way, onRing map[int64]bool) bool {
+	for _, id := range w.NodeIDs {
+		if !onRing[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// reflexVertices returns the corners of ring that bend into the walkable
+// space: concave corners of the outline and convex corners of holes.
+func reflexVertices(ring []int64, pts []point, hole bool) []int64 {
+	orientation := signedArea(pts)
+	if hole {
+		orientation = -orientation
+	}
+
+	var out []int64
+	n := len(pts) - 1 // the last point repeats the first
+	for i := 0; i < n; i++ {
+		prev, cur, next := pts[(i+n-1)%n], pts[i], pts[(i+1)%n]
+		if cross(prev, cur, next)*orientation < 0 {
+			out = append(out, ring[i])
+		}
+	}
+	return out
+}
+
+// visible reports whether the straight line from a to b stays inside the
+// area without crossing its outline or a hole.
+func visible(aID, bID int64, a, b point, rings [][]int64, shapes [][]point) bool {
+	for r, ring := range rings {
+		pts := shapes[r]
+		for i := 0; i+1 < len(ring); i++ {
+			if ring[i] == aID || ring[i] == bID || ring[i+1] == aID || ring[i+1] == bID {
+				continue
+			}
+			if segmentsCross(a, b, pts[i], pts[i+1]) {
+				return false
+			}
+		}
+	}
+
+	// A line can slip through a corner without properly crossing any
+	// edge, so every stretch between outline vertices lying on it must be
+	// inside the area.
+	stops := []float64{0, 1}
+	for _, pts := range shapes {
+		for _, p := range pts {
+			if t, ok := onSegment(p, a, b); ok {
+				stops = append(stops, t)
+			}
+		}
+	}
+	sort.Float64s(stops)
+	for i := 0; i+1 < len(stops); i++ {
+		t := (stops[i] + stops[i+1]) / 2
+		mid := point{a.x + t*(b.x-a.x), a.y + t*(b.y-a.y)}
+		if !insideArea(mid, shapes) {
+			return false
+		}
+	}
+	return true
+}
+
+// onSegment reports whether p lies strictly between a and b, within a
+// few centimetres, and where along the segment as a fraction.
+func onSegment(p, a, b point) (float64, bool) {
+	const tolerance = 0.05 // metres
+	dx, dy := b.x-a.x, b.y-a.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return 0, false
+	}
+	if math.Abs(cross(a, b, p))/length > tolerance {
+		return 0, false
+	}
+	t := ((p.x-a.x)*dx + (p.y-a.y)*dy) / (length * length)
+	return t, t > 0 && t < 1
+}
+
+// insideArea reports whether p lies inside the outer ring (shapes[0]) and
+// outside every hole.
+func insideArea(p point, shapes [][]point) bool {
+	if !pointInRing(p, shapes[0]) {
+		return false
+	}
+	for _, hole := range shapes[1:] {
+		if pointInRing(p, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+func pointInRing(p point, ring []point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentsCross reports a proper crossing; touching at an end point or
+// running along a segment does not count.
+func segmentsCross(a, b, c, d point) bool {
+	d1 := cross(c, d, a)
+	d2 := cross(c, d, b)
+	d3 := cross(a, b, c)
+	d4 := cross(a, b, d)
+	return d1*d2 < 0 && d3*d4 < 0
+}
+
+// cross is the z component of (b-a) x (c-b): positive for a left turn.
+func cross(a, b, c point) float64 {
+	return (b.x-a.x)*(c.y-b.y) - (b.y-a.y)*(c.x-b.x)
+}
+
+func signedArea(pts []point) float64 {
+	sum := 0.0
+	for i := 0; i+1 < len(pts); i++ {
+		sum += pts[i].x*pts[i+1].y - pts[i+1].x*pts[i].y
+	}
+	return sum / 2
+}
+
+func indexOf(ids []int64, id int64) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
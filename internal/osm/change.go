@@ -0,0 +1,195 @@
+package osm
+
+import (
+	"encoding/gob"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Action is what an OsmChange block does to its elements.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionModify Action = "modify"
+	ActionDelete Action = "delete"
+)
+
+// ChangeBlock is one create, modify or delete section of a change file.
+// Deleted elements may carry only their IDs.
+type ChangeBlock struct {
+	Action Action
+	Data   *Data
+}
+
+// Change is an OsmChange document, such as a minutely diff. Its blocks
+// must be applied in order.
+type Change struct {
+	Blocks []ChangeBlock
+}
+
+type xmlChange struct {
+	Blocks []xmlChangeBlock `xml:",any"`
+}
+
+type xmlChangeBlock struct {
+	XMLName xml.Name
+	xmlOSM
+}
+
+// ParseChange reads an OsmChange (.osc) document.
+func ParseChange(r io.Reader) (*Change, error) {
+	var doc xmlChange
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding XML: %w", err)
+	}
+
+	c := &Change{}
+	for _, b := range doc.Blocks {
+		action := Action(b.XMLName.Local)
+		switch action {
+		case ActionCreate, ActionModify, ActionDelete:
+		default:
+			return nil, fmt.Errorf("unknown change action %q", b.XMLName.Local)
+		}
+		c.Blocks = append(c.Blocks, ChangeBlock{Action: action, Data: b.data()})
+	}
+	return c, nil
+}
+
+// ParseChangeFile reads an .osc or .osc.gz file.
+func ParseChangeFile(path string) (*Change, error) {
+	var c *Change
+	err := readFile(path, func(r io.Reader) error {
+		var err error
+		c, err = ParseChange(r)
+		return err
+	})
+	return c, err
+}
+
+// ChangeStats counts the elements a change touched, by action.
+type ChangeStats struct {
+	NodesCreated, NodesModified, NodesDeleted             int
+	WaysCreated, WaysModified, WaysDeleted                int
+	RelationsCreated, RelationsModified, RelationsDeleted int
+}
+
+// Apply updates d in place. Creating an element that exists replaces it
+// and modifying one that does not creates it, as replication tools do;
+// deleting a missing element is ignored.
+func (d *Data) Apply(c *Change) ChangeStats {
+	var stats ChangeStats
+	ways := make(map[int64]int, len(d.Ways))
+	for i, w := range d.Ways {
+		ways[w.ID] = i
+	}
+	relations := make(map[int64]int, len(d.Relations))
+	for i, r := range d.Relations {
+		relations[r.ID] = i
+	}
+
+	for _, b := range c.Blocks {
+		nodes, wayCount, relationCount := len(b.Data.Nodes), len(b.Data.Ways), len(b.Data.Relations)
+		switch b.Action {
+		case ActionCreate:
+			stats.NodesCreated += nodes
+			stats.WaysCreated += wayCount
+			stats.RelationsCreated += relationCount
+		case ActionModify:
+			stats.NodesModified += nodes
+			stats.WaysModified += wayCount
+			stats.RelationsModified += relationCount
+		case ActionDelete:
+			stats.NodesDeleted += nodes
+			stats.WaysDeleted += wayCount
+			stats.RelationsDeleted += relationCount
+		}
+
+		if b.Action == ActionDelete {
+			for id := range b.Data.Nodes {
+				delete(d.Nodes, id)
+			}
+			for _, w := range b.Data.Ways {
+				if i, ok := ways[w.ID]; ok {
+					d.Ways[i] = nil
+					delete(ways, w.ID)
+				}
+			}
+			for _, r := range b.Data.Relations {
+				if i, ok := relations[r.ID]; ok {
+					d.Relations[i] = nil
+					delete(relations, r.ID)
+				}
+			}
+			continue
+		}
+
+		for id, n := range b.Data.Nodes {
+			d.Nodes[id] = n
+		}
+		for _, w := range b.Data.Ways {
+			if i, ok := ways[w.ID]; ok {
+				d.Ways[i] = w
+			} else {
+				ways[w.ID] = len(d.Ways)
+				d.Ways = append(d.Ways, w)
+			}
+		}
+		for _, r := range b.Data.Relations {
+			if i, ok := relations[r.ID]; ok {
+				d.Relations[i] = r
+			} else {
+				relations[r.ID] = len(d.Relations)
+				d.Relations = append(d.Relations, r)
+			}
+		}
+	}
+
+	d.Ways = compact(d.Ways)
+	d.Relations = compact(d.Relations)
+	return stats
+}
+
+// compact removes the nil entries left by deletions, keeping the order.
+func compact[T any](items []*T) []*T {
+	out := items[:0]
+	for _, item := range items {
+		if item != nil {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Save serializes d to a file, so that a graph built from it can later be
+// updated with change files.
+func (d *Data) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return gob.NewEncoder(f).Encode(d)
+}
+
+// LoadData deserializes data written by Data.Save.
+func LoadData(path string) (*Data, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var d Data
+	if err := gob.NewDecoder(f).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Nodes == nil {
+		d.Nodes = make(map[int64]*Node)
+	}
+	return &d, nil
+}
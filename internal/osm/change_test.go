@@ -0,0 +1,160 @@
+package osm_test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+	"github.com/danielscoffee/pathcraft/internal/osm"
+)
+
+const updateBaseXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.000" lon="0.000"/>
+  <node id="2" lat="0.000" lon="0.001"/>
+  <node id="3" lat="0.000" lon="0.002"/>
+  <node id="4" lat="0.001" lon="0.001"/>
+  <node id="5" lat="0.001" lon="0.002"/>
+  <node id="6" lat="0.002" lon="0.002"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="residential"/>
+  </way>
+  <way id="11">
+    <nd ref="2"/>
+    <nd ref="4"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="12">
+    <nd ref="3"/>
+    <nd ref="5"/>
+    <nd ref="6"/>
+    <tag k="highway" v="residential"/>
+  </way>
+</osm>`
+
+const updateChangeXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6">
+  <create>
+    <node id="7" lat="0.001" lon="0.000"/>
+    <way id="13">
+      <nd ref="4"/>
+      <nd ref="7"/>
+      <tag k="highway" v="footway"/>
+    </way>
+  </create>
+  <modify>
+    <node id="5" lat="0.0012" lon="0.0021">
+      <tag k="barrier" v="gate"/>
+    </node>
+    <way id="11">
+      <nd ref="2"/>
+      <nd ref="4"/>
+      <tag k="highway" v="steps"/>
+    </way>
+  </modify>
+  <delete>
+    <way id="10"/>
+    <node id="1"/>
+  </delete>
+  <create>
+    <way id="10">
+      <nd ref="2"/>
+      <nd ref="3"/>
+      <tag k="highway" v="residential"/>
+      <tag k="oneway" v="yes"/>
+    </way>
+  </create>
+</osmChange>`
+
+// edgeList renders a graph's edges in a canonical order for comparison.
+func edgeList(g *graph.Graph) []string {
+	var out []string
+	for from, edges := range g.Edges {
+		for _, e := range edges {
+			out = append(out, fmt.Sprintf("%d>%d cost=%.3f dist=%.3f note=%q", from, e.To, float64(e.Cost), e.DistanceM, g.NoteText(e)))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestParseChange(t *testing.T) {
+	c, err := osm.ParseChange(strings.NewReader(updateChangeXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []osm.Action
+	for _, b := range c.Blocks {
+		actions = append(actions, b.Action)
+	}
+	want := []osm.Action{osm.ActionCreate, osm.ActionModify, osm.ActionDelete, osm.ActionCreate}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+
+	if _, err := osm.ParseChange(strings.NewReader(`<osmChange><rename/></osmChange>`)); err == nil {
+		t.Error("unknown actions should be rejected")
+	}
+}
+
+func TestUpdateGraphMatchesRebuild(t *testing.T) {
+	for _, profile := range []mobility.Profile{mobility.NewWalking(0), mobility.NewDriving(0)} {
+		t.Run(profile.Name(), func(t *testing.T) {
+			data, err := osm.ParseXML(strings.NewReader(updateBaseXML))
+			if err != nil {
+				t.Fatal(err)
+			}
+			change, err := osm.ParseChange(strings.NewReader(updateChangeXML))
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter := &osm.Filter{Profile: profile}
+
+			g := osm.BuildGraph(data, filter)
+			stats, err := osm.UpdateGraph(g, data, change, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Rebuilt {
+				t.Error("a change without areas should be patched, not rebuilt")
+			}
+			if stats.WaysCreated != 2 || stats.WaysModified != 1 || stats.WaysDeleted != 1 || stats.NodesDeleted != 1 {
+				t.Errorf("change stats = %+v", stats.ChangeStats)
+			}
+
+			fresh, err := osm.ParseXML(strings.NewReader(updateBaseXML))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fresh.Apply(change)
+			want := osm.BuildGraph(fresh, filter)
+
+			if !reflect.DeepEqual(edgeList(g), edgeList(want)) {
+				t.Errorf("updated edges:\n%s\nrebuilt edges:\n%s", strings.Join(edgeList(g), "\n"), strings.Join(edgeList(want), "\n"))
+			}
+			if !reflect.DeepEqual(g.Nodes, want.Nodes) {
+				t.Errorf("updated nodes %v, rebuilt nodes %v", g.Nodes, want.Nodes)
+			}
+		})
+	}
+}
+
+func TestUpdateGraphRejectsSimplified(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(updateBaseXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := osm.BuildGraph(data, nil)
+	g.Simplify(nil)
+
+	if _, err := osm.UpdateGraph(g, data, &osm.Change{}, nil); err != osm.ErrSimplified {
+		t.Errorf("err = %v, want ErrSimplified", err)
+	}
+}
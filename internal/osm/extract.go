@@ -0,0 +1,162 @@
+package osm
+
+import (
+	"github.com/danielscoffee/pathcraft/internal/geo"
+)
+
+// Extract returns the part of d inside region. Nodes outside are dropped
+// and ways touching the region are clipped to it: where a way crosses the
+// boundary a new untagged node is placed on the crossing, and a way that
+// leaves and re-enters is split into several ways. New nodes and the
+// extra pieces of split ways get negative IDs, as OSM editors use for
+// objects not yet uploaded. Relations keep the members that survive, with
+// split ways listed piece by piece, and are dropped when none do.
+//
+// Clipping opens closed ways cut by the boundary, so areas crossing it no
+// longer count as areas.
+func (d *Data) Extract(region geo.Region) *Data {
+	out := NewData()
+	nextID := d.minID() - 1
+	newID := func() int64 {
+		id := nextID
+		nextID--
+		return id
+	}
+
+	for id, n := range d.Nodes {
+		if region.Contains(geo.Point{Lat: n.Lat, Lon: n.Lon}) {
+			out.Nodes[id] = n
+		}
+	}
+
+	pieces := make(map[int64][]int64) // way ID -> IDs of its clipped pieces
+	for _, w := range d.Ways {
+		runs := d.clipWay(w, region, out, newID)
+		for i, run := range runs {
+			id := w.ID
+			if i > 0 {
+				id = newID()
+			}
+			out.Ways = append(out.Ways, &Way{ID: id, NodeIDs: run, Tags: w.Tags})
+			pieces[w.ID] = append(pieces[w.ID], id)
+		}
+	}
+
+	kept := make(map[int64]bool)
+	var relations []*Relation
+	for _, r := range d.Relations {
+		var members []Member
+		for _, m := range r.Members {
+			switch m.Type {
+			case "node":
+				if _, ok := out.Nodes[m.Ref]; ok {
+					members = append(members, m)
+				}
+			case "way":
+				for _, id := range pieces[m.Ref] {
+					members = append(members, Member{Type: m.Type, Ref: id, Role: m.Role})
+				}
+			default:
+				members = append(members, m)
+			}
+		}
+		relations = append(relations, &Relation{ID: r.ID, Members: members, Tags: r.Tags})
+		for _, m := range members {
+			if m.Type != "relation" {
+				kept[r.ID] = true
+				break
+			}
+		}
+	}
+	// Relations of relations survive when a relation they refer to does.
+	for _, r := range relations {
+		members := r.Members[:0]
+		for _, m := range r.Members {
+			if m.Type != "relation" || kept[m.Ref] {
+				members = append(members, m)
+			}
+		}
+		r.Members = members
+		if len(members) > 0 {
+			out.Relations = append(out.Relations, r)
+		}
+	}
+
+	return out
+}
+
+// clipWay splits w into the runs of node IDs that lie inside region,
+// adding any boundary nodes it creates to out.
+func (d *Data) clipWay(w *Way, region geo.Region, out *Data, newID func() int64) [][]int64 {
+	var runs [][]int64
+	var run []int64
+	flush := func() {
+		if len(run) >= 2 {
+			runs = append(runs, run)
+		}
+		run = nil
+	}
+	boundary := func(p geo.Point) int64 {
+		id := newID()
+		out.Nodes[id] = &Node{ID: id, Lat: p.Lat, Lon: p.Lon, Tags: map[string]string{}}
+		return id
+	}
+
+	for i := 0; i+1 < len(w.NodeIDs); i++ {
+		from, okFrom := d.Nodes[w.NodeIDs[i]]
+		to, okTo := d.Nodes[w.NodeIDs[i+1]]
+		if !okFrom || !okTo {
+			flush()
+			continue
+		}
+		a, b := geo.Point{Lat: from.Lat, Lon: from.Lon}, geo.Point{Lat: to.Lat, Lon: to.Lon}
+
+		// Split the segment at its crossings and keep the pieces whose
+		// midpoint is inside.
+		cuts := append([]float64{0}, region.Crossings(a, b)...)
+		cuts = append(cuts, 1)
+		for j := 0; j+1 < len(cuts); j++ {
+			if !region.Contains(geo.Lerp(a, b, (cuts[j]+cuts[j+1])/2)) {
+				flush()
+				continue
+			}
+			if len(run) == 0 {
+				if j == 0 {
+					out.Nodes[from.ID] = from
+					run = append(run, from.ID)
+				} else {
+					run = append(run, boundary(geo.Lerp(a, b, cuts[j])))
+				}
+			}
+			if j+1 == len(cuts)-1 {
+				out.Nodes[to.ID] = to
+				run = append(run, to.ID)
+			} else {
+				run = append(run, boundary(geo.Lerp(a, b, cuts[j+1])))
+			}
+		}
+	}
+	flush()
+	return runs
+}
+
+// minID returns the smallest ID in use by any element, or zero.
+func (d *Data) minID() int64 {
+	var min int64
+	for id := range d.Nodes {
+		if id < min {
+			min = id
+		}
+	}
+	for _, w := range d.Ways {
+		if w.ID < min {
+			min = w.ID
+		}
+	}
+	for _, r := range d.Relations {
+		if r.ID < min {
+			min = r.ID
+		}
+	}
+	return min
+}
@@ -0,0 +1,159 @@
+package osm_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/osm"
+)
+
+// A footway that runs east, leaves the box through its eastern edge and
+// comes back, plus a way wholly outside and a relation using both.
+const extractOSMXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.5" lon="0.2"/>
+  <node id="2" lat="0.5" lon="0.8"/>
+  <node id="3" lat="0.5" lon="1.4"/>
+  <node id="4" lat="0.3" lon="0.8">
+    <tag k="barrier" v="gate"/>
+  </node>
+  <node id="5" lat="5" lon="5"/>
+  <node id="6" lat="5" lon="6"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <nd ref="4"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="11">
+    <nd ref="5"/>
+    <nd ref="6"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <relation id="20">
+    <member type="way" ref="10" role="outer"/>
+    <member type="way" ref="11" role="outer"/>
+    <tag k="type" v="route"/>
+  </relation>
+  <relation id="21">
+    <member type="way" ref="11" role=""/>
+  </relation>
+</osm>`
+
+func TestExtractClipsWays(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(extractOSMXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	box := geo.BBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 1}
+	out := data.Extract(box)
+
+	for _, id := range []int64{1, 2, 4} {
+		if out.Nodes[id] == nil {
+			t.Errorf("node %d inside the box should be kept", id)
+		}
+	}
+	for _, id := range []int64{3, 5, 6} {
+		if out.Nodes[id] != nil {
+			t.Errorf("node %d outside the box should be dropped", id)
+		}
+	}
+	if out.Nodes[4].Tags["barrier"] != "gate" {
+		t.Error("kept nodes should keep their tags")
+	}
+
+	if len(out.Ways) != 2 {
+		t.Fatalf("got %d ways, want way 10 split in two", len(out.Ways))
+	}
+	first, second := out.Ways[0], out.Ways[1]
+	if first.ID != 10 || second.ID >= 0 {
+		t.Errorf("way IDs = %d, %d; want 10 and a new negative ID", first.ID, second.ID)
+	}
+	if len(first.NodeIDs) != 3 || first.NodeIDs[0] != 1 || first.NodeIDs[1] != 2 {
+		t.Fatalf("first piece = %v, want [1 2 <boundary>]", first.NodeIDs)
+	}
+	exit := out.Nodes[first.NodeIDs[2]]
+	if exit == nil || first.NodeIDs[2] >= 0 {
+		t.Fatalf("first piece should end at a new boundary node, got %v", first.NodeIDs[2])
+	}
+	if math.Abs(exit.Lon-1) > 1e-9 || math.Abs(exit.Lat-0.5) > 1e-9 {
+		t.Errorf("exit node at (%v, %v), want (0.5, 1)", exit.Lat, exit.Lon)
+	}
+	if len(second.NodeIDs) != 2 || second.NodeIDs[1] != 4 {
+		t.Errorf("second piece = %v, want [<boundary> 4]", second.NodeIDs)
+	}
+	if second.Tags["highway"] != "footway" {
+		t.Error("split pieces should keep the way's tags")
+	}
+
+	if len(out.Relations) != 1 {
+		t.Fatalf("got %d relations, want only 20", len(out.Relations))
+	}
+	want := []osm.Member{
+		{Type: "way", Ref: 10, Role: "outer"},
+		{Type: "way", Ref: second.ID, Role: "outer"},
+	}
+	if !reflect.DeepEqual(out.Relations[0].Members, want) {
+		t.Errorf("members = %+v, want %+v", out.Relations[0].Members, want)
+	}
+}
+
+func TestExtractPolygonWithHole(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(extractOSMXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	square := func(min, max float64) []geo.Point {
+		return []geo.Point{{Lat: min, Lon: min}, {Lat: min, Lon: max}, {Lat: max, Lon: max}, {Lat: max, Lon: min}, {Lat: min, Lon: min}}
+	}
+	region := geo.Polygon{Outer: square(0, 2), Holes: [][]geo.Point{square(0.4, 0.9)}}
+	out := data.Extract(region)
+
+	if out.Nodes[1] == nil || out.Nodes[3] == nil {
+		t.Error("nodes 1 and 3 lie inside the polygon")
+	}
+	if out.Nodes[2] != nil {
+		t.Error("node 2 lies in the hole")
+	}
+	for _, w := range out.Ways {
+		for _, id := range w.NodeIDs {
+			if out.Nodes[id] == nil {
+				t.Errorf("way %d refers to missing node %d", w.ID, id)
+			}
+		}
+	}
+}
+
+func TestWriteXMLRoundTrip(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(extractOSMXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Nodes[4].Tags["name"] = `Gate "A" & <B>`
+
+	var buf bytes.Buffer
+	if err := osm.WriteXML(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	back, err := osm.ParseXML(&buf)
+	if err != nil {
+		t.Fatalf("ParseXML(WriteXML()) error = %v", err)
+	}
+
+	if !reflect.DeepEqual(back.Nodes, data.Nodes) {
+		t.Errorf("nodes differ after round trip")
+	}
+	if !reflect.DeepEqual(back.Ways, data.Ways) {
+		t.Errorf("ways differ after round trip")
+	}
+	if !reflect.DeepEqual(back.Relations, data.Relations) {
+		t.Errorf("relations differ after round trip")
+	}
+}
@@ -0,0 +1,230 @@
+package osm
+
+import "sort"
+
+// MergeStats reports how much the merged extracts overlapped.
+type MergeStats struct {
+	// SharedNodes and SharedWays count elements present in more than one
+	// extract, typically along the border between them.
+	SharedNodes int
+	SharedWays  int
+	// JoinedWays counts shared ways whose copies each held only part of
+	// the way, clipped at their extract's border, and were joined again.
+	JoinedWays int
+	// ConflictingWays counts shared ways whose copies could not be
+	// joined; the copy with most nodes was kept.
+	ConflictingWays int
+}
+
+// Merge combines extracts into one data set, deduplicating elements by
+// OSM ID. A node found in several extracts keeps the first copy. Copies of
+// a way are reconciled: a copy contained in another is dropped, and copies
+// that overlap end to end, as happens when both extracts clip the way at
+// their border, are joined into one. Relations keep every distinct member.
+//
+// Negative IDs are local to their file, such as the boundary nodes added by
+// Extract, so they are renumbered rather than matched, and ignored at the
+// ends of ways when reconciling copies.
+func Merge(parts ...*Data) (*Data, MergeStats) {
+	out := NewData()
+	var stats MergeStats
+
+	nextID := int64(0)
+	for _, d := range parts {
+		nextID = min(nextID, d.minID())
+	}
+
+	ways := make(map[int64]*Way)
+	var wayOrder []int64
+	relations := make(map[int64]*Relation)
+	var relationOrder []int64
+	sharedWays := make(map[int64]bool)
+
+	for i, d := range parts {
+		if i > 0 {
+			d = d.renumberLocal(&nextID)
+		}
+		for id, n := range d.Nodes {
+			if _, ok := out.Nodes[id]; ok {
+				stats.SharedNodes++
+				continue
+			}
+			out.Nodes[id] = n
+		}
+
+		for _, w := range d.Ways {
+			have, ok := ways[w.ID]
+			if !ok {
+				ways[w.ID] = w
+				wayOrder = append(wayOrder, w.ID)
+				continue
+			}
+			sharedWays[w.ID] = true
+			nodes, joined := reconcileNodes(have.NodeIDs, w.NodeIDs)
+			switch {
+			case joined:
+				stats.JoinedWays++
+			case nodes == nil:
+				stats.ConflictingWays++
+				nodes = have.NodeIDs
+				if len(w.NodeIDs) > len(nodes) {
+					nodes = w.NodeIDs
+				}
+			}
+			ways[w.ID] = &Way{ID: w.ID, NodeIDs: nodes, Tags: mergeTags(have.Tags, w.Tags)}
+		}
+
+		for _, r := range d.Relations {
+			have, ok := relations[r.ID]
+			if !ok {
+				relations[r.ID] = r
+				relationOrder = append(relationOrder, r.ID)
+				continue
+			}
+			members := append([]Member(nil), have.Members...)
+			seen := make(map[Member]bool, len(members))
+			for _, m := range members {
+				seen[m] = true
+			}
+			for _, m := range r.Members {
+				if !seen[m] {
+					seen[m] = true
+					members = append(members, m)
+				}
+			}
+			relations[r.ID] = &Relation{ID: r.ID, Members: members, Tags: mergeTags(have.Tags, r.Tags)}
+		}
+	}
+	stats.SharedWays = len(sharedWays)
+
+	sort.Slice(wayOrder, func(i, j int) bool { return wayOrder[i] < wayOrder[j] })
+	for _, id := range wayOrder {
+		out.Ways = append(out.Ways, ways[id])
+	}
+	sort.Slice(relationOrder, func(i, j int) bool { return relationOrder[i] < relationOrder[j] })
+	for _, id := range relationOrder {
+		out.Relations = append(out.Relations, relations[id])
+	}
+	return out, stats
+}
+
+// reconcileNodes returns the node list covering both copies of a way. It
+// reports joined when neither copy contained the other and they were
+// spliced along their overlap, and returns nil when they cannot be
+// reconciled.
+func reconcileNodes(a, b []int64) ([]int64, bool) {
+	a, b = trimLocal(a), trimLocal(b)
+	switch {
+	case containsRun(a, b):
+		return a, false
+	case containsRun(b, a):
+		return b, false
+	}
+	if n := overlap(a, b); n > 0 {
+		return append(append([]int64(nil), a...), b[n:]...), true
+	}
+	if n := overlap(b, a); n > 0 {
+		return append(append([]int64(nil), b...), a[n:]...), true
+	}
+	return nil, false
+}
+
+// trimLocal drops the file-local nodes that clipping left at either end
+// of a way.
+func trimLocal(ids []int64) []int64 {
+	for len(ids) > 0 && ids[0] < 0 {
+		ids = ids[1:]
+	}
+	for len(ids) > 0 && ids[len(ids)-1] < 0 {
+		ids = ids[:len(ids)-1]
+	}
+	return ids
+}
+
+// renumberLocal returns d with its negative IDs replaced by fresh ones
+// taken downwards from *next, so they cannot collide with another file's.
+func (d *Data) renumberLocal(next *int64) *Data {
+	ids := make(map[int64]int64)
+	local := func(id int64) int64 {
+		if id >= 0 {
+			return id
+		}
+		if mapped, ok := ids[id]; ok {
+			return mapped
+		}
+		*next--
+		ids[id] = *next
+		return *next
+	}
+
+	out := NewData()
+	for id, n := range d.Nodes {
+		if id < 0 {
+			n = &Node{ID: local(id), Lat: n.Lat, Lon: n.Lon, Tags: n.Tags}
+		}
+		out.Nodes[n.ID] = n
+	}
+	for _, w := range d.Ways {
+		nodes := make([]int64, len(w.NodeIDs))
+		for i, id := range w.NodeIDs {
+			nodes[i] = local(id)
+		}
+		out.Ways = append(out.Ways, &Way{ID: local(w.ID), NodeIDs: nodes, Tags: w.Tags})
+	}
+	for _, r := range d.Relations {
+		members := make([]Member, len(r.Members))
+		for i, m := range r.Members {
+			m.Ref = local(m.Ref)
+			members[i] = m
+		}
+		out.Relations = append(out.Relations, &Relation{ID: local(r.ID), Members: members, Tags: r.Tags})
+	}
+	return out
+}
+
+// containsRun reports whether b appears as a contiguous run within a.
+func containsRun(a, b []int64) bool {
+	for i := 0; i+len(b) <= len(a); i++ {
+		match := true
+		for j := range b {
+			if a[i+j] != b[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// overlap returns the length of the longest suffix of a that is also a
+// prefix of b.
+func overlap(a, b []int64) int {
+	for n := min(len(a), len(b)); n > 0; n-- {
+		match := true
+		for j := 0; j < n; j++ {
+			if a[len(a)-n+j] != b[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return n
+		}
+	}
+	return 0
+}
+
+// mergeTags returns the union of both tag sets, preferring a's values.
+func mergeTags(a, b map[string]string) map[string]string {
+	out := make(map[string]string, len(a)+len(b))
+	for k, v := range b {
+		out[k] = v
+	}
+	for k, v := range a {
+		out[k] = v
+	}
+	return out
+}
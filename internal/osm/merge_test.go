@@ -0,0 +1,96 @@
+package osm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/osm"
+	"github.com/danielscoffee/pathcraft/internal/routing/astar"
+)
+
+func extractData(nodes map[int64][2]float64, ways map[int64][]int64) *osm.Data {
+	d := osm.NewData()
+	for id, c := range nodes {
+		d.Nodes[id] = &osm.Node{ID: id, Lat: c[0], Lon: c[1], Tags: map[string]string{}}
+	}
+	for id, refs := range ways {
+		d.Ways = append(d.Ways, &osm.Way{ID: id, NodeIDs: refs, Tags: map[string]string{"highway": "footway"}})
+	}
+	return d
+}
+
+func TestMergeReconcilesWays(t *testing.T) {
+	west := extractData(
+		map[int64][2]float64{1: {0, 0}, 2: {0, 1}, 3: {0, 2}, 7: {1, 1}},
+		map[int64][]int64{
+			10: {1, 2, 3},
+			11: {2, 7},
+			12: {1, 7},
+		},
+	)
+	east := extractData(
+		map[int64][2]float64{2: {0, 1}, 3: {0, 2}, 4: {0, 3}, 7: {1, 1}, 8: {1, 3}},
+		map[int64][]int64{
+			10: {2, 3, 4}, // the rest of way 10, clipped differently
+			11: {2, 7},    // an identical copy
+			12: {4, 8},    // a different way under the same ID
+		},
+	)
+
+	merged, stats := osm.Merge(west, east)
+
+	if len(merged.Nodes) != 6 {
+		t.Errorf("got %d nodes, want 6 after deduplication", len(merged.Nodes))
+	}
+	want := osm.MergeStats{SharedNodes: 3, SharedWays: 3, JoinedWays: 1, ConflictingWays: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	byID := make(map[int64][]int64)
+	for _, w := range merged.Ways {
+		byID[w.ID] = w.NodeIDs
+	}
+	if len(merged.Ways) != 3 {
+		t.Errorf("got %d ways, want 3", len(merged.Ways))
+	}
+	if got := byID[10]; !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("way 10 = %v, want [1 2 3 4]", got)
+	}
+	if got := byID[11]; !reflect.DeepEqual(got, []int64{2, 7}) {
+		t.Errorf("way 11 = %v, want [2 7]", got)
+	}
+}
+
+func TestMergeClippedExtractsConnect(t *testing.T) {
+	d := extractData(
+		map[int64][2]float64{1: {0.5, 0.1}, 2: {0.5, 0.45}, 3: {0.5, 0.55}, 4: {0.5, 0.9}},
+		map[int64][]int64{10: {1, 2, 3, 4}},
+	)
+	west := d.Extract(geo.BBox{MinLat: 0, MinLon: 0, MaxLat: 1, MaxLon: 0.6})
+	east := d.Extract(geo.BBox{MinLat: 0, MinLon: 0.4, MaxLat: 1, MaxLon: 1})
+
+	merged, stats := osm.Merge(west, east)
+	if stats.JoinedWays != 1 {
+		t.Fatalf("stats = %+v, want way 10 joined across the border", stats)
+	}
+	for _, n := range merged.Nodes {
+		for _, other := range merged.Nodes {
+			if n != other && n.ID == other.ID {
+				t.Fatalf("node ID %d used twice", n.ID)
+			}
+		}
+	}
+
+	g := osm.BuildGraph(merged, osm.DefaultFilter())
+	h := func(from, to graph.Node) float64 { return 0 }
+	path, err := astar.AStar(g, 1, 4, h)
+	if err != nil {
+		t.Fatalf("no route across the border: %v", err)
+	}
+	if len(path.Nodes) != 4 {
+		t.Errorf("path = %v, want 1 2 3 4", path.Nodes)
+	}
+}
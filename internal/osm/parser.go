@@ -8,8 +8,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/danielscoffee/pathcraft/internal/elevation"
 	"github.com/danielscoffee/pathcraft/internal/geo"
 	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+	pcTime "github.com/danielscoffee/pathcraft/internal/time"
 )
 
 type Node struct {
@@ -25,9 +28,24 @@ type Way struct {
 	Tags    map[string]string
 }
 
+// Member is one element of a relation, such as the outer way of a
+// multipolygon.
+type Member struct {
+	Type string // "node", "way" or "relation"
+	Ref  int64
+	Role string
+}
+
+type Relation struct {
+	ID      int64
+	Members []Member
+	Tags    map[string]string
+}
+
 type Data struct {
-	Nodes map[int64]*Node
-	Ways  []*Way
+	Nodes     map[int64]*Node
+	Ways      []*Way
+	Relations []*Relation
 }
 
 func NewData() *Data {
@@ -38,8 +56,9 @@ func NewData() *Data {
 }
 
 type xmlOSM struct {
-	Nodes []xmlNode `xml:"node"`
-	Ways  []xmlWay  `xml:"way"`
+	Nodes     []xmlNode     `xml:"node"`
+	Ways      []xmlWay      `xml:"way"`
+	Relations []xmlRelation `xml:"relation"`
 }
 
 type xmlNode struct {
@@ -55,6 +74,18 @@ type xmlWay struct {
 	Tags     []xmlTag `xml:"tag"`
 }
 
+type xmlRelation struct {
+	ID      int64       `xml:"id,attr"`
+	Members []xmlMember `xml:"member"`
+	Tags    []xmlTag    `xml:"tag"`
+}
+
+type xmlMember struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
 type xmlNd struct {
 	Ref int64 `xml:"ref,attr"`
 }
@@ -70,7 +101,10 @@ func ParseXML(r io.Reader) (*Data, error) {
 	if err := decoder.Decode(&osm); err != nil {
 		return nil, fmt.Errorf("decoding XML: %w", err)
 	}
+	return osm.data(), nil
+}
 
+func (osm *xmlOSM) data() *Data {
 	data := NewData()
 
 	for _, n := range osm.Nodes {
@@ -104,13 +138,43 @@ func ParseXML(r io.Reader) (*Data, error) {
 		})
 	}
 
-	return data, nil
+	for _, r := range osm.Relations {
+		tags := make(map[string]string)
+		for _, t := range r.Tags {
+			tags[t.K] = t.V
+		}
+
+		members := make([]Member, len(r.Members))
+		for i, m := range r.Members {
+			members[i] = Member{Type: m.Type, Ref: m.Ref, Role: m.Role}
+		}
+
+		data.Relations = append(data.Relations, &Relation{
+			ID:      r.ID,
+			Members: members,
+			Tags:    tags,
+		})
+	}
+
+	return data
 }
 
 func ParseFile(path string) (*Data, error) {
+	var data *Data
+	err := readFile(path, func(r io.Reader) error {
+		var err error
+		data, err = ParseXML(r)
+		return err
+	})
+	return data, err
+}
+
+// readFile opens path, decompressing it when the name ends in .gz, and
+// hands the contents to parse.
+func readFile(path string, parse func(io.Reader) error) error {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		_ = f.Close()
@@ -121,7 +185,7 @@ func ParseFile(path string) (*Data, error) {
 	if strings.HasSuffix(path, ".gz") {
 		gzReader, err := gzip.NewReader(f)
 		if err != nil {
-			return nil, fmt.Errorf("opening gzip: %w", err)
+			return fmt.Errorf("opening gzip: %w", err)
 		}
 		defer func() {
 			_ = gzReader.Close()
@@ -129,27 +193,27 @@ func ParseFile(path string) (*Data, error) {
 		reader = gzReader
 	}
 
-	return ParseXML(reader)
+	return parse(reader)
 }
 
-var WalkableHighways = map[string]bool{
-	"footway":       true,
-	"path":          true,
-	"pedestrian":    true,
-	"steps":         true,
-	"residential":   true,
-	"living_street": true,
-	"service":       true,
-	"track":         true,
-	"unclassified":  true,
-	"tertiary":      true,
-	"secondary":     true,
-	"primary":       true,
-	"trunk":         true,
-}
+// WalkableHighways is the default pedestrian way filter.
+var WalkableHighways = mobility.PedestrianHighways
 
 type Filter struct {
 	IncludeHighways map[string]bool
+	// Profile turns segments into edge costs. Nil means walking at the
+	// default speed.
+	Profile mobility.Profile
+	// Elevation, when set, is sampled for every graph node so profiles can
+	// price in slopes.
+	Elevation elevation.Source
+	// MinComponentSize drops strongly connected components with fewer
+	// nodes, such as private courtyards or ways clipped at the extract
+	// border. The largest component is always kept. Zero keeps everything.
+	MinComponentSize int
+	// FlagSmallComponents keeps those components and lists their nodes in
+	// Graph.Islands instead of dropping them.
+	FlagSmallComponents bool
 }
 
 func DefaultFilter() *Filter {
@@ -176,10 +240,28 @@ func (f *Filter) IsWalkable(w *Way) bool {
 	return highways[highway]
 }
 
+// Allows reports in which directions the filter's profile may use w.
+// Profiles with their own access rules decide alone; the rest are treated
+// as pedestrians, who ignore oneway restrictions.
+func (f *Filter) Allows(w *Way) (forward, backward bool) {
+	if wf, ok := f.profile().(mobility.WayFilter); ok {
+		return wf.WayAccess(w.Tags)
+	}
+	walkable := f.IsWalkable(w)
+	return walkable, walkable
+}
+
+func (f *Filter) profile() mobility.Profile {
+	if f.Profile == nil {
+		return mobility.NewWalking(0)
+	}
+	return f.Profile
+}
+
 func (d *Data) FilterWays(f *Filter) []*Way {
 	var result []*Way
 	for _, w := range d.Ways {
-		if f.IsWalkable(w) {
+		if forward, backward := f.Allows(w); forward || backward {
 			result = append(result, w)
 		}
 	}
@@ -190,8 +272,11 @@ func BuildGraph(data *Data, filter *Filter) *graph.Graph {
 	if filter == nil {
 		filter = DefaultFilter()
 	}
+	profile := filter.profile()
 
 	g := graph.NewGraph()
+	g.Profile = profile.Name()
+	g.Speed = profile.Speed()
 
 	walkableWays := data.FilterWays(filter)
 
@@ -201,34 +286,199 @@ func BuildGraph(data *Data, filter *Filter) *graph.Graph {
 			referencedNodes[nodeID] = true
 		}
 	}
+	degree := neighbourCounts(walkableWays)
+
+	// Open spaces get a visibility graph across their interior, so their
+	// vertices join the graph even when the outline itself is untagged.
+	areas := data.PedestrianAreas(filter)
+	for _, a := range areas {
+		for _, ring := range a.rings() {
+			for _, nodeID := range ring {
+				referencedNodes[nodeID] = true
+			}
+		}
+	}
 
+	// Node costs apply when entering a node; forbidden nodes (a raised
+	// kerb, a locked gate) are left out so no edge can pass through them.
+	nodeCosts := make(map[graph.NodeID]mobility.Cost)
 	for nodeID := range referencedNodes {
-		node, ok := data.Nodes[nodeID]
-		if !ok {
+		addNode(g, filter, data.Nodes[nodeID], degree[nodeID], nodeCosts)
+	}
+
+	for _, w := range walkableWays {
+		addWayEdges(g, filter, w, nodeCosts, nil)
+	}
+
+	if len(areas) > 0 {
+		nearby := newAreaWays(data, walkableWays)
+		for _, a := range areas {
+			addAreaEdges(g, profile, a, nearby.near(data, a), nodeCosts)
+		}
+	}
+
+	pruneComponents(g, filter)
+
+	return g
+}
+
+// addNode adds node to g unless it is missing or the profile forbids it,
+// recording its entry cost in nodeCosts.
+func addNode(g *graph.Graph, filter *Filter, node *Node, degree int, nodeCosts map[graph.NodeID]mobility.Cost) {
+	if node == nil {
+		return
+	}
+	cost := mobility.NodeCost(filter.profile(), mobility.NodeInfo{
+		Tags:   node.Tags,
+		Degree: degree,
+	})
+	if cost.Forbidden {
+		return
+	}
+	if cost != (mobility.Cost{}) {
+		nodeCosts[graph.NodeID(node.ID)] = cost
+	}
+
+	g.AddNode(graph.NodeID(node.ID), node.Lat, node.Lon)
+	if filter.Elevation != nil {
+		if ele, ok := filter.Elevation.Elevation(node.Lat, node.Lon); ok {
+			n := g.Nodes[graph.NodeID(node.ID)]
+			n.Ele = ele
+			g.Nodes[n.ID] = n
+		}
+	}
+}
+
+// addWayEdges adds the edges along w between nodes already in g. When
+// only is set, segments it rejects are skipped.
+func addWayEdges(g *graph.Graph, filter *Filter, w *Way, nodeCosts map[graph.NodeID]mobility.Cost, only func(from, to graph.NodeID) bool) {
+	profile := filter.profile()
+	forward, backward := filter.Allows(w)
+
+	for i := 0; i < len(w.NodeIDs)-1; i++ {
+		fromID := graph.NodeID(w.NodeIDs[i])
+		toID := graph.NodeID(w.NodeIDs[i+1])
+		if only != nil && !only(fromID, toID) {
+			continue
+		}
+
+		fromNode, okFrom := g.Nodes[fromID]
+		toNode, okTo := g.Nodes[toID]
+		if !okFrom || !okTo {
 			continue
 		}
-		g.AddNode(graph.NodeID(nodeID), node.Lat, node.Lon)
+
+		distance := geo.HaversineDistance(fromNode.Lat, fromNode.Lon, toNode.Lat, toNode.Lon)
+
+		if forward {
+			if e, ok := segmentEdge(g, profile, w, fromNode, toNode, distance, false, nodeCosts[toID]); ok {
+				g.AppendEdge(fromID, e)
+			}
+		}
+		if backward {
+			if e, ok := segmentEdge(g, profile, w, toNode, fromNode, distance, true, nodeCosts[fromID]); ok {
+				g.AppendEdge(toID, e)
+			}
+		}
 	}
+}
 
-	for _, w := range walkableWays {
-		isOneway := w.Tags["oneway"] == "yes" || w.Tags["oneway"] == "1"
-		_ = isOneway
+// pruneComponents drops or flags the components below the filter's
+// minimum size.
+func pruneComponents(g *graph.Graph, filter *Filter) {
+	if filter.MinComponentSize <= 0 {
+		return
+	}
+	small := g.StronglyConnectedComponents().Below(filter.MinComponentSize)
+	if filter.FlagSmallComponents {
+		g.Islands = small
+	} else {
+		g.RemoveNodes(small)
+	}
+}
 
+// neighbourCounts returns, for every node, how many distinct nodes the
+// ways connect it to, regardless of direction.
+func neighbourCounts(ways []*Way) map[int64]int {
+	neighbours := make(map[int64]map[int64]bool)
+	link := func(a, b int64) {
+		if neighbours[a] == nil {
+			neighbours[a] = make(map[int64]bool)
+		}
+		neighbours[a][b] = true
+	}
+	for _, w := range ways {
 		for i := 0; i < len(w.NodeIDs)-1; i++ {
-			fromID := w.NodeIDs[i]
-			toID := w.NodeIDs[i+1]
-
-			fromNode, okFrom := data.Nodes[fromID]
-			toNode, okTo := data.Nodes[toID]
-			if !okFrom || !okTo {
+			a, b := w.NodeIDs[i], w.NodeIDs[i+1]
+			if a == b {
 				continue
 			}
+			link(a, b)
+			link(b, a)
+		}
+	}
 
-			distance := geo.HaversineDistance(fromNode.Lat, fromNode.Lon, toNode.Lat, toNode.Lon)
+	counts := make(map[int64]int, len(neighbours))
+	for id, set := range neighbours {
+		counts[id] = len(set)
+	}
+	return counts
+}
 
-			g.AddBidirectionalEdge(graph.NodeID(fromID), graph.NodeID(toID), distance)
-		}
+// segmentEdge prices the travel from one node to the next along w,
+// including the cost of entering the destination node. It reports false
+// when the profile forbids the segment.
+func segmentEdge(g *graph.Graph, profile mobility.Profile, w *Way, from, to graph.Node, distance float64, reverse bool, enter mobility.Cost) (graph.Edge, bool) {
+	ascent, descent := elevation.Climb(from.Ele, to.Ele)
+
+	grade := 0.0
+	if distance > 0 {
+		grade = (to.Ele - from.Ele) / distance
 	}
 
-	return g
+	cost := mobility.SegmentCost(profile, mobility.Segment{
+		DistanceM: distance,
+		Grade:     grade,
+		Tags:      w.Tags,
+		Reverse:   reverse,
+	})
+	if cost.Forbidden {
+		return graph.Edge{}, false
+	}
+
+	return graph.Edge{
+		To:        to.ID,
+		Cost:      pcTime.Seconds(cost.Seconds + cost.Penalty + enter.Seconds + enter.Penalty),
+		Duration:  pcTime.Seconds(cost.Seconds + enter.Seconds),
+		DistanceM: distance,
+		Ascent:    ascent,
+		Descent:   descent,
+		Note:      g.NoteID(cost.Note, enter.Note),
+		Class:     g.ClassID(w.Tags["highway"]),
+	}, true
+}
+
+// Node tags that describe how a node was mapped rather than anything on
+// the ground.
+var metadataNodeTags = map[string]bool{
+	"created_by": true,
+	"source":     true,
+	"note":       true,
+	"fixme":      true,
+	"FIXME":      true,
+}
+
+// KeepNode reports whether a node must survive graph.Simplify because it
+// means something of its own, such as a barrier, crossing or stop.
+func (d *Data) KeepNode(id graph.NodeID) bool {
+	n, ok := d.Nodes[int64(id)]
+	if !ok {
+		return false
+	}
+	for k := range n.Tags {
+		if !metadataNodeTags[k] {
+			return true
+		}
+	}
+	return false
 }
@@ -1,11 +1,16 @@
 package osm_test
 
 import (
+	"fmt"
+	"math"
 	"strings"
 	"testing"
 
 	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/mobility"
 	"github.com/danielscoffee/pathcraft/internal/osm"
+	"github.com/danielscoffee/pathcraft/internal/routing/astar"
 )
 
 const testOSMXML = `<?xml version="1.0" encoding="UTF-8"?>
@@ -174,3 +179,367 @@ func TestHaversineDistance(t *testing.T) {
 		t.Errorf("HaversineDistance = %v, expected ~1300m", dist)
 	}
 }
+
+// rampSource rises one metre per 0.0001 degrees of latitude.
+type rampSource struct{}
+
+func (rampSource) Elevation(lat, _ float64) (float64, bool) {
+	return (lat - 55.6761) * 10000, true
+}
+
+func TestBuildGraph_Elevation(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(testOSMXML))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	g := osm.BuildGraph(data, &osm.Filter{Elevation: rampSource{}})
+
+	if ele := g.Nodes[2].Ele; ele < 9.99 || ele > 10.01 {
+		t.Errorf("node 2 elevation = %v, want 10", ele)
+	}
+
+	up, ok := g.EdgeBetween(1, 2)
+	if !ok {
+		t.Fatal("missing edge 1 -> 2")
+	}
+	down, ok := g.EdgeBetween(2, 1)
+	if !ok {
+		t.Fatal("missing edge 2 -> 1")
+	}
+
+	if up.Ascent < 9.99 || up.Descent != 0 {
+		t.Errorf("uphill edge ascent/descent = %v/%v, want 10/0", up.Ascent, up.Descent)
+	}
+	if down.Descent < 9.99 || down.Ascent != 0 {
+		t.Errorf("downhill edge ascent/descent = %v/%v, want 0/10", down.Ascent, down.Descent)
+	}
+	if up.Cost <= down.Cost {
+		t.Errorf("walking uphill (%vs) should take longer than downhill (%vs)", up.Cost, down.Cost)
+	}
+}
+
+const wheelchairOSMXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0001" lon="0.0000"/>
+  <node id="3" lat="0.0002" lon="0.0000">
+    <tag k="kerb" v="raised"/>
+  </node>
+  <node id="4" lat="0.0002" lon="0.0001">
+    <tag k="highway" v="crossing"/>
+  </node>
+  <node id="5" lat="0.0003" lon="0.0000"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="11">
+    <nd ref="2"/>
+    <nd ref="4"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="12">
+    <nd ref="4"/>
+    <nd ref="5"/>
+    <tag k="highway" v="steps"/>
+  </way>
+</osm>`
+
+func TestBuildGraph_WheelchairProfile(t *testing.T) {
+	data, err := osm.ParseXML(strings.NewReader(wheelchairOSMXML))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	g := osm.BuildGraph(data, &osm.Filter{Profile: mobility.NewWheelchair(0)})
+
+	if g.Profile != "wheelchair" {
+		t.Errorf("graph profile = %q, want wheelchair", g.Profile)
+	}
+	if g.HasNode(3) {
+		t.Error("node behind a raised kerb should be excluded")
+	}
+	if g.HasNode(5) {
+		t.Error("node only reachable by steps should be excluded")
+	}
+
+	e, ok := g.EdgeBetween(2, 4)
+	if !ok {
+		t.Fatal("missing edge 2 -> 4")
+	}
+	if note := g.NoteText(e); note == "" {
+		t.Error("entering an unmapped crossing should carry a note")
+	}
+}
+
+func TestBuildGraph_CyclingOneway(t *testing.T) {
+	const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0001" lon="0.0000"/>
+  <node id="3" lat="0.0002" lon="0.0000"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <tag k="highway" v="residential"/>
+    <tag k="oneway" v="yes"/>
+  </way>
+  <way id="11">
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="residential"/>
+    <tag k="oneway" v="yes"/>
+    <tag k="oneway:bicycle" v="no"/>
+  </way>
+</osm>`
+
+	data, err := osm.ParseXML(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	g := osm.BuildGraph(data, &osm.Filter{Profile: mobility.NewCycling(0)})
+
+	if _, ok := g.EdgeBetween(1, 2); !ok {
+		t.Error("expected edge 1 -> 2 along the oneway")
+	}
+	if _, ok := g.EdgeBetween(2, 1); ok {
+		t.Error("unexpected edge 2 -> 1 against the oneway")
+	}
+	if _, ok := g.EdgeBetween(3, 2); !ok {
+		t.Error("expected contraflow edge 3 -> 2 with oneway:bicycle=no")
+	}
+}
+
+func TestBuildGraph_DrivingProfile(t *testing.T) {
+	const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0010" lon="0.0000">
+    <tag k="highway" v="traffic_signals"/>
+  </node>
+  <node id="3" lat="0.0020" lon="0.0000"/>
+  <node id="4" lat="0.0020" lon="0.0010"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="residential"/>
+    <tag k="maxspeed" v="36"/>
+  </way>
+  <way id="11">
+    <nd ref="3"/>
+    <nd ref="4"/>
+    <tag k="highway" v="footway"/>
+  </way>
+</osm>`
+
+	data, err := osm.ParseXML(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	g := osm.BuildGraph(data, &osm.Filter{Profile: mobility.NewDriving(0)})
+
+	if g.HasNode(4) {
+		t.Error("footway-only node should not be in a driving graph")
+	}
+
+	toSignal, ok := g.EdgeBetween(1, 2)
+	if !ok {
+		t.Fatal("missing edge 1 -> 2")
+	}
+	fromSignal, ok := g.EdgeBetween(2, 3)
+	if !ok {
+		t.Fatal("missing edge 2 -> 3")
+	}
+
+	delay := float64(toSignal.Cost) - toSignal.DistanceM/10
+	if delay < mobility.TrafficSignalDelay-1e-6 || delay > mobility.TrafficSignalDelay+1e-6 {
+		t.Errorf("entering the signal adds %vs, want %vs", delay, mobility.TrafficSignalDelay)
+	}
+	if math.Abs(float64(fromSignal.Cost)-fromSignal.DistanceM/10) > 1e-6 {
+		t.Errorf("leaving the signal costs %vs, want plain travel time %vs", fromSignal.Cost, fromSignal.DistanceM/10)
+	}
+}
+
+func TestBuildGraph_Barriers(t *testing.T) {
+	const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0010" lon="0.0000">
+    <tag k="barrier" v="gate"/>
+    <tag k="locked" v="yes"/>
+  </node>
+  <node id="3" lat="0.0020" lon="0.0000"/>
+  <node id="4" lat="0.0030" lon="0.0000">
+    <tag k="barrier" v="bollard"/>
+  </node>
+  <node id="5" lat="0.0040" lon="0.0000"/>
+  <way id="10">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <nd ref="4"/>
+    <nd ref="5"/>
+    <tag k="highway" v="residential"/>
+  </way>
+</osm>`
+
+	data, err := osm.ParseXML(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	walking := osm.BuildGraph(data, &osm.Filter{Profile: mobility.NewWalking(0)})
+	if walking.HasNode(2) {
+		t.Error("locked gate should be removed from the walking graph")
+	}
+	if _, ok := walking.EdgeBetween(3, 4); !ok {
+		t.Error("pedestrians should pass the bollard")
+	}
+
+	driving := osm.BuildGraph(data, &osm.Filter{Profile: mobility.NewDriving(0)})
+	if driving.HasNode(4) {
+		t.Error("bollard should be removed from the driving graph")
+	}
+	if _, ok := driving.EdgeBetween(3, 4); ok {
+		t.Error("unexpected edge into the bollard for cars")
+	}
+}
+
+// plazaOSMXML is a 100 m square with entrances at opposite corners and,
+// as a multipolygon, a fountain in the middle.
+const plazaOSMXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0000" lon="0.0009"/>
+  <node id="3" lat="0.0009" lon="0.0009"/>
+  <node id="4" lat="0.0009" lon="0.0000"/>
+  <node id="5" lat="-0.0005" lon="0.0000"/>
+  <node id="6" lat="0.0014" lon="0.0009"/>
+  <node id="11" lat="0.0004" lon="0.0004"/>
+  <node id="12" lat="0.0004" lon="0.0005"/>
+  <node id="13" lat="0.0005" lon="0.0005"/>
+  <node id="14" lat="0.0005" lon="0.0004"/>
+  <way id="100">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/><nd ref="4"/><nd ref="1"/>
+    %s
+  </way>
+  <way id="101">
+    <nd ref="11"/><nd ref="12"/><nd ref="13"/><nd ref="14"/><nd ref="11"/>
+  </way>
+  <way id="200">
+    <nd ref="5"/><nd ref="1"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="201">
+    <nd ref="3"/><nd ref="6"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  %s
+</osm>`
+
+func TestBuildGraph_PedestrianArea(t *testing.T) {
+	tests := []struct {
+		name      string
+		wayTags   string
+		relation  string
+		diagonal  bool
+		crossHole bool
+	}{
+		{
+			name:     "closed way area",
+			wayTags:  `<tag k="highway" v="pedestrian"/><tag k="area" v="yes"/>`,
+			diagonal: true,
+		},
+		{
+			name:    "ring road is not an area",
+			wayTags: `<tag k="highway" v="pedestrian"/>`,
+		},
+		{
+			name: "multipolygon with hole",
+			relation: `<relation id="300">
+    <member type="way" ref="100" role="outer"/>
+    <member type="way" ref="101" role="inner"/>
+    <tag k="type" v="multipolygon"/>
+    <tag k="highway" v="pedestrian"/>
+  </relation>`,
+			crossHole: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := osm.ParseXML(strings.NewReader(fmt.Sprintf(plazaOSMXML, tt.wayTags, tt.relation)))
+			if err != nil {
+				t.Fatalf("ParseXML() error = %v", err)
+			}
+
+			g := osm.BuildGraph(data, nil)
+
+			if _, ok := g.EdgeBetween(1, 3); ok != tt.diagonal {
+				t.Errorf("diagonal edge 1 -> 3 present = %v, want %v", ok, tt.diagonal)
+			}
+			if !tt.crossHole {
+				return
+			}
+
+			path, err := astar.AStarWeighted(g, 5, 6, func(_, _ graph.Node) float64 { return 0 }, astar.ByDistance)
+			if err != nil {
+				t.Fatalf("no route across the plaza: %v", err)
+			}
+			for _, id := range path.Nodes {
+				if id == 2 || id == 4 {
+					t.Errorf("route %v walks around the edge instead of crossing", path.Nodes)
+				}
+			}
+			if _, ok := g.EdgeBetween(1, 13); ok {
+				t.Error("edge 1 -> 13 passes through the fountain")
+			}
+		})
+	}
+}
+
+func TestBuildGraph_MinComponentSize(t *testing.T) {
+	const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="0.0000" lon="0.0000"/>
+  <node id="2" lat="0.0010" lon="0.0000"/>
+  <node id="3" lat="0.0020" lon="0.0000"/>
+  <node id="4" lat="0.0100" lon="0.0100"/>
+  <node id="5" lat="0.0110" lon="0.0100"/>
+  <way id="10">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="11">
+    <nd ref="4"/><nd ref="5"/>
+    <tag k="highway" v="footway"/>
+  </way>
+</osm>`
+
+	data, err := osm.ParseXML(strings.NewReader(xml))
+	if err != nil {
+		t.Fatalf("ParseXML() error = %v", err)
+	}
+
+	dropped := osm.BuildGraph(data, &osm.Filter{MinComponentSize: 3})
+	if dropped.HasNode(4) || dropped.HasNode(5) {
+		t.Error("the two-node fragment should be dropped")
+	}
+	if len(dropped.Nodes) != 3 {
+		t.Errorf("got %d nodes, want the 3 of the main component", len(dropped.Nodes))
+	}
+
+	flagged := osm.BuildGraph(data, &osm.Filter{MinComponentSize: 3, FlagSmallComponents: true})
+	if !flagged.HasNode(4) {
+		t.Error("flagged fragments should be kept")
+	}
+	if len(flagged.Islands) != 2 {
+		t.Errorf("Islands = %v, want nodes 4 and 5", flagged.Islands)
+	}
+}
@@ -0,0 +1,188 @@
+package osm
+
+import (
+	"errors"
+
+	"github.com/danielscoffee/pathcraft/internal/graph"
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+)
+
+// ErrSimplified is returned when updating a graph whose shape points were
+// collapsed by graph.Simplify; such graphs must be rebuilt instead.
+var ErrSimplified = errors.New("graph is simplified")
+
+// UpdateStats reports what an update changed.
+type UpdateStats struct {
+	ChangeStats
+	// Graph node and edge counts before and after the update.
+	NodesBefore, NodesAfter int
+	EdgesBefore, EdgesAfter int
+	// EdgesRemoved and EdgesAdded count the edges recomputed around the
+	// changed nodes and ways; an edge that was merely recomputed is in both.
+	EdgesRemoved, EdgesAdded int
+	// Rebuilt is set when the change touched a pedestrian area, whose
+	// visibility edges cannot be patched, and the graph was rebuilt.
+	Rebuilt bool
+}
+
+// UpdateGraph applies change to data, the OSM data g was built from, and
+// patches g to match as if BuildGraph had been run again with filter.
+//
+// Only edges that can have changed are recomputed: those at a node the
+// change created, moved or retagged, or at any node of a changed way. An
+// edge's cost depends only on its way, its two nodes and the degree of the
+// node it enters, and all of these are unchanged for the other edges.
+// Nodes that an earlier build dropped as a small component are not
+// revived when the change connects them; rebuild to recover those.
+func UpdateGraph(g *graph.Graph, data *Data, change *Change, filter *Filter) (UpdateStats, error) {
+	if filter == nil {
+		filter = DefaultFilter()
+	}
+	stats := UpdateStats{NodesBefore: len(g.Nodes), EdgesBefore: countEdges(g)}
+	for _, edges := range g.Edges {
+		for _, e := range edges {
+			if len(e.Geometry) > 0 {
+				return stats, ErrSimplified
+			}
+		}
+	}
+
+	touched := make(map[int64]bool)
+	ways := make(map[int64]*Way, len(data.Ways))
+	for _, w := range data.Ways {
+		ways[w.ID] = w
+	}
+	for _, b := range change.Blocks {
+		for id := range b.Data.Nodes {
+			touched[id] = true
+		}
+		for _, w := range b.Data.Ways {
+			// Deletions may list the way without its nodes, so the old
+			// version is taken from data.
+			if old, ok := ways[w.ID]; ok {
+				for _, id := range old.NodeIDs {
+					touched[id] = true
+				}
+			}
+			for _, id := range w.NodeIDs {
+				touched[id] = true
+			}
+		}
+	}
+
+	areaTouched := func() bool {
+		for _, a := range data.PedestrianAreas(filter) {
+			for _, ring := range a.rings() {
+				for _, id := range ring {
+					if touched[id] {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	if areaTouched() {
+		stats.ChangeStats = data.Apply(change)
+		return rebuild(g, data, filter, stats), nil
+	}
+
+	// Drop every edge at a touched node, and the nodes themselves.
+	for from, edges := range g.Edges {
+		kept := edges[:0]
+		for _, e := range edges {
+			if touched[int64(from)] || touched[int64(e.To)] {
+				stats.EdgesRemoved++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if len(kept) == 0 {
+			delete(g.Edges, from)
+		} else {
+			g.Edges[from] = kept
+		}
+	}
+	for id := range touched {
+		delete(g.Nodes, graph.NodeID(id))
+	}
+
+	stats.ChangeStats = data.Apply(change)
+	if areaTouched() {
+		return rebuild(g, data, filter, stats), nil
+	}
+
+	walkableWays := data.FilterWays(filter)
+	degree := neighbourCounts(walkableWays)
+
+	nodeCosts := make(map[graph.NodeID]mobility.Cost)
+	added := make(map[int64]bool)
+	for _, w := range walkableWays {
+		for _, id := range w.NodeIDs {
+			if touched[id] && !added[id] {
+				added[id] = true
+				addNode(g, filter, data.Nodes[id], degree[id], nodeCosts)
+			}
+		}
+	}
+
+	// Entry costs of untouched nodes are needed for edges leading into
+	// them from touched ones.
+	entry := func(id graph.NodeID) {
+		if _, ok := nodeCosts[id]; ok || touched[int64(id)] {
+			return
+		}
+		if n, ok := data.Nodes[int64(id)]; ok {
+			cost := mobility.NodeCost(filter.profile(), mobility.NodeInfo{Tags: n.Tags, Degree: degree[int64(id)]})
+			if cost != (mobility.Cost{}) {
+				nodeCosts[id] = cost
+			}
+		}
+	}
+	near := func(from, to graph.NodeID) bool {
+		if !touched[int64(from)] && !touched[int64(to)] {
+			return false
+		}
+		entry(from)
+		entry(to)
+		return true
+	}
+
+	before := countEdges(g)
+	for _, w := range walkableWays {
+		addWayEdges(g, filter, w, nodeCosts, near)
+	}
+	stats.EdgesAdded = countEdges(g) - before
+
+	if len(g.Islands) > 0 {
+		kept := g.Islands[:0]
+		for _, id := range g.Islands {
+			if g.HasNode(id) {
+				kept = append(kept, id)
+			}
+		}
+		g.Islands = kept
+	}
+	pruneComponents(g, filter)
+
+	stats.NodesAfter = len(g.Nodes)
+	stats.EdgesAfter = countEdges(g)
+	return stats, nil
+}
+
+func rebuild(g *graph.Graph, data *Data, filter *Filter, stats UpdateStats) UpdateStats {
+	*g = *BuildGraph(data, filter)
+	stats.Rebuilt = true
+	stats.NodesAfter = len(g.Nodes)
+	stats.EdgesAfter = countEdges(g)
+	return stats
+}
+
+func countEdges(g *graph.Graph) int {
+	count := 0
+	for _, edges := range g.Edges {
+		count += len(edges)
+	}
+	return count
+}
@@ -0,0 +1,113 @@
+package osm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteXML writes d as an OSM XML document that ParseXML and other OSM
+// tools can read. Elements are ordered by ID and tags by key, so the same
+// data always produces the same file.
+func WriteXML(w io.Writer, d *Data) error {
+	bw := bufio.NewWriter(w)
+	p := func(format string, args ...any) {
+		fmt.Fprintf(bw, format, args...)
+	}
+
+	p("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	p("<osm version=\"0.6\" generator=\"pathcraft\">\n")
+
+	ids := make([]int64, 0, len(d.Nodes))
+	for id := range d.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		n := d.Nodes[id]
+		p("  <node id=\"%d\" lat=\"%s\" lon=\"%s\"", n.ID, coord(n.Lat), coord(n.Lon))
+		if len(n.Tags) == 0 {
+			p("/>\n")
+			continue
+		}
+		p(">\n")
+		writeTags(bw, n.Tags)
+		p("  </node>\n")
+	}
+
+	ways := append([]*Way(nil), d.Ways...)
+	sort.SliceStable(ways, func(i, j int) bool { return ways[i].ID < ways[j].ID })
+	for _, w := range ways {
+		p("  <way id=\"%d\">\n", w.ID)
+		for _, ref := range w.NodeIDs {
+			p("    <nd ref=\"%d\"/>\n", ref)
+		}
+		writeTags(bw, w.Tags)
+		p("  </way>\n")
+	}
+
+	relations := append([]*Relation(nil), d.Relations...)
+	sort.SliceStable(relations, func(i, j int) bool { return relations[i].ID < relations[j].ID })
+	for _, r := range relations {
+		p("  <relation id=\"%d\">\n", r.ID)
+		for _, m := range r.Members {
+			p("    <member type=\"%s\" ref=\"%d\" role=\"%s\"/>\n", escape(m.Type), m.Ref, escape(m.Role))
+		}
+		writeTags(bw, r.Tags)
+		p("  </relation>\n")
+	}
+
+	p("</osm>\n")
+	return bw.Flush()
+}
+
+// WriteFile writes d to path, compressed when the name ends in .gz.
+func WriteFile(path string, d *Data) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if !strings.HasSuffix(path, ".gz") {
+		return WriteXML(f, d)
+	}
+	gz := gzip.NewWriter(f)
+	if err := WriteXML(gz, d); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTags(w io.Writer, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "    <tag k=\"%s\" v=\"%s\"/>\n", escape(k), escape(tags[k]))
+	}
+}
+
+// coord formats a latitude or longitude with the seven decimals OSM
+// stores, without exponents.
+func coord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 7, 64)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,241 @@
+// Package profiles loads routing profiles from JSON or YAML definition
+// files, so profiles can be tuned without recompiling.
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a profile file.
+type Format int
+
+const (
+	JSON Format = iota
+	YAML
+)
+
+// FormatOf picks the format from a file extension.
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	}
+	return 0, fmt.Errorf("unsupported profile file extension %q, want .json, .yaml or .yml", filepath.Ext(path))
+}
+
+// Load reads and validates the profile defined in path.
+func Load(path string) (mobility.Profile, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse decodes a profile definition. Unknown fields are rejected so that
+// typos do not silently fall back to defaults.
+func Parse(data []byte, format Format) (mobility.Profile, error) {
+	var f file
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("decoding JSON: %w", err)
+		}
+	case YAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("decoding YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown profile format %d", format)
+	}
+
+	return mobility.NewRuleProfile(f.spec())
+}
+
+// Entry is a profile file found by Discover.
+type Entry struct {
+	Path    string
+	Profile mobility.Profile // nil when Err is set
+	Err     error
+}
+
+// Discover loads every profile file in dir, sorted by path. Invalid files
+// are reported in their entry rather than aborting the scan.
+func Discover(dir string) ([]Entry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, de.Name())
+		if _, err := FormatOf(path); err != nil {
+			continue
+		}
+		p, err := Load(path)
+		entries = append(entries, Entry{Path: path, Profile: p, Err: err})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// file mirrors the on-disk schema.
+type file struct {
+	Name             string             `json:"name" yaml:"name"`
+	SpeedKmh         float64            `json:"speed_kmh" yaml:"speed_kmh"`
+	Access           access             `json:"access" yaml:"access"`
+	HighwaySpeedsKmh map[string]float64 `json:"highway_speeds_kmh" yaml:"highway_speeds_kmh"`
+	UseMaxSpeed      bool               `json:"use_maxspeed" yaml:"use_maxspeed"`
+	MaxSpeedKmh      float64            `json:"max_speed_kmh" yaml:"max_speed_kmh"`
+	WayRules         []wayRule          `json:"way_rules" yaml:"way_rules"`
+	NodeRules        []nodeRule         `json:"node_rules" yaml:"node_rules"`
+	Mode             string             `json:"mode" yaml:"mode"`
+	TurnCosts        *turnCosts         `json:"turn_costs" yaml:"turn_costs"`
+}
+
+type access struct {
+	Highways   []string `json:"highways" yaml:"highways"`
+	Keys       []string `json:"keys" yaml:"keys"`
+	Oneway     bool     `json:"oneway" yaml:"oneway"`
+	OnewayKeys []string `json:"oneway_keys" yaml:"oneway_keys"`
+}
+
+type wayRule struct {
+	Match       map[string]values `json:"match" yaml:"match"`
+	SpeedFactor float64           `json:"speed_factor" yaml:"speed_factor"`
+	Penalty     float64           `json:"penalty" yaml:"penalty"`
+	Forbid      bool              `json:"forbid" yaml:"forbid"`
+	Note        string            `json:"note" yaml:"note"`
+}
+
+type nodeRule struct {
+	Match    map[string]values `json:"match" yaml:"match"`
+	Junction bool              `json:"junction" yaml:"junction"`
+	Forbid   bool              `json:"forbid" yaml:"forbid"`
+	Delay    float64           `json:"delay" yaml:"delay"`
+	Note     string            `json:"note" yaml:"note"`
+}
+
+type turnCosts struct {
+	Straight float64 `json:"straight" yaml:"straight"`
+	Right    float64 `json:"right" yaml:"right"`
+	Left     float64 `json:"left" yaml:"left"`
+	UTurn    float64 `json:"u_turn" yaml:"u_turn"`
+}
+
+// values accepts either a single tag value or a list of them.
+type values []string
+
+func (v *values) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*v = values{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("tag values must be a string or a list of strings")
+	}
+	*v = many
+	return nil
+}
+
+func (v *values) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*v = values{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var many []string
+		if err := node.Decode(&many); err != nil {
+			return err
+		}
+		*v = many
+		return nil
+	}
+	return fmt.Errorf("line %d: tag values must be a string or a list of strings", node.Line)
+}
+
+func (f file) spec() mobility.RuleSpec {
+	spec := mobility.RuleSpec{
+		Name:             f.Name,
+		SpeedKmh:         f.SpeedKmh,
+		Highways:         make(map[string]bool, len(f.Access.Highways)),
+		AccessKeys:       f.Access.Keys,
+		Oneway:           f.Access.Oneway,
+		OnewayKeys:       f.Access.OnewayKeys,
+		HighwaySpeedsKmh: f.HighwaySpeedsKmh,
+		UseMaxSpeed:      f.UseMaxSpeed,
+		MaxSpeedKmh:      f.MaxSpeedKmh,
+		Mode:             mobility.Mode(f.Mode),
+	}
+	for _, h := range f.Access.Highways {
+		spec.Highways[h] = true
+	}
+	for _, r := range f.WayRules {
+		spec.WayRules = append(spec.WayRules, mobility.WayRule{
+			Match:       match(r.Match),
+			SpeedFactor: r.SpeedFactor,
+			Penalty:     r.Penalty,
+			Forbid:      r.Forbid,
+			Note:        r.Note,
+		})
+	}
+	for _, r := range f.NodeRules {
+		spec.NodeRules = append(spec.NodeRules, mobility.NodeRule{
+			Match:    match(r.Match),
+			Junction: r.Junction,
+			Forbid:   r.Forbid,
+			Delay:    r.Delay,
+			Note:     r.Note,
+		})
+	}
+	if t := f.TurnCosts; t != nil {
+		spec.Turns = &mobility.TurnCosts{
+			Straight: t.Straight,
+			Right:    t.Right,
+			Left:     t.Left,
+			UTurn:    t.UTurn,
+		}
+	}
+	return spec
+}
+
+func match(m map[string]values) mobility.TagMatch {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(mobility.TagMatch, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,209 @@
+package profiles_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+	"github.com/danielscoffee/pathcraft/internal/profiles"
+)
+
+func TestLoad_Examples(t *testing.T) {
+	for _, name := range []string{"truck.yaml", "stroller.json"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := profiles.Load(filepath.Join("..", "..", "examples", "profiles", name))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if want := strings.TrimSuffix(name, filepath.Ext(name)); p.Name() != want {
+				t.Errorf("Name() = %q, want %q", p.Name(), want)
+			}
+		})
+	}
+}
+
+func TestParse_Rules(t *testing.T) {
+	src := `
+name: test
+speed_kmh: 36
+access:
+  highways: [primary, residential, track]
+  keys: [motor_vehicle, access]
+  oneway: true
+highway_speeds_kmh: {primary: 72}
+way_rules:
+  - match: {surface: gravel}
+    speed_factor: 0.5
+  - match: {highway: residential}
+    penalty: 1
+  - match: {highway: track}
+    forbid: true
+node_rules:
+  - match: {barrier: gate, access: "yes"}
+    delay: 5
+  - match: {barrier: gate}
+    forbid: true
+turn_costs: {left: 10, right: 4, u_turn: 60}
+`
+	p, err := profiles.Parse([]byte(src), profiles.YAML)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if math.Abs(p.Speed()-10) > 1e-9 {
+		t.Errorf("Speed() = %v, want 10 m/s", p.Speed())
+	}
+
+	filter := p.(mobility.WayFilter)
+	access := []struct {
+		tags              map[string]string
+		forward, backward bool
+	}{
+		{map[string]string{"highway": "primary"}, true, true},
+		{map[string]string{"highway": "footway"}, false, false},
+		{map[string]string{"highway": "track"}, false, false},
+		{map[string]string{"highway": "primary", "oneway": "yes"}, true, false},
+		{map[string]string{"highway": "primary", "access": "no"}, false, false},
+		{map[string]string{"highway": "primary", "motor_vehicle": "yes", "access": "no"}, true, true},
+	}
+	for _, tt := range access {
+		if f, b := filter.WayAccess(tt.tags); f != tt.forward || b != tt.backward {
+			t.Errorf("WayAccess(%v) = (%v, %v), want (%v, %v)", tt.tags, f, b, tt.forward, tt.backward)
+		}
+	}
+
+	cost := func(tags map[string]string) mobility.Cost {
+		return mobility.SegmentCost(p, mobility.Segment{DistanceM: 100, Tags: tags})
+	}
+	if c := cost(map[string]string{"highway": "primary"}); math.Abs(c.Seconds-5) > 1e-9 {
+		t.Errorf("primary = %v s, want 5", c.Seconds)
+	}
+	if c := cost(map[string]string{"highway": "primary", "surface": "gravel"}); math.Abs(c.Seconds-10) > 1e-9 {
+		t.Errorf("gravel primary = %v s, want 10", c.Seconds)
+	}
+	if c := cost(map[string]string{"highway": "residential"}); math.Abs(c.Seconds-10) > 1e-9 || math.Abs(c.Penalty-10) > 1e-9 {
+		t.Errorf("residential = %+v, want 10 s with 10 s penalty", c)
+	}
+
+	node := func(tags map[string]string) mobility.Cost {
+		return mobility.NodeCost(p, mobility.NodeInfo{Tags: tags, Degree: 2})
+	}
+	if c := node(map[string]string{"barrier": "gate"}); !c.Forbidden {
+		t.Errorf("closed gate should be forbidden, got %+v", c)
+	}
+	if c := node(map[string]string{"barrier": "gate", "access": "yes"}); c.Forbidden || c.Seconds != 5 {
+		t.Errorf("open gate = %+v, want 5 s delay", c)
+	}
+
+	turns := p.(mobility.TurnCoster)
+	for _, tt := range []struct {
+		deflection, want float64
+	}{{0, 0}, {90, 4}, {-90, 10}, {180, 60}} {
+		if got := turns.TurnCost(tt.deflection); got != tt.want {
+			t.Errorf("TurnCost(%v) = %v, want %v", tt.deflection, got, tt.want)
+		}
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	src := `{"name": "j", "speed_kmh": 5, "access": {"highways": ["footway"]},
+		"way_rules": [{"match": {"surface": ["sand", "mud"]}, "speed_factor": 0.5}]}`
+	p, err := profiles.Parse([]byte(src), profiles.JSON)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, ok := p.(mobility.TurnCoster); ok && p.(mobility.TurnCoster).HasTurnCosts() {
+		t.Error("profile without turn_costs should not report turn costs")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format profiles.Format
+		src    string
+		want   []string
+	}{
+		{
+			name:   "unknown field",
+			format: profiles.YAML,
+			src:    "name: x\nspeed_kmh: 5\nspeeed: 3\naccess: {highways: [footway]}\n",
+			want:   []string{"speeed"},
+		},
+		{
+			name:   "unknown JSON field",
+			format: profiles.JSON,
+			src:    `{"name": "x", "speed": 5}`,
+			want:   []string{"speed"},
+		},
+		{
+			name:   "every problem reported",
+			format: profiles.YAML,
+			src: `
+name: ""
+speed_kmh: -1
+access: {highways: [primary]}
+highway_speeds_kmh: {footway: 5}
+way_rules:
+  - match: {surface: gravel}
+  - match: {surface: []}
+    speed_factor: -2
+node_rules:
+  - delay: 3
+turn_costs: {left: -1}
+`,
+			want: []string{
+				"name: must not be empty",
+				"speed_kmh: must be positive",
+				"highway_speeds_kmh.footway: class is not in access.highways",
+				"way_rules[0]: rule has no effect",
+				"way_rules[1].match.surface: must list at least one value",
+				"way_rules[1].speed_factor: must not be negative",
+				"node_rules[0].match: must name a tag or set junction",
+				"turn_costs.left: must not be negative",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := profiles.Parse([]byte(tt.src), tt.format)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.json", `{"name": "a", "speed_kmh": 5, "access": {"highways": ["footway"]}}`)
+	write("b.yml", "name: b\n")
+	write("notes.txt", "not a profile")
+
+	entries, err := profiles.Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Err != nil || entries[0].Profile.Name() != "a" {
+		t.Errorf("a.json: %+v", entries[0])
+	}
+	if entries[1].Err == nil {
+		t.Error("b.yml should fail validation")
+	}
+}
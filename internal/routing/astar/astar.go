@@ -18,7 +18,27 @@ type Path struct {
 	NodesCount int
 }
 
+// Weight returns the cost of traversing an edge. It must never be negative.
+type Weight func(e graph.Edge) float64
+
+// ByDistance weighs edges by their length in metres.
+func ByDistance(e graph.Edge) float64 {
+	return e.DistanceM
+}
+
+// ByCost weighs edges by the travel time their profile assigned.
+func ByCost(e graph.Edge) float64 {
+	return float64(e.Cost)
+}
+
+// AStar finds the shortest path by distance.
 func AStar(g *graph.Graph, source, target graph.NodeID, h geo.Heuristic) (Path, error) {
+	return AStarWeighted(g, source, target, h, ByDistance)
+}
+
+// AStarWeighted finds the path minimising weight. The heuristic must be
+// expressed in the same unit as the weight to remain admissible.
+func AStarWeighted(g *graph.Graph, source, target graph.NodeID, h geo.Heuristic, weight Weight) (Path, error) {
 	if !g.HasNode(source) || !g.HasNode(target) {
 		return Path{}, ErrNodeNotFound
 	}
@@ -59,7 +79,7 @@ func AStar(g *graph.Graph, source, target graph.NodeID, h geo.Heuristic) (Path,
 		delete(inOpenSet, currentID)
 
 		for _, edge := range g.Neighbors(currentID) {
-			tentativeG := gScore[currentID] + edge.DistanceM
+			tentativeG := gScore[currentID] + weight(edge)
 			existingG, visited := gScore[edge.To]
 			if !visited || tentativeG < existingG {
 				cameFrom[edge.To] = currentID
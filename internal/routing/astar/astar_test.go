@@ -178,3 +178,49 @@ func TestAStar_WeightedEdges(t *testing.T) {
 		}
 	}
 }
+
+func TestAStarTurns_AvoidsTurns(t *testing.T) {
+	g := buildTestGraph()
+
+	// Horizontal moves change the ID by 1 and vertical ones by 3, so a turn
+	// is any change in the step between consecutive nodes.
+	turn := func(prev, via, to graph.NodeID) float64 {
+		if via-prev != to-via {
+			return 10
+		}
+		return 0
+	}
+
+	path, err := astar.AStarTurns(g, 1, 9, zeroHeuristic, astar.ByDistance, turn)
+	if err != nil {
+		t.Fatalf("expected path, got error: %v", err)
+	}
+
+	if path.TotalCost != 14 {
+		t.Errorf("expected cost 14 (one turn), got %v", path.TotalCost)
+	}
+	if path.NodesCount != 5 {
+		t.Fatalf("expected 5 nodes, got %d", path.NodesCount)
+	}
+	mid := path.Nodes[2]
+	if mid != 3 && mid != 7 {
+		t.Errorf("expected path through a corner, got %v", path.Nodes)
+	}
+}
+
+func TestAStarTurns_NoTurnCostMatchesAStar(t *testing.T) {
+	g := buildTestGraph()
+	free := func(_, _, _ graph.NodeID) float64 { return 0 }
+
+	path, err := astar.AStarTurns(g, 1, 9, zeroHeuristic, astar.ByDistance, free)
+	if err != nil {
+		t.Fatalf("expected path, got error: %v", err)
+	}
+	if path.TotalCost != 4 {
+		t.Errorf("expected cost 4, got %v", path.TotalCost)
+	}
+
+	if _, err := astar.AStarTurns(g, 1, 99, zeroHeuristic, astar.ByDistance, free); err != astar.ErrNodeNotFound {
+		t.Errorf("expected ErrNodeNotFound, got %v", err)
+	}
+}
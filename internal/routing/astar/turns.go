@@ -0,0 +1,124 @@
+package astar
+
+import (
+	"container/heap"
+
+	"github.com/danielscoffee/pathcraft/internal/geo"
+	"github.com/danielscoffee/pathcraft/internal/graph"
+)
+
+// TurnWeight returns the extra cost of arriving at via from prev and
+// leaving towards to. It must never be negative.
+type TurnWeight func(prev, via, to graph.NodeID) float64
+
+// arrival is a search state for turn-aware routing: a node together with
+// the node it was reached from, because the cost of leaving depends on
+// how the node was entered.
+type arrival struct {
+	prev  graph.NodeID
+	node  graph.NodeID
+	start bool
+}
+
+// AStarTurns finds the path minimising weight plus turn costs. Each node
+// may be settled once per incoming edge, so the search space grows with
+// the number of edges rather than nodes.
+func AStarTurns(g *graph.Graph, source, target graph.NodeID, h geo.Heuristic, weight Weight, turn TurnWeight) (Path, error) {
+	if !g.HasNode(source) || !g.HasNode(target) {
+		return Path{}, ErrNodeNotFound
+	}
+
+	if source == target {
+		return Path{
+			Nodes:      []graph.NodeID{source},
+			TotalCost:  0,
+			NodesCount: 1,
+		}, nil
+	}
+
+	targetNode := g.Nodes[target]
+	start := arrival{node: source, start: true}
+
+	gScore := map[arrival]float64{start: 0}
+	cameFrom := make(map[arrival]arrival)
+	closed := make(map[arrival]bool)
+
+	openSet := &turnQueue{}
+	heap.Push(openSet, turnItem{state: start, priority: h(g.Nodes[source], targetNode)})
+
+	for openSet.Len() > 0 {
+		current := heap.Pop(openSet).(turnItem).state
+		if closed[current] {
+			continue
+		}
+		closed[current] = true
+
+		if current.node == target {
+			return reconstructTurnPath(cameFrom, current, gScore[current]), nil
+		}
+
+		for _, edge := range g.Neighbors(current.node) {
+			tentativeG := gScore[current] + weight(edge)
+			if !current.start {
+				tentativeG += turn(current.prev, current.node, edge.To)
+			}
+
+			next := arrival{prev: current.node, node: edge.To}
+			if closed[next] {
+				continue
+			}
+			if existingG, seen := gScore[next]; seen && tentativeG >= existingG {
+				continue
+			}
+			gScore[next] = tentativeG
+			cameFrom[next] = current
+			heap.Push(openSet, turnItem{
+				state:    next,
+				priority: tentativeG + h(g.Nodes[edge.To], targetNode),
+			})
+		}
+	}
+
+	return Path{}, ErrNoPath
+}
+
+func reconstructTurnPath(cameFrom map[arrival]arrival, last arrival, totalCost float64) Path {
+	path := []graph.NodeID{last.node}
+	for current := last; !current.start; {
+		current = cameFrom[current]
+		path = append(path, current.node)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return Path{
+		Nodes:      path,
+		TotalCost:  totalCost,
+		NodesCount: len(path),
+	}
+}
+
+// turnQueue may hold several entries for one state; stale ones are
+// skipped when popped.
+type turnItem struct {
+	state    arrival
+	priority float64
+}
+
+type turnQueue []turnItem
+
+func (q turnQueue) Len() int           { return len(q) }
+func (q turnQueue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q turnQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *turnQueue) Push(x any) { *q = append(*q, x.(turnItem)) }
+
+func (q *turnQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
@@ -0,0 +1,182 @@
+package raptor
+
+import (
+	"sort"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// Leg is a ride on one trip between two of its stops, or a transfer on
+// foot when Trip is empty.
+type Leg struct {
+	From      gtfs.StopID
+	To        gtfs.StopID
+	Trip      gtfs.TripID
+	Departure time.Time
+	Arrival   time.Time
+}
+
+// Journey is a way from the source of a search to a stop.
+type Journey struct {
+	// Departure is when the first trip leaves the source.
+	Departure time.Time
+	Arrival   time.Time
+	Transfers int
+	// Walking is the time spent on footpaths.
+	Walking time.Time
+	// Fare and Comfort are only counted by RunMulti; see Criterion.
+	Fare    float64
+	Comfort float64
+	Legs    []Leg
+}
+
+// Journeys returns the Pareto-optimal journeys to target: the earliest
+// arrival for each number of transfers that arrives earlier than with
+// fewer. They are ordered by transfers, so each arrives earlier than the
+// one before.
+func (res *Result) Journeys(target gtfs.StopID) []Journey {
+	r := res.router
+	s, ok := r.stopNumbers[target]
+	if !ok || r.best[s] == unreached || target == res.source {
+		return nil
+	}
+
+	var journeys []Journey
+	for k := 1; k <= res.rounds; k++ {
+		i := k*len(r.stops) + int(s)
+		if r.arrivals[i] == unreached || r.parents[i].from == -1 {
+			// Not reached, or no earlier than with fewer trips.
+			continue
+		}
+		j := r.journey(s, k)
+		// Fewer trips may have been needed than the round allowed.
+		for len(journeys) > 0 && journeys[len(journeys)-1].Transfers >= j.Transfers {
+			journeys = journeys[:len(journeys)-1]
+		}
+		journeys = append(journeys, j)
+	}
+	return journeys
+}
+
+// journey follows the parents of stop s from round k back to the source.
+func (r *Router) journey(s int32, k int) Journey {
+	return journeyOf(r.legs(s, k))
+}
+
+// journeyOf returns the journey riding and walking legs, in order.
+func journeyOf(legs []Leg) Journey {
+	j := Journey{Departure: legs[0].Departure, Arrival: legs[len(legs)-1].Arrival, Legs: legs}
+	for _, leg := range j.Legs {
+		if leg.Trip != "" {
+			j.Transfers++
+		} else {
+			j.Walking += leg.Arrival - leg.Departure
+		}
+	}
+	j.Transfers--
+	return j
+}
+
+func (r *Router) legs(s int32, k int) []Leg {
+	n := len(r.stops)
+
+	var legs []Leg
+	for k > 0 {
+		st := r.parents[k*n+int(s)]
+		if st.from == -1 {
+			// Reached as early in the round before.
+			k--
+			continue
+		}
+		legs = append(legs, Leg{
+			From:      r.stops[st.from],
+			To:        r.stops[s],
+			Trip:      st.trip,
+			Departure: st.depart,
+			Arrival:   st.arrive,
+		})
+		s = st.from
+		if !st.transfer {
+			k--
+		}
+	}
+
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+	return legs
+}
+
+// Profile runs rRAPTOR: it finds the Pareto-optimal journeys to q.Target
+// leaving q.Source from q.Departure to latest, better than any other in
+// departing later, arriving earlier or transferring less. Journeys are
+// ordered by departure, then by transfers. A journey may leave after
+// latest when waiting for it beats leaving within the window.
+//
+// One search runs per departure from the source in the window, latest
+// first, each keeping the labels of the last: a journey found for a later
+// departure bounds those leaving earlier, so each search only explores
+// what the earlier departure improves.
+func (r *Router) Profile(q Query, latest time.Time) []Journey {
+	r.reset()
+	rounds := q.MaxTransfers + 1
+	r.grow(rounds + 1)
+
+	src, target, ok := r.endpoints(q)
+	if !ok || target < 0 || src == target || int(src) >= len(r.index.Stops) {
+		return nil
+	}
+
+	n := len(r.stops)
+	before := make([]time.Time, rounds+1)
+	departures := r.index.DeparturesAt(int(src), q.Departure, latest)
+
+	var journeys []Journey
+	for i := len(departures) - 1; i >= 0; i-- {
+		for k := range before {
+			before[k] = r.arrivals[k*n+int(target)]
+		}
+		r.search(src, target, departures[i], rounds)
+
+		for k := 1; k <= rounds; k++ {
+			at := k*n + int(target)
+			if r.arrivals[at] < before[k] && r.parents[at].from != -1 {
+				journeys = append(journeys, r.journey(target, k))
+			}
+		}
+	}
+
+	return paretoJourneys(journeys)
+}
+
+// paretoJourneys drops the journeys another departs no earlier than,
+// arrives no later than and transfers no more than, and duplicates.
+func paretoJourneys(journeys []Journey) []Journey {
+	dominates := func(a, b Journey) bool {
+		return a.Departure >= b.Departure && a.Arrival <= b.Arrival && a.Transfers <= b.Transfers
+	}
+
+	var kept []Journey
+	for i, j := range journeys {
+		dominated := false
+		for k, o := range journeys {
+			// Of equal journeys, keep the first.
+			if k != i && dominates(o, j) && (!dominates(j, o) || k < i) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			kept = append(kept, j)
+		}
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].Departure != kept[j].Departure {
+			return kept[i].Departure < kept[j].Departure
+		}
+		return kept[i].Transfers < kept[j].Transfers
+	})
+	return kept
+}
@@ -0,0 +1,451 @@
+package raptor
+
+import (
+	"sort"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// Criterion is a criterion McRAPTOR compares journeys by, besides arrival
+// time and transfers, which it always compares.
+type Criterion uint8
+
+const (
+	// WalkingTime is the time spent on footpaths.
+	WalkingTime Criterion = 1 << iota
+	// Fare is the sum of the fares of the routes ridden.
+	Fare
+	// Comfort is a discomfort cost: seconds riding, weighted by route, and
+	// seconds waiting to transfer, counted double.
+	Comfort
+)
+
+// waitComfort weighs a second of waiting for the next trip against one
+// riding.
+const waitComfort = 2
+
+// Dominance tells when one journey makes another not worth showing. A
+// journey dominates another when it is no worse in arrival, transfers and
+// each of Criteria.
+//
+// The slacks widen the comparison for the journeys returned: one within
+// ArrivalSlack of arriving as early, and so on, counts as no worse. They
+// keep options differing by a minute or a few cents from crowding out
+// those that differ. The search itself compares exactly.
+type Dominance struct {
+	Criteria     Criterion
+	ArrivalSlack time.Time
+	WalkingSlack time.Time
+	FareSlack    float64
+	ComfortSlack float64
+}
+
+// DefaultBagSize bounds the labels kept at each stop per round.
+const DefaultBagSize = 8
+
+// MultiQuery is a McRAPTOR search.
+type MultiQuery struct {
+	Query
+	Dominance Dominance
+	// BagSize bounds the labels kept at each stop per round, dropping those
+	// arriving latest, and the journeys returned. Zero means
+	// DefaultBagSize.
+	BagSize int
+	// RouteFares is the price of boarding each route. Routes without one
+	// are free.
+	RouteFares map[gtfs.RouteID]float64
+	// RouteComfort weighs a second riding each route in the comfort cost.
+	// Routes without one weigh 1.
+	RouteComfort map[gtfs.RouteID]float64
+}
+
+// label is a journey to a stop, known by its last leg and the label it
+// continues.
+type label struct {
+	arrival time.Time
+	walking time.Time
+	fare    float64
+	comfort float64
+	trips   int32
+	// parent is the label the last leg leaves from, or -1 at the source.
+	parent int32
+	stop   int32
+	// trip is the trip of the last leg, or empty for a footpath.
+	trip   gtfs.TripID
+	depart time.Time
+}
+
+// routeLabel is a label riding a trip of the pattern being scanned.
+type routeLabel struct {
+	from  int32
+	trip  gtfs.Boarding
+	board int
+	fare  float64
+	// comfort is the label's comfort cost on boarding, with the wait.
+	comfort float64
+}
+
+// multiState is the search state of RunMulti. Bags of round k at stop s
+// are at bags[k*n+s] and hold indices into labels; best holds each stop's
+// labels of every round.
+type multiState struct {
+	labels  []label
+	bags    [][]int32
+	best    [][]int32
+	touched []int32
+	rounds  int
+
+	criteria Criterion
+	bagSize  int
+	target   int32
+	route    []routeLabel
+	walks    []int32
+}
+
+// RunMulti runs McRAPTOR: it finds the journeys to q.Target leaving at
+// q.Departure or later that no other dominates, as q.Dominance tells, in
+// order of arrival. Fares are added up per route boarded; transfer
+// discounts and zones are not modelled.
+func (r *Router) RunMulti(q MultiQuery) []Journey {
+	r.reset()
+	src, target, ok := r.endpoints(q.Query)
+	if !ok || target < 0 || src == target {
+		return nil
+	}
+
+	m := r.multiState(q.MaxTransfers + 2)
+	m.criteria = q.Dominance.Criteria
+	m.bagSize = q.BagSize
+	if m.bagSize <= 0 {
+		m.bagSize = DefaultBagSize
+	}
+	m.target = target
+
+	n := len(r.stops)
+	m.add(r, 0, src, label{arrival: q.Departure, parent: -1, stop: src})
+	r.mark(src)
+
+	numIndexed := int32(len(r.index.Stops))
+	for k := 1; k <= q.MaxTransfers+1; k++ {
+		for _, s := range r.markedStops {
+			r.marked[s] = false
+			if s >= numIndexed {
+				continue
+			}
+			for _, ps := range r.index.PatternsAtStop(int(s)) {
+				switch start := r.patternStart[ps.Pattern]; {
+				case start == -1:
+					r.active = append(r.active, ps.Pattern)
+					r.patternStart[ps.Pattern] = ps.Index
+				case ps.Index < start:
+					r.patternStart[ps.Pattern] = ps.Index
+				}
+			}
+		}
+		r.markedStops = r.markedStops[:0]
+
+		for _, p := range r.active {
+			r.scanPatternMulti(m, q, int(p), int(r.patternStart[p]), k)
+			r.patternStart[p] = -1
+		}
+		r.active = r.active[:0]
+
+		// Footpaths from the labels reached by trip this round. As in
+		// search, walks do not chain.
+		m.walks = m.walks[:0]
+		for _, s := range r.markedStops {
+			for _, l := range m.bags[k*n+int(s)] {
+				if m.labels[l].trip != "" {
+					m.walks = append(m.walks, l)
+				}
+			}
+		}
+		for _, l := range m.walks {
+			from := m.labels[l]
+			for _, fp := range r.transfers[r.transferStart[from.stop]:r.transferStart[from.stop+1]] {
+				walk := from
+				walk.arrival += fp.duration
+				walk.walking += fp.duration
+				walk.parent, walk.stop, walk.trip, walk.depart = l, fp.to, "", from.arrival
+				if m.add(r, k, fp.to, walk) {
+					r.mark(fp.to)
+				}
+			}
+		}
+
+		if len(r.markedStops) == 0 {
+			break
+		}
+	}
+
+	return m.journeys(r, q)
+}
+
+// multiState returns the McRAPTOR state, cleared of the last search, with
+// room for rounds rounds.
+func (r *Router) multiState(rounds int) *multiState {
+	m := r.multi
+	if m == nil {
+		m = &multiState{best: make([][]int32, len(r.stops))}
+		r.multi = m
+	}
+	for _, s := range m.touched {
+		m.best[s] = m.best[s][:0]
+		for k := range m.rounds {
+			m.bags[k*len(r.stops)+int(s)] = m.bags[k*len(r.stops)+int(s)][:0]
+		}
+	}
+	m.touched = m.touched[:0]
+	m.labels = m.labels[:0]
+	if rounds > m.rounds {
+		bags := make([][]int32, rounds*len(r.stops))
+		copy(bags, m.bags)
+		m.bags = bags
+		m.rounds = rounds
+	}
+	return m
+}
+
+// scanPatternMulti rides the trips of pattern p from stop index start in
+// round k, boarding from the labels of round k-1.
+func (r *Router) scanPatternMulti(m *multiState, q MultiQuery, p, start, k int) {
+	pattern := &r.index.Patterns[p]
+	n := len(r.stops)
+	fare := q.RouteFares[pattern.RouteID]
+	weight, ok := q.RouteComfort[pattern.RouteID]
+	if !ok {
+		weight = 1
+	}
+
+	m.route = m.route[:0]
+	for i := start; i < len(pattern.Stops); i++ {
+		s := pattern.Stops[i]
+
+		for _, rl := range m.route {
+			from := &m.labels[rl.from]
+			arr := rl.trip.Arrival(i)
+			ride := float64(arr - rl.trip.Departure(rl.board))
+			l := label{
+				arrival: arr,
+				walking: from.walking,
+				fare:    rl.fare,
+				comfort: rl.comfort + ride*weight,
+				trips:   int32(k),
+				parent:  rl.from,
+				stop:    s,
+				trip:    rl.trip.Trip,
+				depart:  rl.trip.Departure(rl.board),
+			}
+			if m.add(r, k, s, l) {
+				r.mark(s)
+			}
+		}
+
+		for _, li := range m.bags[(k-1)*n+int(s)] {
+			from := &m.labels[li]
+			trip, ok := r.index.EarliestBoarding(p, i, from.arrival)
+			if !ok {
+				continue
+			}
+			rl := routeLabel{from: li, trip: trip, board: i, fare: from.fare + fare, comfort: from.comfort}
+			if from.parent != -1 {
+				// Waiting at the source is not counted: the rider can leave
+				// later.
+				rl.comfort += float64(trip.Departure(i)-from.arrival) * waitComfort
+			}
+			m.boardRoute(rl, i, weight)
+		}
+	}
+}
+
+// boardRoute adds rl to the labels riding the pattern unless one on a
+// trip leaving stop index i no later is no worse, dropping those it
+// beats. Comfort is compared as it stands at stop i.
+func (m *multiState) boardRoute(rl routeLabel, i int, weight float64) {
+	comfortAt := func(rl *routeLabel) float64 {
+		return rl.comfort + float64(rl.trip.Departure(i)-rl.trip.Departure(rl.board))*weight
+	}
+	noWorse := func(a, b *routeLabel) bool {
+		return a.trip.Departure(i) <= b.trip.Departure(i) &&
+			(m.criteria&WalkingTime == 0 || m.labels[a.from].walking <= m.labels[b.from].walking) &&
+			(m.criteria&Fare == 0 || a.fare <= b.fare) &&
+			(m.criteria&Comfort == 0 || comfortAt(a) <= comfortAt(b))
+	}
+
+	for j := range m.route {
+		if noWorse(&m.route[j], &rl) {
+			return
+		}
+	}
+	kept := m.route[:0]
+	for j := range m.route {
+		if !noWorse(&rl, &m.route[j]) {
+			kept = append(kept, m.route[j])
+		}
+	}
+	m.route = append(kept, rl)
+	if len(m.route) > m.bagSize {
+		latest := 0
+		for j := range m.route {
+			if m.route[j].trip.Departure(i) > m.route[latest].trip.Departure(i) {
+				latest = j
+			}
+		}
+		m.route = append(m.route[:latest], m.route[latest+1:]...)
+	}
+}
+
+// dominates reports whether a is no worse than b in arrival, trips and
+// the criteria compared.
+func (m *multiState) dominates(a, b *label) bool {
+	return a.arrival <= b.arrival && a.trips <= b.trips &&
+		(m.criteria&WalkingTime == 0 || a.walking <= b.walking) &&
+		(m.criteria&Fare == 0 || a.fare <= b.fare) &&
+		(m.criteria&Comfort == 0 || a.comfort <= b.comfort)
+}
+
+// add keeps l in the bag of stop s in round k unless a label of s from
+// this round or any before, or one of the target, dominates it. Labels it
+// dominates leave the bags. It reports whether l was kept.
+func (m *multiState) add(r *Router, k int, s int32, l label) bool {
+	for _, o := range m.best[s] {
+		if m.dominates(&m.labels[o], &l) {
+			return false
+		}
+	}
+	if m.target != s {
+		for _, o := range m.best[m.target] {
+			if m.dominates(&m.labels[o], &l) {
+				return false
+			}
+		}
+	}
+
+	if len(m.best[s]) == 0 {
+		m.touched = append(m.touched, s)
+	}
+	i := int32(len(m.labels))
+	m.labels = append(m.labels, l)
+	bag := &m.bags[k*len(r.stops)+int(s)]
+	*bag = m.insert(*bag, i)
+	m.best[s] = m.insert(m.best[s], i)
+	return m.has(*bag, i)
+}
+
+// insert adds label i to bag, dropping the labels it dominates and, past
+// the bag size, the one arriving latest.
+func (m *multiState) insert(bag []int32, i int32) []int32 {
+	kept := bag[:0]
+	for _, o := range bag {
+		if !m.dominates(&m.labels[i], &m.labels[o]) {
+			kept = append(kept, o)
+		}
+	}
+	kept = append(kept, i)
+	if len(kept) > m.bagSize {
+		latest := 0
+		for j, o := range kept {
+			if m.labels[o].arrival > m.labels[kept[latest]].arrival {
+				latest = j
+			}
+		}
+		kept = append(kept[:latest], kept[latest+1:]...)
+	}
+	return kept
+}
+
+func (m *multiState) has(bag []int32, i int32) bool {
+	for _, o := range bag {
+		if o == i {
+			return true
+		}
+	}
+	return false
+}
+
+// journeys turns the labels of the target into journeys, leaving out
+// those dominated within the slacks of q.Dominance and those riding the
+// same trips as an earlier one.
+func (m *multiState) journeys(r *Router, q MultiQuery) []Journey {
+	labels := append([]int32(nil), m.best[m.target]...)
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := &m.labels[labels[i]], &m.labels[labels[j]]
+		if a.arrival != b.arrival {
+			return a.arrival < b.arrival
+		}
+		if a.trips != b.trips {
+			return a.trips < b.trips
+		}
+		return a.walking < b.walking
+	})
+
+	d := q.Dominance
+	near := func(a, b *label) bool {
+		return a.arrival <= b.arrival+d.ArrivalSlack && a.trips <= b.trips &&
+			(d.Criteria&WalkingTime == 0 || a.walking <= b.walking+d.WalkingSlack) &&
+			(d.Criteria&Fare == 0 || a.fare <= b.fare+d.FareSlack) &&
+			(d.Criteria&Comfort == 0 || a.comfort <= b.comfort+d.ComfortSlack)
+	}
+
+	var kept []int32
+	var journeys []Journey
+	seen := make(map[string]bool)
+	for _, l := range labels {
+		dominated := false
+		for _, o := range kept {
+			if near(&m.labels[o], &m.labels[l]) {
+				dominated = true
+				break
+			}
+		}
+		if dominated {
+			continue
+		}
+
+		j := m.journey(r, l)
+		var trips string
+		for _, leg := range j.Legs {
+			if leg.Trip != "" {
+				trips += string(leg.Trip) + "\x00"
+			}
+		}
+		if seen[trips] {
+			continue
+		}
+		seen[trips] = true
+		kept = append(kept, l)
+		journeys = append(journeys, j)
+		if len(journeys) == m.bagSize {
+			break
+		}
+	}
+	return journeys
+}
+
+// journey follows the parents of label l back to the source.
+func (m *multiState) journey(r *Router, l int32) Journey {
+	last := &m.labels[l]
+	j := Journey{
+		Arrival:   last.arrival,
+		Transfers: int(last.trips) - 1,
+		Walking:   last.walking,
+		Fare:      last.fare,
+		Comfort:   last.comfort,
+	}
+	for ; m.labels[l].parent != -1; l = m.labels[l].parent {
+		at := &m.labels[l]
+		j.Legs = append(j.Legs, Leg{
+			From:      r.stops[m.labels[at.parent].stop],
+			To:        r.stops[at.stop],
+			Trip:      at.trip,
+			Departure: at.depart,
+			Arrival:   at.arrival,
+		})
+	}
+	for i, k := 0, len(j.Legs)-1; i < k; i, k = i+1, k-1 {
+		j.Legs[i], j.Legs[k] = j.Legs[k], j.Legs[i]
+	}
+	j.Departure = j.Legs[0].Departure
+	return j
+}
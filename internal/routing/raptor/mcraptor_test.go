@@ -0,0 +1,137 @@
+package raptor
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+)
+
+// multiNetwork has three ways from A to D, leaving at 100:
+//   - the express EXP, arriving at 400 for a fare of 5;
+//   - the metro M to B, then a 300s walk, arriving at 500 for 3;
+//   - the local L to C and the local L2 on, arriving at 450 for 6.
+func multiNetwork() *Router {
+	stopTimes := []gtfs.StopTime{
+		{TripID: "EXP", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "EXP", StopID: "D", ArrivalTime: 400, DepartureTime: 400, StopSequence: 2},
+		{TripID: "M", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "M", StopID: "B", ArrivalTime: 200, DepartureTime: 200, StopSequence: 2},
+		{TripID: "L", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "L", StopID: "C", ArrivalTime: 250, DepartureTime: 250, StopSequence: 2},
+		{TripID: "L2", StopID: "C", ArrivalTime: 300, DepartureTime: 300, StopSequence: 1},
+		{TripID: "L2", StopID: "D", ArrivalTime: 450, DepartureTime: 450, StopSequence: 2},
+	}
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"EXP": "EXP", "M": "M", "L": "L", "L2": "L2"})
+	return NewRouter(idx, map[gtfs.StopID][]Transfer{"B": {{To: "D", Duration: 300}}})
+}
+
+func TestRouter_RunMulti(t *testing.T) {
+	fares := map[gtfs.RouteID]float64{"EXP": 5, "M": 3, "L": 3, "L2": 3}
+	tests := []struct {
+		name      string
+		dominance Dominance
+		bagSize   int
+		comfort   map[gtfs.RouteID]float64
+		want      []gtfs.TripID // first trip of each journey
+	}{
+		{name: "arrival and transfers", want: []gtfs.TripID{"EXP"}},
+		{name: "walking", dominance: Dominance{Criteria: WalkingTime}, want: []gtfs.TripID{"EXP"}},
+		{name: "fare", dominance: Dominance{Criteria: Fare}, want: []gtfs.TripID{"EXP", "M"}},
+		{name: "fare and walking", dominance: Dominance{Criteria: Fare | WalkingTime}, want: []gtfs.TripID{"EXP", "M"}},
+		{
+			name:      "fare within slack",
+			dominance: Dominance{Criteria: Fare, FareSlack: 2},
+			want:      []gtfs.TripID{"EXP"},
+		},
+		{
+			name:      "comfort",
+			dominance: Dominance{Criteria: Comfort},
+			comfort:   map[gtfs.RouteID]float64{"EXP": 3},
+			want:      []gtfs.TripID{"EXP", "L", "M"},
+		},
+		{name: "bag of one", dominance: Dominance{Criteria: Fare}, bagSize: 1, want: []gtfs.TripID{"EXP"}},
+	}
+
+	router := multiNetwork()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journeys := router.RunMulti(MultiQuery{
+				Query:        Query{Source: "A", Target: "D", Departure: 0, MaxTransfers: DefaultMaxTransfers},
+				Dominance:    tt.dominance,
+				BagSize:      tt.bagSize,
+				RouteFares:   fares,
+				RouteComfort: tt.comfort,
+			})
+			if len(journeys) != len(tt.want) {
+				t.Fatalf("got %d journeys, want %d: %+v", len(journeys), len(tt.want), journeys)
+			}
+			for i, j := range journeys {
+				if j.Legs[0].Trip != tt.want[i] {
+					t.Errorf("journey %d starts on %s, want %s", i, j.Legs[0].Trip, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_RunMulti_Criteria(t *testing.T) {
+	journeys := multiNetwork().RunMulti(MultiQuery{
+		Query:      Query{Source: "A", Target: "D", Departure: 0, MaxTransfers: DefaultMaxTransfers},
+		Dominance:  Dominance{Criteria: Fare},
+		RouteFares: map[gtfs.RouteID]float64{"EXP": 5, "M": 3, "L": 3, "L2": 3},
+	})
+	if len(journeys) != 2 {
+		t.Fatalf("got %d journeys, want 2", len(journeys))
+	}
+
+	walk := journeys[1]
+	if walk.Arrival != 500 || walk.Walking != 300 || walk.Fare != 3 || walk.Transfers != 0 || walk.Comfort != 100 {
+		t.Errorf("metro and walk = %+v", walk)
+	}
+	if len(walk.Legs) != 2 || walk.Legs[1].From != "B" || walk.Legs[1].Trip != "" || walk.Legs[1].Departure != 200 {
+		t.Errorf("legs = %+v", walk.Legs)
+	}
+}
+
+func TestRouter_RunMultiMatchesRun(t *testing.T) {
+	idx, transfers := metroFeed()
+	multi, single := NewRouter(idx, transfers), NewRouter(idx, transfers)
+
+	// Compared by arrival and transfers alone, McRAPTOR finds the journeys
+	// RAPTOR does, ordered by arrival rather than transfers.
+	for i, q := range metroQueries(20) {
+		q := Query{Source: q.source, Target: metroTarget(i), Departure: q.at, MaxTransfers: DefaultMaxTransfers}
+		want := single.Run(q).Journeys(q.Target)
+		for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+			want[i], want[j] = want[j], want[i]
+		}
+		got := multi.RunMulti(MultiQuery{Query: q})
+
+		if len(got) != len(want) {
+			t.Fatalf("from %s to %s: %d journeys, want %d", q.Source, q.Target, len(got), len(want))
+		}
+		for k := range want {
+			if got[k].Arrival != want[k].Arrival || got[k].Transfers != want[k].Transfers {
+				t.Errorf("from %s to %s: journey %d arrives at %s with %d transfers, want %s with %d",
+					q.Source, q.Target, k, got[k].Arrival, got[k].Transfers, want[k].Arrival, want[k].Transfers)
+			}
+		}
+	}
+}
+
+func BenchmarkRunMulti(b *testing.B) {
+	idx, transfers := metroFeed()
+	router := NewRouter(idx, transfers)
+	queries := metroQueries(64)
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		q := queries[i%len(queries)]
+		router.RunMulti(MultiQuery{
+			Query:     Query{Source: q.source, Target: metroTarget(i), Departure: q.at, MaxTransfers: DefaultMaxTransfers},
+			Dominance: Dominance{Criteria: WalkingTime | Comfort},
+		})
+		i++
+	}
+}
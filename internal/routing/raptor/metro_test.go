@@ -0,0 +1,242 @@
+package raptor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// metroGrid is the side of the grid of stops metroFeed lays its lines on.
+const metroGrid = 30
+
+// metroFeed builds a metro-sized network: ten lines along the rows of a
+// grid and ten along its columns, 500 stops in all, each line run in both
+// directions every five minutes from 05:00 to 24:00 and stopping every two
+// minutes. Lines cross at 100 stops, and neighbouring stops of the grid
+// are two minutes apart on foot.
+func metroFeed() (*gtfs.StopTimeIndex, map[gtfs.StopID][]Transfer) {
+	stop := func(row, col int) gtfs.StopID {
+		return gtfs.StopID(fmt.Sprintf("S%d_%d", row, col))
+	}
+	onLine := func(row, col int) bool { return row%3 == 0 || col%3 == 0 }
+
+	var lines [][]gtfs.StopID
+	for i := 0; i < metroGrid; i += 3 {
+		var across, down []gtfs.StopID
+		for j := range metroGrid {
+			across = append(across, stop(i, j))
+			down = append(down, stop(j, i))
+		}
+		lines = append(lines, across, down)
+	}
+
+	var stopTimes []gtfs.StopTime
+	tripRoutes := make(gtfs.TripToRoute)
+	for l, stops := range lines {
+		route := gtfs.RouteID(fmt.Sprintf("M%d", l))
+		for dir := range 2 {
+			for start := 5 * time.SecondsPerHour; start < 24*time.SecondsPerHour; start += 300 {
+				trip := gtfs.TripID(fmt.Sprintf("%s_%d_%d", route, dir, start))
+				tripRoutes[trip] = route
+				for i := range stops {
+					s := stops[i]
+					if dir == 1 {
+						s = stops[len(stops)-1-i]
+					}
+					at := time.Time(start + i*120)
+					stopTimes = append(stopTimes, gtfs.StopTime{
+						TripID: trip, StopID: s, ArrivalTime: at, DepartureTime: at + 20, StopSequence: i + 1,
+					})
+				}
+			}
+		}
+	}
+
+	transfers := make(map[gtfs.StopID][]Transfer)
+	for row := range metroGrid {
+		for col := range metroGrid {
+			if !onLine(row, col) {
+				continue
+			}
+			for _, d := range [][2]int{{0, 1}, {1, 0}, {0, -1}, {-1, 0}} {
+				r, c := row+d[0], col+d[1]
+				if r >= 0 && r < metroGrid && c >= 0 && c < metroGrid && onLine(r, c) {
+					transfers[stop(row, col)] = append(transfers[stop(row, col)], Transfer{To: stop(r, c), Duration: 120})
+				}
+			}
+		}
+	}
+
+	return gtfs.BuildIndex(stopTimes, tripRoutes), transfers
+}
+
+// metroQueries spreads searches over the network and the day.
+func metroQueries(n int) []struct {
+	source gtfs.StopID
+	at     time.Time
+} {
+	queries := make([]struct {
+		source gtfs.StopID
+		at     time.Time
+	}, n)
+	for i := range queries {
+		row, col := (i*7)%metroGrid, (i*3)%(metroGrid/3)*3
+		queries[i].source = gtfs.StopID(fmt.Sprintf("S%d_%d", row, col))
+		queries[i].at = time.Time(6*time.SecondsPerHour + (i*977)%(14*time.SecondsPerHour))
+	}
+	return queries
+}
+
+// metroTarget picks a destination for the i-th query, across the grid.
+func metroTarget(i int) gtfs.StopID {
+	return gtfs.StopID(fmt.Sprintf("S%d_%d", metroGrid-1-(i*5)%metroGrid, (i%10)*3))
+}
+
+func TestRouter_ReusedAcrossSearches(t *testing.T) {
+	idx, transfers := metroFeed()
+	reused := NewRouter(idx, transfers)
+
+	for _, q := range metroQueries(20) {
+		got := reused.Search(q.source, q.at).EarliestArrivals()
+		want := NewRouter(idx, transfers).Search(q.source, q.at).EarliestArrivals()
+
+		if len(got) != len(want) {
+			t.Fatalf("from %s at %s: reached %d stops, want %d", q.source, q.at, len(got), len(want))
+		}
+		for stop, arr := range want {
+			if got[stop] != arr {
+				t.Fatalf("from %s at %s: arrival at %s = %s, want %s", q.source, q.at, stop, got[stop], arr)
+			}
+		}
+	}
+}
+
+func TestRouter_TargetPruning(t *testing.T) {
+	idx, transfers := metroFeed()
+	full, pruned := NewRouter(idx, transfers), NewRouter(idx, transfers)
+
+	for i, q := range metroQueries(20) {
+		target := metroTarget(i)
+		want, wantOK := full.Search(q.source, q.at).Arrival(target)
+		res := pruned.Run(Query{Source: q.source, Target: target, Departure: q.at, MaxTransfers: DefaultMaxTransfers})
+		if got, ok := res.Arrival(target); got != want || ok != wantOK {
+			t.Errorf("from %s at %s: arrival at %s = %s, want %s", q.source, q.at, target, got, want)
+		}
+		journeys := res.Journeys(target)
+		if len(journeys) == 0 || journeys[len(journeys)-1].Arrival != want {
+			t.Errorf("from %s at %s: journeys to %s = %+v, want the last arriving at %s", q.source, q.at, target, journeys, want)
+		}
+	}
+}
+
+func TestRouter_PathAcrossRounds(t *testing.T) {
+	idx, transfers := metroFeed()
+	res := NewRouter(idx, transfers).Search("S0_0", 8*time.SecondsPerHour)
+
+	// Every path leaves the source and ends at the stop it was asked for,
+	// each step starting where the one before ended.
+	for stop := range res.EarliestArrivals() {
+		path := res.ReconstructPath(stop)
+		if stop == "S0_0" {
+			continue
+		}
+		if len(path) == 0 || path[0].FromStop != "S0_0" || path[len(path)-1].ToStop != stop {
+			t.Fatalf("path to %s = %+v", stop, path)
+		}
+		for i := 1; i < len(path); i++ {
+			if path[i].FromStop != path[i-1].ToStop {
+				t.Fatalf("path to %s breaks at step %d: %+v", stop, i, path)
+			}
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	idx, transfers := metroFeed()
+	router := NewRouter(idx, transfers)
+	queries := metroQueries(64)
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		q := queries[i%len(queries)]
+		router.Search(q.source, q.at)
+		i++
+	}
+}
+
+func BenchmarkSearch_Target(b *testing.B) {
+	idx, transfers := metroFeed()
+	router := NewRouter(idx, transfers)
+	queries := metroQueries(64)
+	targets := make([]gtfs.StopID, len(queries))
+	for i := range targets {
+		targets[i] = metroTarget(i)
+	}
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		q := queries[i%len(queries)]
+		router.Run(Query{Source: q.source, Target: targets[i%len(queries)], Departure: q.at, MaxTransfers: DefaultMaxTransfers})
+		i++
+	}
+}
+
+func TestRouter_Profile(t *testing.T) {
+	idx, transfers := metroFeed()
+	profile, single := NewRouter(idx, transfers), NewRouter(idx, transfers)
+
+	type key struct {
+		dep, arr  time.Time
+		transfers int
+	}
+	for i, q := range metroQueries(8) {
+		q := Query{Source: q.source, Target: metroTarget(i), Departure: q.at, MaxTransfers: DefaultMaxTransfers}
+		latest := q.Departure + time.SecondsPerHour
+
+		got := profile.Profile(q, latest)
+
+		// The same journeys come from searching each departure alone.
+		s, _ := idx.StopNumber(q.Source)
+		var all []Journey
+		for _, dep := range idx.DeparturesAt(s, q.Departure, latest) {
+			each := q
+			each.Departure = dep
+			all = append(all, single.Run(each).Journeys(q.Target)...)
+		}
+		want := paretoJourneys(all)
+
+		if len(got) != len(want) {
+			t.Fatalf("from %s to %s: %d journeys, want %d", q.Source, q.Target, len(got), len(want))
+		}
+		for k := range want {
+			g, w := key{got[k].Departure, got[k].Arrival, got[k].Transfers}, key{want[k].Departure, want[k].Arrival, want[k].Transfers}
+			if g != w {
+				t.Errorf("from %s to %s: journey %d = %+v, want %+v", q.Source, q.Target, k, g, w)
+			}
+			if got[k].Departure < q.Departure {
+				t.Errorf("journey %d leaves at %s, before the window", k, got[k].Departure)
+			}
+		}
+		if len(got) < 2 {
+			t.Errorf("from %s to %s: %d journeys in an hour of trips every five minutes", q.Source, q.Target, len(got))
+		}
+	}
+}
+
+func BenchmarkProfile(b *testing.B) {
+	idx, transfers := metroFeed()
+	router := NewRouter(idx, transfers)
+	queries := metroQueries(64)
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		q := queries[i%len(queries)]
+		router.Profile(Query{Source: q.source, Target: metroTarget(i), Departure: q.at, MaxTransfers: DefaultMaxTransfers}, q.at+time.SecondsPerHour)
+		i++
+	}
+}
@@ -1,6 +1,8 @@
 package raptor
 
 import (
+	"math"
+
 	"github.com/danielscoffee/pathcraft/internal/gtfs"
 	"github.com/danielscoffee/pathcraft/internal/time"
 )
@@ -12,187 +14,457 @@ type JourneyStep struct {
 	IsTransfer bool
 }
 
-type Result struct {
-	ArrivalTimes    []map[gtfs.StopID]time.Time
-	EarliestArrival map[gtfs.StopID]time.Time
-	Parents         []map[gtfs.StopID]JourneyStep
-}
-
 type Transfer struct {
 	To       gtfs.StopID
 	Duration time.Time
 }
 
+// TransfersFromGTFS converts transfers.txt entries into footpaths, leaving
+// out those the feed forbids.
+func TransfersFromGTFS(transfers []gtfs.Transfer) map[gtfs.StopID][]Transfer {
+	footpaths := make(map[gtfs.StopID][]Transfer)
+	for _, t := range transfers {
+		if t.TransferType == gtfs.TransferNotPossible {
+			continue
+		}
+		footpaths[t.FromStopID] = append(footpaths[t.FromStopID], Transfer{
+			To:       t.ToStopID,
+			Duration: time.Time(t.MinTransferTime),
+		})
+	}
+	return footpaths
+}
+
+// footpath is a Transfer between stop numbers.
+type footpath struct {
+	to       int32
+	duration time.Time
+}
+
+// step is how a stop was reached in a round: on trip from stop from, or
+// by transfer, leaving at depart and arriving at arrive. from is -1 when
+// the stop kept its arrival of the round before.
+type step struct {
+	from           int32
+	trip           gtfs.TripID
+	transfer       bool
+	depart, arrive time.Time
+}
+
+const unreached = time.Time(math.MaxInt)
+
+// Router searches one StopTimeIndex. It numbers stops like the index,
+// followed by stops reached only by transfer, and keeps its search state
+// between queries to spare allocating it each time. A Router is therefore
+// not safe for concurrent use.
 type Router struct {
-	index     *gtfs.StopTimeIndex
-	transfers map[gtfs.StopID][]Transfer
+	index *gtfs.StopTimeIndex
+	// stops extends index.Stops with stops reached only by transfer.
+	stops       []gtfs.StopID
+	stopNumbers map[gtfs.StopID]int32
+	// The footpaths from stop s are transfers[transferStart[s]:transferStart[s+1]].
+	transferStart []int32
+	transfers     []footpath
+	// The footpaths into stop s, for searches running backwards, are
+	// arrivingTransfers[arrivingStart[s]:arrivingStart[s+1]], each to the
+	// stop they come from.
+	arrivingStart     []int32
+	arrivingTransfers []footpath
+
+	// Search state. arrivals and parents hold round k of stop s at
+	// k*len(stops)+s, for rounds rounds; reached lists the stops to reset
+	// before the next search.
+	rounds       int
+	arrivals     []time.Time
+	parents      []step
+	best         []time.Time
+	reached      []int32
+	marked       []bool
+	markedStops  []int32
+	patternStart []int32
+	active       []int32
+	tripArrivals []time.Time
+	// multi is the search state of RunMulti, made on its first search.
+	multi *multiState
 }
 
 func NewRouter(index *gtfs.StopTimeIndex, transfers map[gtfs.StopID][]Transfer) *Router {
-	return &Router{
-		index:     index,
-		transfers: transfers,
+	r := &Router{
+		index:       index,
+		stops:       append([]gtfs.StopID(nil), index.Stops...),
+		stopNumbers: make(map[gtfs.StopID]int32, len(index.Stops)),
+	}
+	for s, id := range r.stops {
+		r.stopNumbers[id] = int32(s)
+	}
+	number := func(id gtfs.StopID) int32 {
+		s, ok := r.stopNumbers[id]
+		if !ok {
+			s = int32(len(r.stops))
+			r.stopNumbers[id] = s
+			r.stops = append(r.stops, id)
+		}
+		return s
+	}
+	for from, paths := range transfers {
+		number(from)
+		for _, tr := range paths {
+			number(tr.To)
+		}
 	}
-}
 
-const MaxRounds = 10
+	n := len(r.stops)
+	r.transferStart = make([]int32, n+1)
+	for from, paths := range transfers {
+		r.transferStart[r.stopNumbers[from]+1] = int32(len(paths))
+	}
+	for s := range n {
+		r.transferStart[s+1] += r.transferStart[s]
+	}
+	r.transfers = make([]footpath, r.transferStart[n])
+	for from, paths := range transfers {
+		start := r.transferStart[r.stopNumbers[from]]
+		for i, tr := range paths {
+			r.transfers[start+int32(i)] = footpath{to: r.stopNumbers[tr.To], duration: tr.Duration}
+		}
+	}
 
-func (r *Router) Search(source gtfs.StopID, departureTime time.Time) *Result {
-	earliestArrival := make(map[gtfs.StopID]time.Time)
-	arrivalTimes := make([]map[gtfs.StopID]time.Time, MaxRounds+1)
-	parents := make([]map[gtfs.StopID]JourneyStep, MaxRounds+1)
+	r.arrivingStart = make([]int32, n+1)
+	for _, fp := range r.transfers {
+		r.arrivingStart[fp.to+1]++
+	}
+	for s := range n {
+		r.arrivingStart[s+1] += r.arrivingStart[s]
+	}
+	r.arrivingTransfers = make([]footpath, len(r.transfers))
+	next := append([]int32(nil), r.arrivingStart[:n]...)
+	for from := range n {
+		for _, fp := range r.transfers[r.transferStart[from]:r.transferStart[from+1]] {
+			r.arrivingTransfers[next[fp.to]] = footpath{to: int32(from), duration: fp.duration}
+			next[fp.to]++
+		}
+	}
 
-	for k := 0; k <= MaxRounds; k++ {
-		arrivalTimes[k] = make(map[gtfs.StopID]time.Time)
-		parents[k] = make(map[gtfs.StopID]JourneyStep)
+	r.best = make([]time.Time, n)
+	r.marked = make([]bool, n)
+	for i := range r.best {
+		r.best[i] = unreached
+	}
+	r.patternStart = make([]int32, len(index.Patterns))
+	for i := range r.patternStart {
+		r.patternStart[i] = -1
 	}
+	return r
+}
 
-	arrivalTimes[0][source] = departureTime
-	earliestArrival[source] = departureTime
+// Index returns the index the router searches.
+func (r *Router) Index() *gtfs.StopTimeIndex {
+	return r.index
+}
 
-	markedStops := make(map[gtfs.StopID]bool)
-	markedStops[source] = true
+// DefaultMaxTransfers bounds journeys to ten trips.
+const DefaultMaxTransfers = 9
+
+// Query is a RAPTOR search from Source leaving at Departure or later.
+type Query struct {
+	Source gtfs.StopID
+	// Target, when set, is the stop the search is for. Arrivals elsewhere
+	// no earlier than the best known at Target are pruned, so only
+	// Target's arrivals are complete.
+	Target    gtfs.StopID
+	Departure time.Time
+	// MaxTransfers bounds the changes between trips: journeys ride at most
+	// MaxTransfers+1 trips.
+	MaxTransfers int
+}
 
-	for k := 1; k <= MaxRounds; k++ {
-		for stop, t := range arrivalTimes[k-1] {
-			arrivalTimes[k][stop] = t
-		}
+// Search finds the earliest arrival at every stop from source, leaving at
+// departureTime or later, with up to DefaultMaxTransfers transfers. The
+// result is only valid until the router's next search.
+func (r *Router) Search(source gtfs.StopID, departureTime time.Time) *Result {
+	return r.Run(Query{Source: source, Departure: departureTime, MaxTransfers: DefaultMaxTransfers})
+}
 
-		activeRoutes := make(map[gtfs.RouteID]int)
-		for stopID := range markedStops {
-			routes := r.index.RoutesAtStop(stopID)
-			for _, routeID := range routes {
-				seq := r.index.GetStopSequence(stopID, routeID)
-				if currentSeq, ok := activeRoutes[routeID]; !ok || seq < currentSeq {
-					activeRoutes[routeID] = seq
-				}
-			}
-		}
+// HasStop reports whether a stop is served by the index or reached by a
+// transfer.
+func (r *Router) HasStop(id gtfs.StopID) bool {
+	_, ok := r.stopNumbers[id]
+	return ok
+}
 
-		markedStops = make(map[gtfs.StopID]bool)
+// Run searches as q asks. Round k boards the k-th trip of a journey. The
+// result is only valid until the router's next search.
+func (r *Router) Run(q Query) *Result {
+	r.reset()
+	r.grow(q.MaxTransfers + 2)
+	res := &Result{router: r, source: q.Source, departure: q.Departure}
 
-		for routeID, startSeq := range activeRoutes {
-			tripIndex := -1
-			var boardingStop gtfs.StopID
-			pattern := r.index.RoutePatterns[routeID]
-			routeTrips := r.index.RouteTrips[routeID]
+	src, target, ok := r.endpoints(q)
+	if !ok {
+		return res
+	}
+	res.rounds = r.search(src, target, q.Departure, q.MaxTransfers+1)
+	return res
+}
 
-			startStopIndex := -1
-			for i, s := range pattern.Stops {
-				if s.Sequence == startSeq {
-					startStopIndex = i
-					break
-				}
+// endpoints returns the numbers of q's source and target, or -1 for no
+// target. It fails when either is unknown.
+func (r *Router) endpoints(q Query) (src, target int32, ok bool) {
+	if src, ok = r.stopNumbers[q.Source]; !ok {
+		return 0, 0, false
+	}
+	target = -1
+	if q.Target != "" {
+		if target, ok = r.stopNumbers[q.Target]; !ok {
+			return 0, 0, false
+		}
+	}
+	return src, target, true
+}
+
+// search runs up to maxRounds rounds from stop src, leaving at departure,
+// and returns the last round run. Labels already in the search state are
+// kept, and only beaten by earlier arrivals: a journey leaving later is
+// just as good for a rider leaving earlier, who can wait.
+func (r *Router) search(src, target int32, departure time.Time, maxRounds int) int {
+	n := len(r.stops)
+	for _, s := range r.markedStops {
+		r.marked[s] = false
+	}
+	r.markedStops = r.markedStops[:0]
+
+	if r.best[src] == unreached {
+		r.reached = append(r.reached, src)
+	}
+	r.arrivals[src] = departure
+	r.best[src] = min(r.best[src], departure)
+	r.parents[src] = step{from: -1}
+	r.mark(src)
+
+	numIndexed := int32(len(r.index.Stops))
+	last := 0
+	for k := 1; k <= maxRounds; k++ {
+		prev, cur := r.arrivals[(k-1)*n:k*n], r.arrivals[k*n:(k+1)*n]
+		parents := r.parents[k*n : (k+1)*n]
+		for _, s := range r.reached {
+			if prev[s] < cur[s] {
+				cur[s] = prev[s]
+				parents[s] = step{from: -1}
 			}
+		}
+		last = k
 
-			if startStopIndex == -1 {
+		// The earliest marked stop of each pattern is where its scan starts.
+		for _, s := range r.markedStops {
+			r.marked[s] = false
+			if s >= numIndexed {
 				continue
 			}
-
-			for i := startStopIndex; i < len(pattern.Stops); i++ {
-				stop := pattern.Stops[i]
-
-				if tripIndex != -1 {
-					trip := routeTrips[tripIndex][i]
-					arrTime := trip.ArrivalTime
-
-					if existing, ok := earliestArrival[stop.StopID]; !ok || arrTime < existing {
-						arrivalTimes[k][stop.StopID] = arrTime
-						earliestArrival[stop.StopID] = arrTime
-						markedStops[stop.StopID] = true
-						parents[k][stop.StopID] = JourneyStep{
-							FromStop: boardingStop,
-							ToStop:   stop.StopID,
-							TripID:   trip.TripID,
-						}
-					}
-				}
-
-				// Can we catch a better trip at this stop?
-				if prevArrival, ok := arrivalTimes[k-1][stop.StopID]; ok {
-					etIndex := r.index.EarliestTripIndex(routeID, i, prevArrival)
-					if etIndex != -1 {
-						if tripIndex == -1 || etIndex < tripIndex {
-							tripIndex = etIndex
-							boardingStop = stop.StopID
-						}
-					}
+			for _, ps := range r.index.PatternsAtStop(int(s)) {
+				switch start := r.patternStart[ps.Pattern]; {
+				case start == -1:
+					r.active = append(r.active, ps.Pattern)
+					r.patternStart[ps.Pattern] = ps.Index
+				case ps.Index < start:
+					r.patternStart[ps.Pattern] = ps.Index
 				}
 			}
 		}
+		r.markedStops = r.markedStops[:0]
 
-		// Handle transfers
-		// We need to iterate over stops updated in THIS round (Step 2)
-		updatedInStep2 := make([]gtfs.StopID, 0, len(markedStops))
-		for stopID := range markedStops {
-			updatedInStep2 = append(updatedInStep2, stopID)
+		for _, p := range r.active {
+			r.scanPattern(int(p), int(r.patternStart[p]), target, prev, cur, parents)
+			r.patternStart[p] = -1
 		}
-
-		for _, stopID := range updatedInStep2 {
-			for _, tr := range r.transfers[stopID] {
-				arrWithTransfer := arrivalTimes[k][stopID] + tr.Duration
-				if existing, ok := earliestArrival[tr.To]; !ok || arrWithTransfer < existing {
-					arrivalTimes[k][tr.To] = arrWithTransfer
-					earliestArrival[tr.To] = arrWithTransfer
-					markedStops[tr.To] = true
-					parents[k][tr.To] = JourneyStep{
-						FromStop:   stopID,
-						ToStop:     tr.To,
-						IsTransfer: true,
-					}
+		r.active = r.active[:0]
+
+		// Footpaths from the stops reached by trip this round, leaving when
+		// the trip arrives: walks do not chain, even where one improves a
+		// stop another starts from.
+		r.tripArrivals = r.tripArrivals[:0]
+		for _, s := range r.markedStops {
+			r.tripArrivals = append(r.tripArrivals, cur[s])
+		}
+		for i, s := range r.markedStops[:len(r.tripArrivals)] {
+			depart := r.tripArrivals[i]
+			for _, fp := range r.transfers[r.transferStart[s]:r.transferStart[s+1]] {
+				arr := depart + fp.duration
+				if arr < bound(cur, fp.to, target) {
+					r.improve(fp.to, arr, cur)
+					parents[fp.to] = step{from: s, transfer: true, depart: depart, arrive: arr}
 				}
 			}
 		}
 
-		if len(markedStops) == 0 {
+		if len(r.markedStops) == 0 {
 			break
 		}
 	}
 
-	return &Result{
-		ArrivalTimes:    arrivalTimes,
-		EarliestArrival: earliestArrival,
-		Parents:         parents,
+	return last
+}
+
+// bound returns the time an arrival at stop s in the round of cur must
+// beat to be kept: the arrival there with as many trips, or at the target
+// when that is earlier. The round's own labels rather than the best of
+// any round bound it, so that journeys with fewer trips are not pruned by
+// those with more from a later departure, see Profile.
+func bound(cur []time.Time, s, target int32) time.Time {
+	if target >= 0 {
+		return min(cur[s], cur[target])
 	}
+	return cur[s]
 }
 
-func (res *Result) ReconstructPath(target gtfs.StopID) []JourneyStep {
-	path := []JourneyStep{}
-	currentStop := target
+// grow makes room for rounds rounds of search state.
+func (r *Router) grow(rounds int) {
+	if rounds <= r.rounds {
+		return
+	}
+	n := len(r.stops)
+	arrivals := make([]time.Time, rounds*n)
+	for i := range arrivals {
+		arrivals[i] = unreached
+	}
+	r.arrivals = arrivals
+	r.parents = make([]step, rounds*n)
+	r.rounds = rounds
+}
 
-	bestRound := -1
-	var bestTime time.Time
+// scanPattern rides the trips of pattern p from stop index start. prev
+// and cur are the arrivals of the round before and of this round.
+func (r *Router) scanPattern(p, start int, target int32, prev, cur []time.Time, parents []step) {
+	pattern := &r.index.Patterns[p]
+
+	var trip gtfs.Boarding
+	boarded := false
+	var boardingStop int32
+	var boardingIndex int
+	for i := start; i < len(pattern.Stops); i++ {
+		s := pattern.Stops[i]
+
+		if boarded {
+			if arr := trip.Arrival(i); arr < bound(cur, s, target) {
+				r.improve(s, arr, cur)
+				parents[s] = step{from: boardingStop, trip: trip.Trip, depart: trip.Departure(boardingIndex), arrive: arr}
+			}
+		}
 
-	for k, arrivals := range res.ArrivalTimes {
-		if t, ok := arrivals[target]; ok {
-			if bestRound == -1 || t < bestTime {
-				bestTime = t
-				bestRound = k
+		// Can we catch a better trip at this stop? Not if the one we are
+		// on leaves before we could get here.
+		if prevArrival := prev[s]; prevArrival != unreached && (!boarded || prevArrival <= trip.Departure(i)) {
+			if next, ok := r.index.EarliestBoarding(p, i, prevArrival); ok {
+				if !boarded || next.Departure(i) < trip.Departure(i) {
+					trip, boarded = next, true
+					boardingStop, boardingIndex = s, i
+				}
 			}
 		}
 	}
+}
 
-	if bestRound == -1 {
-		return nil
+// improve records an earlier arrival at stop s in the current round and
+// marks s.
+func (r *Router) improve(s int32, arr time.Time, cur []time.Time) {
+	if r.best[s] == unreached {
+		r.reached = append(r.reached, s)
 	}
+	cur[s] = arr
+	r.best[s] = min(r.best[s], arr)
+	r.mark(s)
+}
 
-	for k := bestRound; k > 0; {
-		step, ok := res.Parents[k][currentStop]
-		if !ok {
-			break
-		}
-		path = append(path, step)
-		currentStop = step.FromStop
+func (r *Router) mark(s int32) {
+	if !r.marked[s] {
+		r.marked[s] = true
+		r.markedStops = append(r.markedStops, s)
+	}
+}
 
-		if !step.IsTransfer {
-			k--
+// reset undoes the previous search, touching only the stops it reached.
+func (r *Router) reset() {
+	n := len(r.stops)
+	for _, s := range r.reached {
+		r.best[s] = unreached
+		for k := range r.rounds {
+			r.arrivals[k*n+int(s)] = unreached
 		}
 	}
+	for _, s := range r.markedStops {
+		r.marked[s] = false
+	}
+	r.reached = r.reached[:0]
+	r.markedStops = r.markedStops[:0]
+}
+
+// Result is the outcome of a Search. It reads the router's search state,
+// so it is only valid until the router searches again.
+type Result struct {
+	router    *Router
+	source    gtfs.StopID
+	departure time.Time
+	// rounds is the last round searched.
+	rounds int
+}
+
+// Rounds returns the number of rounds searched, each boarding one more
+// trip.
+func (res *Result) Rounds() int {
+	return res.rounds
+}
+
+// Arrival returns the earliest arrival at a stop, if it was reached.
+func (res *Result) Arrival(stop gtfs.StopID) (time.Time, bool) {
+	s, ok := res.router.stopNumbers[stop]
+	if !ok || res.router.best[s] == unreached {
+		return 0, false
+	}
+	return res.router.best[s], true
+}
+
+// ArrivalInRound returns the earliest arrival at a stop with at most k
+// trips, if it was reached with that many.
+func (res *Result) ArrivalInRound(stop gtfs.StopID, k int) (time.Time, bool) {
+	s, ok := res.router.stopNumbers[stop]
+	if !ok || k < 0 || k > res.rounds {
+		return 0, false
+	}
+	arr := res.router.arrivals[k*len(res.router.stops)+int(s)]
+	return arr, arr != unreached
+}
+
+// EarliestArrivals returns the earliest arrival at every stop reached.
+func (res *Result) EarliestArrivals() map[gtfs.StopID]time.Time {
+	r := res.router
+	arrivals := make(map[gtfs.StopID]time.Time, len(r.reached))
+	for _, s := range r.reached {
+		arrivals[r.stops[s]] = r.best[s]
+	}
+	return arrivals
+}
+
+// ReconstructPath returns the steps of the journey reaching target
+// earliest with the fewest trips, or nil if it was not reached.
+func (res *Result) ReconstructPath(target gtfs.StopID) []JourneyStep {
+	r := res.router
+	s, ok := r.stopNumbers[target]
+	if !ok || r.best[s] == unreached {
+		return nil
+	}
 
-	// Reverse path
-	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
-		path[i], path[j] = path[j], path[i]
+	k := 0
+	for r.arrivals[k*len(r.stops)+int(s)] != r.best[s] {
+		k++
 	}
 
+	path := []JourneyStep{}
+	for _, leg := range r.legs(s, k) {
+		path = append(path, JourneyStep{
+			FromStop:   leg.From,
+			ToStop:     leg.To,
+			TripID:     leg.Trip,
+			IsTransfer: leg.Trip == "",
+		})
+	}
 	return path
 }
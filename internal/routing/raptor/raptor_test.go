@@ -46,7 +46,7 @@ func TestRAPTOR(t *testing.T) {
 	// Option 2: A -> B -> C -> D (1 trip + transfer)
 	// T1 to C (arr 300), transfer to D (arr 300+50=350)
 
-	arrD, ok := res.EarliestArrival["D"]
+	arrD, ok := res.Arrival("D")
 	if !ok {
 		t.Error("Stop D not reached")
 	} else if arrD != 350 {
@@ -60,3 +60,103 @@ func TestRAPTOR(t *testing.T) {
 
 	t.Logf("Path to D: %+v", path)
 }
+
+func TestRAPTOR_Frequencies(t *testing.T) {
+	// A BRT line from A to B every ten minutes from 06:00, exactly.
+	stopTimes := []gtfs.StopTime{
+		{TripID: "BRT", StopID: "A", ArrivalTime: 0, DepartureTime: 0, StopSequence: 1},
+		{TripID: "BRT", StopID: "B", ArrivalTime: 900, DepartureTime: 900, StopSequence: 2},
+	}
+	frequencies := []gtfs.Frequency{{TripID: "BRT", StartTime: 6 * 3600, EndTime: 10 * 3600, Headway: 600, ExactTimes: true}}
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"BRT": "R"}, frequencies...)
+
+	res := NewRouter(idx, nil).Search("A", 7*3600+1)
+
+	if arr, _ := res.Arrival("B"); arr != 7*3600+600+900 {
+		t.Errorf("arrival at B = %s, want 07:25:00 on the 07:10:00 run", arr)
+	}
+	path := res.ReconstructPath("B")
+	if len(path) != 1 || path[0].TripID != "BRT" {
+		t.Errorf("path = %+v, want one BRT leg", path)
+	}
+}
+
+func TestRAPTOR_BothDirectionsOnOneRoute(t *testing.T) {
+	// Trips in both directions share route_id R.
+	stopTimes := []gtfs.StopTime{
+		{TripID: "OUT", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "OUT", StopID: "B", ArrivalTime: 200, DepartureTime: 200, StopSequence: 2},
+		{TripID: "OUT", StopID: "C", ArrivalTime: 300, DepartureTime: 300, StopSequence: 3},
+		{TripID: "BACK", StopID: "C", ArrivalTime: 400, DepartureTime: 400, StopSequence: 1},
+		{TripID: "BACK", StopID: "B", ArrivalTime: 500, DepartureTime: 500, StopSequence: 2},
+		{TripID: "BACK", StopID: "A", ArrivalTime: 600, DepartureTime: 600, StopSequence: 3},
+	}
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"OUT": "R", "BACK": "R"})
+
+	res := NewRouter(idx, nil).Search("C", 0)
+	if arr, ok := res.Arrival("A"); !ok || arr != 600 {
+		t.Errorf("arrival at A = %d, %v, want 600 on the return trip", arr, ok)
+	}
+}
+
+func TestRouter_Journeys(t *testing.T) {
+	// A slow direct line from A to D, and a faster way changing at B.
+	stopTimes := []gtfs.StopTime{
+		{TripID: "SLOW", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "SLOW", StopID: "D", ArrivalTime: 1000, DepartureTime: 1000, StopSequence: 2},
+		{TripID: "FAST1", StopID: "A", ArrivalTime: 200, DepartureTime: 200, StopSequence: 1},
+		{TripID: "FAST1", StopID: "B", ArrivalTime: 300, DepartureTime: 300, StopSequence: 2},
+		{TripID: "FAST2", StopID: "C", ArrivalTime: 400, DepartureTime: 400, StopSequence: 1},
+		{TripID: "FAST2", StopID: "D", ArrivalTime: 500, DepartureTime: 500, StopSequence: 2},
+	}
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"SLOW": "R1", "FAST1": "R2", "FAST2": "R3"})
+	router := NewRouter(idx, map[gtfs.StopID][]Transfer{"B": {{To: "C", Duration: 60}}})
+
+	res := router.Run(Query{Source: "A", Target: "D", Departure: 0, MaxTransfers: DefaultMaxTransfers})
+	journeys := res.Journeys("D")
+	if len(journeys) != 2 {
+		t.Fatalf("Journeys() = %+v, want the direct and the faster one", journeys)
+	}
+
+	direct, fast := journeys[0], journeys[1]
+	if direct.Transfers != 0 || direct.Departure != 100 || direct.Arrival != 1000 || len(direct.Legs) != 1 {
+		t.Errorf("direct journey = %+v", direct)
+	}
+	wantLegs := []Leg{
+		{From: "A", To: "B", Trip: "FAST1", Departure: 200, Arrival: 300},
+		{From: "B", To: "C", Departure: 300, Arrival: 360},
+		{From: "C", To: "D", Trip: "FAST2", Departure: 400, Arrival: 500},
+	}
+	if fast.Transfers != 1 || fast.Departure != 200 || fast.Arrival != 500 || len(fast.Legs) != len(wantLegs) {
+		t.Fatalf("fast journey = %+v", fast)
+	}
+	for i, leg := range wantLegs {
+		if fast.Legs[i] != leg {
+			t.Errorf("leg %d = %+v, want %+v", i, fast.Legs[i], leg)
+		}
+	}
+
+	// Without transfers only the direct line is left.
+	res = router.Run(Query{Source: "A", Target: "D", Departure: 0, MaxTransfers: 0})
+	if journeys := res.Journeys("D"); len(journeys) != 1 || journeys[0].Arrival != 1000 {
+		t.Errorf("Journeys() with no transfers = %+v, want the direct line", journeys)
+	}
+}
+
+func TestRouter_UnknownStops(t *testing.T) {
+	idx := gtfs.BuildIndex([]gtfs.StopTime{
+		{TripID: "T", StopID: "A", ArrivalTime: 100, DepartureTime: 100, StopSequence: 1},
+		{TripID: "T", StopID: "B", ArrivalTime: 200, DepartureTime: 200, StopSequence: 2},
+	}, gtfs.TripToRoute{"T": "R"})
+	router := NewRouter(idx, nil)
+
+	if router.HasStop("Z") || !router.HasStop("B") {
+		t.Error("HasStop() does not match the index")
+	}
+	if j := router.Run(Query{Source: "A", Target: "Z", MaxTransfers: 1}).Journeys("Z"); j != nil {
+		t.Errorf("Journeys(Z) = %+v, want none", j)
+	}
+	if _, ok := router.Run(Query{Source: "Z", Target: "B", MaxTransfers: 1}).Arrival("B"); ok {
+		t.Error("B reached from an unknown stop")
+	}
+}
@@ -0,0 +1,195 @@
+package raptor
+
+import (
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+// ArriveBy runs reverse RAPTOR: it scans backwards in time from q.Target,
+// reached at arrival or earlier, for the latest departures from q.Source
+// at q.Departure or later. Round k alights from the k-th trip before the
+// target, and footpaths are walked in reverse: journeys may end with a
+// walk to the target but not start with one, as with Run.
+//
+// The journeys returned are the latest departure for each number of
+// transfers that leaves later than with fewer, ordered by transfers like
+// Result.Journeys. Like Run, it overwrites the labels of the router's
+// last search.
+func (r *Router) ArriveBy(q Query, arrival time.Time) []Journey {
+	r.reset()
+	rounds := q.MaxTransfers + 1
+	r.grow(rounds + 1)
+
+	src, target, ok := r.endpoints(q)
+	if !ok || target < 0 || src == target {
+		return nil
+	}
+	last := r.searchBackward(target, src, arrival, q.Departure, rounds)
+
+	n := len(r.stops)
+	var journeys []Journey
+	for k := 1; k <= last; k++ {
+		i := k*n + int(src)
+		if r.arrivals[i] == unreached || r.parents[i].from == -1 {
+			// Not reached, or no later than with fewer trips.
+			continue
+		}
+		j := journeyOf(r.reverseLegs(src, k))
+		for len(journeys) > 0 && journeys[len(journeys)-1].Transfers >= j.Transfers {
+			journeys = journeys[:len(journeys)-1]
+		}
+		journeys = append(journeys, j)
+	}
+	return journeys
+}
+
+// searchBackward runs up to maxRounds rounds from stop origin, reached at
+// arrival, towards goal, and returns the last round run. Departures before
+// earliest are not kept.
+//
+// The labels are the latest times each stop can be left, stored negated:
+// later is then smaller, and the bookkeeping of search applies unchanged.
+// parents point from a stop to the next one towards origin.
+func (r *Router) searchBackward(origin, goal int32, arrival, earliest time.Time, maxRounds int) int {
+	n := len(r.stops)
+	for _, s := range r.markedStops {
+		r.marked[s] = false
+	}
+	r.markedStops = r.markedStops[:0]
+
+	limit := -earliest
+	r.reached = append(r.reached, origin)
+	r.arrivals[origin] = -arrival
+	r.best[origin] = -arrival
+	r.parents[origin] = step{from: -1}
+	r.mark(origin)
+	// The last trip may be left a walk away from origin.
+	for _, fp := range r.arrivingTransfers[r.arrivingStart[origin]:r.arrivingStart[origin+1]] {
+		if v := -arrival + fp.duration; v <= limit && fp.to != goal && v < r.arrivals[fp.to] {
+			r.improve(fp.to, v, r.arrivals[:n])
+			r.parents[fp.to] = step{from: origin, transfer: true, depart: -v, arrive: arrival}
+		}
+	}
+
+	numIndexed := int32(len(r.index.Stops))
+	last := 0
+	for k := 1; k <= maxRounds; k++ {
+		prev, cur := r.arrivals[(k-1)*n:k*n], r.arrivals[k*n:(k+1)*n]
+		parents := r.parents[k*n : (k+1)*n]
+		for _, s := range r.reached {
+			if prev[s] < cur[s] {
+				cur[s] = prev[s]
+				parents[s] = step{from: -1}
+			}
+		}
+		last = k
+
+		// The latest marked stop of each pattern is where its scan starts.
+		for _, s := range r.markedStops {
+			r.marked[s] = false
+			if s >= numIndexed {
+				continue
+			}
+			for _, ps := range r.index.PatternsAtStop(int(s)) {
+				switch start := r.patternStart[ps.Pattern]; {
+				case start == -1:
+					r.active = append(r.active, ps.Pattern)
+					r.patternStart[ps.Pattern] = ps.Index
+				case ps.Index > start:
+					r.patternStart[ps.Pattern] = ps.Index
+				}
+			}
+		}
+		r.markedStops = r.markedStops[:0]
+
+		for _, p := range r.active {
+			r.scanPatternBackward(int(p), int(r.patternStart[p]), goal, limit, prev, cur, parents)
+			r.patternStart[p] = -1
+		}
+		r.active = r.active[:0]
+
+		// Footpaths into the stops left by trip this round, arriving when
+		// the trip must be caught.
+		r.tripArrivals = r.tripArrivals[:0]
+		for _, s := range r.markedStops {
+			r.tripArrivals = append(r.tripArrivals, cur[s])
+		}
+		for i, s := range r.markedStops[:len(r.tripArrivals)] {
+			at := r.tripArrivals[i]
+			for _, fp := range r.arrivingTransfers[r.arrivingStart[s]:r.arrivingStart[s+1]] {
+				v := at + fp.duration
+				// Journeys start with a trip, so goal is not walked from.
+				if v <= limit && fp.to != goal && v < bound(cur, fp.to, goal) {
+					r.improve(fp.to, v, cur)
+					parents[fp.to] = step{from: s, transfer: true, depart: -v, arrive: -at}
+				}
+			}
+		}
+
+		if len(r.markedStops) == 0 {
+			break
+		}
+	}
+
+	return last
+}
+
+// scanPatternBackward rides the trips of pattern p backwards from stop
+// index start, alighting where the round before must be reached.
+func (r *Router) scanPatternBackward(p, start int, goal int32, limit time.Time, prev, cur []time.Time, parents []step) {
+	pattern := &r.index.Patterns[p]
+
+	var trip gtfs.Boarding
+	boarded := false
+	var alightingStop int32
+	var alightingIndex int
+	for i := start; i >= 0; i-- {
+		s := pattern.Stops[i]
+
+		if boarded {
+			dep := trip.Departure(i)
+			if v := -dep; v <= limit && v < bound(cur, s, goal) {
+				r.improve(s, v, cur)
+				parents[s] = step{from: alightingStop, trip: trip.Trip, depart: dep, arrive: trip.Arrival(alightingIndex)}
+			}
+		}
+
+		// Can we ride a later trip to this stop? Not if the one we are on
+		// arrives after we must be here.
+		if v := prev[s]; v != unreached && (!boarded || -v >= trip.Arrival(i)) {
+			if next, ok := r.index.LatestAlighting(p, i, -v); ok {
+				if !boarded || next.Arrival(i) > trip.Arrival(i) {
+					trip, boarded = next, true
+					alightingStop, alightingIndex = s, i
+				}
+			}
+		}
+	}
+}
+
+// reverseLegs follows the parents of stop s from round k of a backward
+// search on to its origin, which round 0 may reach on foot.
+func (r *Router) reverseLegs(s int32, k int) []Leg {
+	n := len(r.stops)
+
+	var legs []Leg
+	for k >= 0 {
+		st := r.parents[k*n+int(s)]
+		if st.from == -1 {
+			k--
+			continue
+		}
+		legs = append(legs, Leg{
+			From:      r.stops[s],
+			To:        r.stops[st.from],
+			Trip:      st.trip,
+			Departure: st.depart,
+			Arrival:   st.arrive,
+		})
+		s = st.from
+		if !st.transfer {
+			k--
+		}
+	}
+	return legs
+}
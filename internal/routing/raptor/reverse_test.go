@@ -0,0 +1,130 @@
+package raptor
+
+import (
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/gtfs"
+	"github.com/danielscoffee/pathcraft/internal/time"
+)
+
+func TestRouter_ArriveBy(t *testing.T) {
+	// R1 runs A - B - C every 100s, R2 runs B - D once, and D is a walk
+	// from C.
+	var stopTimes []gtfs.StopTime
+	for trip, dep := range map[gtfs.TripID]time.Time{"T1": 100, "T2": 200, "T3": 300} {
+		stopTimes = append(stopTimes,
+			gtfs.StopTime{TripID: trip, StopID: "A", ArrivalTime: dep, DepartureTime: dep, StopSequence: 1},
+			gtfs.StopTime{TripID: trip, StopID: "B", ArrivalTime: dep + 100, DepartureTime: dep + 100, StopSequence: 2},
+			gtfs.StopTime{TripID: trip, StopID: "C", ArrivalTime: dep + 200, DepartureTime: dep + 200, StopSequence: 3},
+		)
+	}
+	stopTimes = append(stopTimes,
+		gtfs.StopTime{TripID: "U", StopID: "B", ArrivalTime: 350, DepartureTime: 360, StopSequence: 1},
+		gtfs.StopTime{TripID: "U", StopID: "D", ArrivalTime: 400, DepartureTime: 400, StopSequence: 2},
+	)
+	idx := gtfs.BuildIndex(stopTimes, gtfs.TripToRoute{"T1": "R1", "T2": "R1", "T3": "R1", "U": "R2"})
+	router := NewRouter(idx, map[gtfs.StopID][]Transfer{"C": {{To: "D", Duration: 60}}})
+
+	// want is the journey leaving last.
+	tests := []struct {
+		name     string
+		arrival  time.Time
+		journeys int
+		want     []Leg
+	}{
+		{
+			// T2 reaches C at 400, a walk short, and T1 with the walk
+			// arrives at 360; changing from T2 to U leaves later.
+			name:     "transfer leaves later",
+			arrival:  420,
+			journeys: 2,
+			want: []Leg{
+				{From: "A", To: "B", Trip: "T2", Departure: 200, Arrival: 300},
+				{From: "B", To: "D", Trip: "U", Departure: 360, Arrival: 400},
+			},
+		},
+		{
+			name:     "ends with a walk",
+			arrival:  460,
+			journeys: 1,
+			want: []Leg{
+				{From: "A", To: "C", Trip: "T2", Departure: 200, Arrival: 400},
+				{From: "C", To: "D", Departure: 400, Arrival: 460},
+			},
+		},
+		{name: "too early", arrival: 359},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journeys := router.ArriveBy(Query{Source: "A", Target: "D", MaxTransfers: DefaultMaxTransfers}, tt.arrival)
+			if tt.want == nil {
+				if len(journeys) != 0 {
+					t.Fatalf("journeys = %+v, want none", journeys)
+				}
+				return
+			}
+			if len(journeys) != tt.journeys {
+				t.Fatalf("got %d journeys, want %d: %+v", len(journeys), tt.journeys, journeys)
+			}
+			legs := journeys[len(journeys)-1].Legs
+			if len(legs) != len(tt.want) {
+				t.Fatalf("legs = %+v, want %+v", legs, tt.want)
+			}
+			for i := range legs {
+				if legs[i] != tt.want[i] {
+					t.Errorf("leg %d = %+v, want %+v", i, legs[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_ArriveByMatchesRun(t *testing.T) {
+	idx, transfers := metroFeed()
+	reverse, forward := NewRouter(idx, transfers), NewRouter(idx, transfers)
+
+	for i, q := range metroQueries(20) {
+		q := Query{Source: q.source, Target: metroTarget(i), Departure: q.at, MaxTransfers: DefaultMaxTransfers}
+		arrival, ok := forward.Run(q).Arrival(q.Target)
+		if !ok {
+			t.Fatalf("from %s at %s: %s not reached", q.Source, q.Departure, q.Target)
+		}
+
+		journeys := reverse.ArriveBy(q, arrival)
+		if len(journeys) == 0 {
+			t.Fatalf("from %s to %s by %s: no journeys", q.Source, q.Target, arrival)
+		}
+		for _, j := range journeys {
+			if j.Arrival > arrival || j.Departure < q.Departure || j.Legs[0].From != q.Source || j.Legs[len(j.Legs)-1].To != q.Target {
+				t.Errorf("from %s to %s by %s: journey %+v", q.Source, q.Target, arrival, j)
+			}
+		}
+
+		// Leaving at the latest departure still arrives in time, and
+		// leaving any later does not.
+		latest := journeys[len(journeys)-1].Departure
+		at := q
+		at.Departure = latest
+		if got, _ := forward.Run(at).Arrival(q.Target); got > arrival {
+			t.Errorf("from %s to %s leaving %s: arrival %s, after %s", q.Source, q.Target, latest, got, arrival)
+		}
+		at.Departure = latest + 1
+		if got, ok := forward.Run(at).Arrival(q.Target); ok && got <= arrival {
+			t.Errorf("from %s to %s leaving %s: arrival %s, in time for %s", q.Source, q.Target, at.Departure, got, arrival)
+		}
+	}
+}
+
+func BenchmarkArriveBy(b *testing.B) {
+	idx, transfers := metroFeed()
+	router := NewRouter(idx, transfers)
+	queries := metroQueries(64)
+
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		q := queries[i%len(queries)]
+		router.ArriveBy(Query{Source: q.source, Target: metroTarget(i), MaxTransfers: DefaultMaxTransfers}, q.at+time.SecondsPerHour)
+		i++
+	}
+}
@@ -1,9 +1,13 @@
 package engine
 
 import (
+	"encoding/gob"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/danielscoffee/pathcraft/internal/elevation"
 	"github.com/danielscoffee/pathcraft/internal/geo"
 	"github.com/danielscoffee/pathcraft/internal/graph"
 	"github.com/danielscoffee/pathcraft/internal/gtfs"
@@ -15,19 +19,122 @@ import (
 )
 
 type Engine struct {
+	config    Config
 	graph     *graph.Graph
-	gtfsIndex *gtfs.StopTimeIndex
+	feed      *gtfs.Feed
+	footpaths map[gtfs.StopID][]raptor.Transfer
+	// routeFares and routeComfort price and weigh the feed's routes for
+	// multi-criteria searches.
+	routeFares   map[gtfs.RouteID]float64
+	routeComfort map[gtfs.RouteID]float64
+	// transitLoc is the time zone of the feed's stop times.
+	transitLoc *time.Location
+	// dayRouters caches a RAPTOR router over the index of each service
+	// day queried.
+	dayRouters map[gtfs.Date]*raptor.Router
+	// maxSpeed is the fastest edge in the graph, in m/s. Dividing straight
+	// line distance by it keeps the A* heuristic admissible for any profile.
+	maxSpeed float64
+	// components is derived from graph and recomputed whenever it changes.
+	components graph.Components
+	report     LoadReport
+	// source is the OSM data behind graph, kept when Config.KeepSource is
+	// set so that change files can be applied later, and built the
+	// settings graph was built with from it.
+	source *osm.Data
+	built  buildSettings
 }
 
+// buildSettings are the parts of a Config that shape the edges built from
+// OSM data beyond the profile, which the graph records itself. They are
+// saved with the source data so that changes are applied the same way.
+type buildSettings struct {
+	Elevation           bool
+	MinComponentSize    int
+	FlagSmallComponents bool
+}
+
+func (c Config) buildSettings() buildSettings {
+	return buildSettings{
+		Elevation:           c.Elevation != nil,
+		MinComponentSize:    c.MinComponentSize,
+		FlagSmallComponents: c.FlagSmallComponents,
+	}
+}
+
+func (b buildSettings) String() string {
+	s := fmt.Sprintf("min component %d", b.MinComponentSize)
+	if b.FlagSmallComponents {
+		s += " flagged"
+	}
+	if b.Elevation {
+		s += ", with elevation"
+	} else {
+		s += ", without elevation"
+	}
+	return s
+}
+
+// Config controls how graphs are built from OSM data.
+type Config struct {
+	// Profile prices the edges of graphs built by LoadOSM. Nil means walking.
+	Profile mobility.Profile
+	// Elevation is sampled for every node when set, see elevation.Open.
+	Elevation elevation.Source
+	// Simplify collapses chains of shape points after building, keeping
+	// their geometry on the merged edges. Shape points then no longer
+	// exist as nodes, so routes must start and end at kept nodes.
+	Simplify bool
+	// MinComponentSize drops disconnected fragments with fewer nodes, or
+	// flags them when FlagSmallComponents is set. See osm.Filter.
+	MinComponentSize    int
+	FlagSmallComponents bool
+	// Region, when set, clips the OSM data to an area before building,
+	// see osm.Data.Extract.
+	Region geo.Region
+	// KeepSource holds on to the OSM data a graph was built from and saves
+	// it next to the graph cache, so that ApplyChange can update the graph
+	// later without parsing the original files again.
+	KeepSource bool
+	// ValidateOnLoad runs graph.Validate on caches read by LoadGraph and
+	// rejects graphs with errors, such as edges to missing nodes.
+	ValidateOnLoad bool
+}
+
+// ErrNoSource is returned by ApplyChange when the graph was loaded without
+// the OSM data it was built from.
+var ErrNoSource = errors.New("graph has no source data")
+
+// ErrConfigMismatch is returned by ApplyChange when the engine is not
+// configured the way the graph was built, such as without its DEM.
+var ErrConfigMismatch = errors.New("build config does not match graph")
+
+// ErrProfileMismatch is returned when a route is requested with a profile
+// other than the one the loaded graph was built for.
+var ErrProfileMismatch = errors.New("profile does not match graph")
+
 func New() *Engine {
 	return &Engine{}
 }
 
+func NewWithConfig(cfg Config) *Engine {
+	return &Engine{config: cfg}
+}
+
+// Profile returns the profile graphs are built with by LoadOSM.
+func (e *Engine) Profile() mobility.Profile {
+	if e.config.Profile == nil {
+		return mobility.NewWalking(0)
+	}
+	return e.config.Profile
+}
+
 type RouteRequest struct {
 	From               int64
 	To                 int64
 	Profile            mobility.Profile
 	IncludeCoordinates bool
+	IncludeElevation   bool
 }
 
 type Coordinate struct {
@@ -36,78 +143,536 @@ type Coordinate struct {
 }
 
 type RouteResult struct {
-	Nodes       []int64
-	Coordinates []Coordinate
-	Distance    float64       // Total distance in meters
-	Duration    time.Duration // Estimated duration
+	Nodes         []int64
+	Coordinates   []Coordinate
+	Distance      float64       // Total distance in meters
+	Duration      time.Duration // Estimated duration
+	ElevationGain float64       // Total ascent in meters
+	ElevationLoss float64       // Total descent in meters
+	// Notes flags stretches of the route the profile could not fully
+	// verify, such as unmapped kerbs on a wheelchair route.
+	Notes []RouteNote
+	// ElevationProfile samples the terrain at every node of the route when
+	// IncludeElevation is set. Without elevation data every sample is zero.
+	ElevationProfile []ElevationPoint
+}
+
+// RouteNote covers consecutive route nodes sharing the same remark.
+type RouteNote struct {
+	From int64
+	To   int64
+	Note string
+}
+
+// ElevationPoint is one sample of a route's elevation chart.
+type ElevationPoint struct {
+	DistanceM  float64 // distance from the start of the route
+	ElevationM float64
 }
 
+// GraphStats describes the loaded network, see graph.NetworkStats.
 type GraphStats struct {
-	Nodes int
-	Edges int
+	graph.NetworkStats
+	// Components lists the sizes of the strongly connected components,
+	// largest first. Islands counts nodes flagged as too small at build.
+	Components []int
+	Islands    int
+	Profile    string
 }
 
-func (e *Engine) LoadOSM(path string) error {
-	data, err := osm.ParseFile(path)
+// LoadOSM builds the graph from one or more OSM files. Several files, such
+// as neighbouring regional extracts, are merged first with osm.Merge so
+// that ways crossing their border connect; LoadReport tells whether they
+// did.
+func (e *Engine) LoadOSM(paths ...string) error {
+	parts, data, merge, err := e.readOSM(paths)
 	if err != nil {
-		return fmt.Errorf("parsing OSM file: %w", err)
+		return err
+	}
+	e.LoadData(data)
+
+	e.report.Merge = merge
+	for i, part := range parts {
+		src := SourceStats{Path: paths[i]}
+		for id := range part.Nodes {
+			if !e.graph.HasNode(graph.NodeID(id)) {
+				continue
+			}
+			src.Nodes++
+			if e.components.InMain(graph.NodeID(id)) {
+				src.InMain++
+			}
+		}
+		e.report.Sources = append(e.report.Sources, src)
+	}
+	return nil
+}
+
+// ReadOSM parses and merges OSM files and clips them to Config.Region the
+// way LoadOSM does, without building a graph. Pass the result to LoadData
+// to build one from it.
+func (e *Engine) ReadOSM(paths ...string) (*osm.Data, error) {
+	_, data, _, err := e.readOSM(paths)
+	return data, err
+}
+
+func (e *Engine) readOSM(paths []string) ([]*osm.Data, *osm.Data, osm.MergeStats, error) {
+	if len(paths) == 0 {
+		return nil, nil, osm.MergeStats{}, errors.New("no OSM file given")
 	}
 
-	e.graph = osm.BuildGraph(data, nil)
+	parts := make([]*osm.Data, len(paths))
+	for i, path := range paths {
+		data, err := osm.ParseFile(path)
+		if err != nil {
+			return nil, nil, osm.MergeStats{}, fmt.Errorf("parsing OSM file %s: %w", path, err)
+		}
+		parts[i] = data
+	}
+
+	data, merge := parts[0], osm.MergeStats{}
+	if len(parts) > 1 {
+		data, merge = osm.Merge(parts...)
+	}
+	if e.config.Region != nil {
+		data = data.Extract(e.config.Region)
+	}
+	return parts, data, merge, nil
+}
+
+// SourceStats describes what one input file contributed to the graph.
+type SourceStats struct {
+	Path   string
+	Nodes  int // graph nodes found in the file, shared ones included
+	InMain int // of those, nodes in the main component
+}
+
+// LoadReport describes the files behind the graph built by the last
+// LoadOSM call. It is empty for graphs loaded from a cache.
+type LoadReport struct {
+	Sources []SourceStats
+	Merge   osm.MergeStats
+}
+
+// Disconnected returns the sources that contributed nodes but none to the
+// main component, meaning routes cannot cross into them.
+func (r LoadReport) Disconnected() []string {
+	var paths []string
+	for _, s := range r.Sources {
+		if s.Nodes > 0 && s.InMain == 0 {
+			paths = append(paths, s.Path)
+		}
+	}
+	return paths
+}
+
+// LoadReport returns the report of the last LoadOSM call.
+func (e *Engine) LoadReport() LoadReport {
+	return e.report
+}
+
+// LoadData builds the graph from OSM data that is already in memory, such
+// as an extract about to be written out as well.
+func (e *Engine) LoadData(data *osm.Data) {
+	g := osm.BuildGraph(data, e.filter())
+	if e.config.Simplify {
+		g.Simplify(data.KeepNode)
+	}
+	e.setGraph(g)
+	e.source = nil
+	if e.config.KeepSource {
+		e.source = data
+		e.built = e.config.buildSettings()
+	}
+}
+
+func (e *Engine) filter() *osm.Filter {
+	return &osm.Filter{
+		IncludeHighways:     osm.WalkableHighways,
+		Profile:             e.Profile(),
+		Elevation:           e.config.Elevation,
+		MinComponentSize:    e.config.MinComponentSize,
+		FlagSmallComponents: e.config.FlagSmallComponents,
+	}
+}
+
+// ApplyChange updates the graph with an OsmChange file (.osc or .osc.gz),
+// recomputing only the edges around changed nodes and ways. The engine
+// must hold the graph's source data and be configured as it was built.
+func (e *Engine) ApplyChange(path string) (osm.UpdateStats, error) {
+	if e.graph == nil {
+		return osm.UpdateStats{}, fmt.Errorf("graph not loaded")
+	}
+	if e.source == nil {
+		return osm.UpdateStats{}, ErrNoSource
+	}
+	if e.graph.Profile != "" && e.graph.Profile != e.Profile().Name() {
+		return osm.UpdateStats{}, fmt.Errorf("%w: graph is built for %s, not %s", ErrProfileMismatch, e.graph.Profile, e.Profile().Name())
+	}
+	if cfg := e.config.buildSettings(); cfg != e.built {
+		return osm.UpdateStats{}, fmt.Errorf("%w: graph is built with %s, not %s", ErrConfigMismatch, e.built, cfg)
+	}
+
+	change, err := osm.ParseChangeFile(path)
+	if err != nil {
+		return osm.UpdateStats{}, fmt.Errorf("parsing change file: %w", err)
+	}
+	stats, err := osm.UpdateGraph(e.graph, e.source, change, e.filter())
+	if err != nil {
+		return stats, err
+	}
+	// Recompute everything derived from the graph.
+	e.setGraph(e.graph)
+	return stats, nil
+}
+
+// HasSource reports whether the engine holds the OSM data of its graph.
+func (e *Engine) HasSource() bool {
+	return e.source != nil
+}
+
+// SourcePath returns where SaveGraph keeps the source data of the graph
+// cache at path.
+func SourcePath(path string) string {
+	return path + ".src"
+}
+
+// settingsPath returns where SaveGraph keeps the build settings of the
+// source data at path.
+func settingsPath(path string) string {
+	return SourcePath(path) + ".cfg"
+}
+
+// Extract replaces the loaded graph with the part inside region, and
+// clips its source data alike.
+func (e *Engine) Extract(region geo.Region) error {
+	if e.graph == nil {
+		return fmt.Errorf("graph not loaded")
+	}
+	e.setGraph(e.graph.Extract(func(n graph.Node) bool {
+		return region.Contains(geo.Point{Lat: n.Lat, Lon: n.Lon})
+	}))
+	if e.source != nil {
+		e.source = e.source.Extract(region)
+	}
 	return nil
 }
 
+func (e *Engine) setGraph(g *graph.Graph) {
+	e.graph = g
+	e.report = LoadReport{}
+	e.maxSpeed = 0
+	for _, edges := range g.Edges {
+		for _, edge := range edges {
+			if edge.Cost <= 0 {
+				continue
+			}
+			if speed := edge.DistanceM / float64(edge.Cost); speed > e.maxSpeed {
+				e.maxSpeed = speed
+			}
+		}
+	}
+	e.components = g.StronglyConnectedComponents()
+}
+
 func (e *Engine) SaveGraph(path string) error {
 	if e.graph == nil {
 		return fmt.Errorf("graph not loaded")
 	}
-	return e.graph.Save(path)
+	if err := e.graph.Save(path); err != nil {
+		return err
+	}
+	if e.source == nil {
+		return nil
+	}
+	if err := e.source.Save(SourcePath(path)); err != nil {
+		return err
+	}
+	return saveSettings(settingsPath(path), e.built)
+}
+
+func saveSettings(path string, b buildSettings) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return gob.NewEncoder(f).Encode(b)
+}
+
+func loadSettings(path string) (buildSettings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return buildSettings{}, err
+	}
+	defer f.Close()
+
+	var b buildSettings
+	err = gob.NewDecoder(f).Decode(&b)
+	return b, err
 }
 
+// LoadGraph loads a graph cache. With Config.KeepSource its source data and
+// build settings are loaded too when they were saved alongside, and with
+// Config.ValidateOnLoad the graph is checked first.
 func (e *Engine) LoadGraph(path string) error {
 	g, err := graph.LoadGraph(path)
 	if err != nil {
 		return err
 	}
-	e.graph = g
+	if e.config.ValidateOnLoad {
+		if err := g.Validate().Err(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	e.source = nil
+	if e.config.KeepSource {
+		if _, err := os.Stat(SourcePath(path)); err == nil {
+			data, err := osm.LoadData(SourcePath(path))
+			if err != nil {
+				return fmt.Errorf("loading source data: %w", err)
+			}
+			built, err := loadSettings(settingsPath(path))
+			if err != nil {
+				return fmt.Errorf("loading build settings: %w", err)
+			}
+			e.source, e.built = data, built
+		}
+	}
+	e.setGraph(g)
 	return nil
 }
 
-func (e *Engine) LoadGTFS(stopTimesPath, tripsPath string) error {
-	stopTimes, err := gtfs.ParseStopTimesFile(stopTimesPath)
+// LoadGTFS loads a GTFS feed from a .zip archive or a directory, see
+// gtfs.LoadFeed.
+func (e *Engine) LoadGTFS(path string) error {
+	feed, err := gtfs.LoadFeed(path)
 	if err != nil {
-		return fmt.Errorf("parsing stop_times: %w", err)
+		return err
 	}
 
-	tripRoutes, err := gtfs.ParseTripsFile(tripsPath)
+	loc, err := feed.Location()
 	if err != nil {
-		return fmt.Errorf("parsing trips: %w", err)
+		// Without agency.txt there is no time zone to read stop times in;
+		// assume the machine's.
+		loc = time.Local
 	}
 
-	e.gtfsIndex = gtfs.BuildIndex(stopTimes, tripRoutes)
+	e.feed = feed
+	e.footpaths = raptor.TransfersFromGTFS(feed.Transfers)
+	e.routeFares = feed.RouteFares()
+	e.routeComfort = make(map[gtfs.RouteID]float64, len(feed.Routes))
+	for id, route := range feed.Routes {
+		if w, ok := modeComfort[route.Type.Mode()]; ok {
+			e.routeComfort[id] = w
+		}
+	}
+	e.transitLoc = loc
+	e.dayRouters = make(map[gtfs.Date]*raptor.Router)
 	return nil
 }
 
+// modeComfort weighs a second riding each mode in the comfort cost of
+// multi-criteria searches, against 1 for modes not listed: buses stop,
+// start and sway more than trains on their own tracks.
+var modeComfort = map[string]float64{
+	"bus":        1.25,
+	"coach":      1.1,
+	"trolleybus": 1.2,
+	"tram":       1.1,
+	"subway":     0.9,
+	"rail":       0.8,
+	"monorail":   0.9,
+}
+
+// TransitLocation returns the time zone of the loaded feed's schedules.
+func (e *Engine) TransitLocation() *time.Location {
+	return e.transitLoc
+}
+
+// maxDayRouters bounds the service days whose routers are kept.
+const maxDayRouters = 7
+
+func (e *Engine) dayRouter(d gtfs.Date) *raptor.Router {
+	if r, ok := e.dayRouters[d]; ok {
+		return r
+	}
+	if len(e.dayRouters) >= maxDayRouters {
+		clear(e.dayRouters)
+	}
+	r := raptor.NewRouter(e.feed.IndexFor(d), e.footpaths)
+	e.dayRouters[d] = r
+	return r
+}
+
+// Feed returns the loaded GTFS feed, for looking up stop and route names,
+// or nil.
+func (e *Engine) Feed() *gtfs.Feed {
+	return e.feed
+}
+
+// TransitIndex returns the RAPTOR index of the service day at falls on,
+// with the trip patterns of that day.
+func (e *Engine) TransitIndex(at time.Time) (*gtfs.StopTimeIndex, error) {
+	if e.feed == nil {
+		return nil, fmt.Errorf("GTFS not loaded")
+	}
+	day, _ := gtfs.ServiceTime(at, e.transitLoc)
+	return e.dayRouter(day).Index(), nil
+}
+
 type TransitRouteRequest struct {
-	FromStop      string
-	ToStop        string
-	DepartureTime string // HH:MM:SS
+	FromStop string
+	ToStop   string
+	// Departure is the earliest time to leave FromStop, or with ArriveBy the
+	// latest to reach ToStop. Only trips running on its service day in the
+	// feed's time zone are used, along with those of the previous day still
+	// running after midnight.
+	Departure time.Time
+	// MaxTransfers bounds the changes between trips. Zero means
+	// raptor.DefaultMaxTransfers, and a negative value allows direct trips
+	// only. Journeys with fewer transfers are returned too, see
+	// TransitRouteResult.
+	MaxTransfers int
+	// Window, when set, asks for the journeys leaving from Departure to
+	// Departure+Window rather than the earliest only, see
+	// raptor.Router.Profile. The window ends with Departure's service day.
+	Window time.Duration
+	// Dominance, when it names criteria beyond arrival and transfers, runs
+	// McRAPTOR and returns the journeys no other beats in all of them, see
+	// raptor.Router.RunMulti. Fares come from the feed's fare files. It
+	// cannot be combined with Window.
+	Dominance raptor.Dominance
+	// BagSize bounds the journeys of a multi-criteria search. Zero means
+	// raptor.DefaultBagSize.
+	BagSize int
+	// ArriveBy finds the latest departures reaching ToStop by Departure,
+	// see raptor.Router.ArriveBy. It cannot be combined with Window or
+	// Dominance.
+	ArriveBy bool
+}
+
+// TransitLeg is a ride on one trip, or a transfer on foot when TripID is
+// empty.
+type TransitLeg struct {
+	FromStop  string
+	ToStop    string
+	TripID    string
+	RouteID   string
+	Departure time.Time
+	Arrival   time.Time
+}
+
+type TransitJourney struct {
+	Departure time.Time
+	Arrival   time.Time
+	Transfers int
+	Walking   time.Duration
+	// Fare and Comfort are only set by multi-criteria searches, see
+	// raptor.Criterion.
+	Fare    float64
+	Comfort float64
+	Legs    []TransitLeg
 }
 
-func (e *Engine) TransitRoute(req TransitRouteRequest) (*raptor.Result, error) {
-	if e.gtfsIndex == nil {
+// TransitRouteResult holds the Pareto-optimal journeys in arrival time and
+// transfers: the earliest arrival for each number of transfers that beats
+// every journey with fewer. They are ordered by transfers, so each arrives
+// earlier than the one before; none means ToStop cannot be reached.
+//
+// Journeys to arrive by a time are the latest departure for each number
+// of transfers that leaves later than with fewer, likewise ordered by
+// transfers.
+//
+// For a departure window, departing later is a criterion too, and the
+// journeys are ordered by departure, then by transfers. Multi-criteria
+// searches order them by arrival.
+type TransitRouteResult struct {
+	Journeys []TransitJourney
+}
+
+func (e *Engine) TransitRoute(req TransitRouteRequest) (*TransitRouteResult, error) {
+	if e.feed == nil {
 		return nil, fmt.Errorf("GTFS not loaded")
 	}
 
-	depTime, err := pcTime.ParseTime(req.DepartureTime)
-	if err != nil {
-		return nil, fmt.Errorf("invalid departure time: %w", err)
+	day, depTime := gtfs.ServiceTime(req.Departure, e.transitLoc)
+	router := e.dayRouter(day)
+	from, to := gtfs.StopID(req.FromStop), gtfs.StopID(req.ToStop)
+	if !router.HasStop(from) {
+		return nil, fmt.Errorf("source stop %s not found", req.FromStop)
+	}
+	if !router.HasStop(to) {
+		return nil, fmt.Errorf("target stop %s not found", req.ToStop)
+	}
+	if req.Dominance.Criteria != 0 && req.Window > 0 {
+		return nil, fmt.Errorf("multi-criteria search over a departure window is not supported")
+	}
+	if req.ArriveBy && (req.Window > 0 || req.Dominance.Criteria != 0) {
+		return nil, fmt.Errorf("arrive-by search with a departure window or criteria is not supported")
+	}
+
+	maxTransfers := req.MaxTransfers
+	switch {
+	case maxTransfers == 0:
+		maxTransfers = raptor.DefaultMaxTransfers
+	case maxTransfers < 0:
+		maxTransfers = 0
+	}
+	q := raptor.Query{Source: from, Target: to, Departure: depTime, MaxTransfers: maxTransfers}
+	var journeys []raptor.Journey
+	switch {
+	case req.ArriveBy:
+		// Any departure of the service day, the previous day's trips still
+		// running after midnight included.
+		q.Departure = -gtfs.SecondsPerDay
+		journeys = router.ArriveBy(q, depTime)
+	case req.Dominance.Criteria != 0:
+		journeys = router.RunMulti(raptor.MultiQuery{
+			Query:        q,
+			Dominance:    req.Dominance,
+			BagSize:      req.BagSize,
+			RouteFares:   e.routeFares,
+			RouteComfort: e.routeComfort,
+		})
+	case req.Window > 0:
+		journeys = router.Profile(q, depTime+pcTime.Time(req.Window/time.Second))
+	default:
+		journeys = router.Run(q).Journeys(to)
 	}
 
-	router := raptor.NewRouter(e.gtfsIndex, nil)
-	res := router.Search(gtfs.StopID(req.FromStop), depTime)
+	start := day.Start(e.transitLoc)
+	at := func(t pcTime.Time) time.Time {
+		return start.Add(time.Duration(t) * time.Second)
+	}
 
-	return res, nil
+	result := &TransitRouteResult{}
+	for _, j := range journeys {
+		journey := TransitJourney{
+			Departure: at(j.Departure),
+			Arrival:   at(j.Arrival),
+			Transfers: j.Transfers,
+			Walking:   time.Duration(j.Walking) * time.Second,
+			Fare:      j.Fare,
+			Comfort:   j.Comfort,
+		}
+		for _, leg := range j.Legs {
+			l := TransitLeg{
+				FromStop:  string(leg.From),
+				ToStop:    string(leg.To),
+				TripID:    string(leg.Trip),
+				Departure: at(leg.Departure),
+				Arrival:   at(leg.Arrival),
+			}
+			if trip, ok := e.feed.Trips[leg.Trip]; ok {
+				l.RouteID = string(trip.RouteID)
+			}
+			journey.Legs = append(journey.Legs, l)
+		}
+		result.Journeys = append(result.Journeys, journey)
+	}
+	return result, nil
 }
 
 func (e *Engine) Route(req RouteRequest) (*RouteResult, error) {
@@ -134,38 +699,144 @@ func (e *Engine) Route(req RouteRequest) (*RouteResult, error) {
 		speed = mobility.DefaultWalkingSpeedMPS
 	}
 
-	heuristic := geo.HaversineHeuristic(speed)
-	path, err := astar.AStar(e.graph, sourceID, targetID, heuristic)
+	// Graphs built from OSM carry per-edge travel times for one profile.
+	// Hand-built or legacy graphs only know distances, so they are routed
+	// by length at the requested speed.
+	weight := astar.ByDistance
+	heuristic := geo.HaversineHeuristic(1)
+	timeScale := 1 / speed
+	if e.graph.Profile != "" {
+		if e.graph.Profile != req.Profile.Name() {
+			return nil, fmt.Errorf("%w: graph was built for %q, not %q", ErrProfileMismatch, e.graph.Profile, req.Profile.Name())
+		}
+		weight = astar.ByCost
+		heuristic = func(_, _ graph.Node) float64 { return 0 }
+		if e.maxSpeed > 0 {
+			heuristic = geo.HaversineHeuristic(e.maxSpeed)
+		}
+		// A faster or slower walker scales every edge alike, so the best
+		// path is unchanged and only the reported duration moves.
+		timeScale = 1
+		if e.graph.Speed > 0 {
+			timeScale = e.graph.Speed / speed
+		}
+	}
+
+	// Turn costs only make sense on graphs priced for the same profile.
+	var turn astar.TurnWeight
+	if tc, ok := req.Profile.(mobility.TurnCoster); ok && tc.HasTurnCosts() && e.graph.Profile != "" {
+		turn = e.turnWeight(tc)
+	}
+
+	var path astar.Path
+	var err error
+	if turn != nil {
+		path, err = astar.AStarTurns(e.graph, sourceID, targetID, heuristic, weight, turn)
+	} else {
+		path, err = astar.AStarWeighted(e.graph, sourceID, targetID, heuristic, weight)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("routing failed: %w", err)
 	}
 
-	nodes := make([]int64, len(path.Nodes))
+	res := &RouteResult{}
+	nodes := make([]int64, 0, len(path.Nodes))
 	var coords []Coordinate
-	if req.IncludeCoordinates {
-		coords = make([]Coordinate, len(path.Nodes))
-	}
-
-	for i, n := range path.Nodes {
-		nodes[i] = int64(n)
+	// visit records a point of the route. Shape points of simplified
+	// edges are visited too, so the output matches the unsimplified graph.
+	visit := func(node graph.Node, distance float64) {
+		nodes = append(nodes, int64(node.ID))
 		if req.IncludeCoordinates {
-			node := e.graph.Nodes[n]
-			coords[i] = Coordinate{
+			coords = append(coords, Coordinate{
 				Lat: node.Lat,
 				Lon: node.Lon,
+			})
+		}
+		if req.IncludeElevation {
+			res.ElevationProfile = append(res.ElevationProfile, ElevationPoint{
+				DistanceM:  distance,
+				ElevationM: node.Ele,
+			})
+		}
+	}
+
+	var travelSeconds float64
+	for i, n := range path.Nodes {
+		if i > 0 {
+			from := path.Nodes[i-1]
+			if edge, ok := e.graph.EdgeBetween(from, n); ok {
+				prev := e.graph.Nodes[from]
+				along := res.Distance
+				for _, shape := range edge.Geometry {
+					along += geo.HaversineDistance(prev.Lat, prev.Lon, shape.Lat, shape.Lon)
+					visit(shape, along)
+					prev = shape
+				}
+				res.Distance += edge.DistanceM
+				travelSeconds += float64(edge.Duration)
+				res.ElevationGain += edge.Ascent
+				res.ElevationLoss += edge.Descent
+				res.addNote(int64(from), int64(n), e.graph.NoteText(edge))
+			}
+			if turn != nil && i > 1 {
+				travelSeconds += turn(path.Nodes[i-2], from, n)
 			}
 		}
+		visit(e.graph.Nodes[n], res.Distance)
 	}
 
-	durationSeconds := path.TotalCost / speed
-	duration := time.Duration(durationSeconds * float64(time.Second))
+	// Legacy graphs have no durations; their path cost is the distance.
+	durationSeconds := travelSeconds * timeScale
+	if e.graph.Profile == "" {
+		durationSeconds = path.TotalCost * timeScale
+	}
+	res.Nodes = nodes
+	res.Coordinates = coords
+	res.Duration = time.Duration(durationSeconds * float64(time.Second))
+
+	return res, nil
+}
+
+// turnWeight prices turns at junctions. Bends where a way merely changes
+// direction are free; only nodes offering a choice of exit, and U-turns,
+// are charged.
+func (e *Engine) turnWeight(tc mobility.TurnCoster) astar.TurnWeight {
+	return func(prev, via, to graph.NodeID) float64 {
+		if to != prev {
+			exits := 0
+			for _, edge := range e.graph.Neighbors(via) {
+				if edge.To != prev {
+					exits++
+				}
+			}
+			if exits < 2 {
+				return 0
+			}
+		}
+		// Simplified edges bend along their geometry, so the headings
+		// come from the shape points next to the junction.
+		before, after := e.graph.Nodes[prev], e.graph.Nodes[to]
+		if edge, ok := e.graph.EdgeBetween(prev, via); ok && len(edge.Geometry) > 0 {
+			before = edge.Geometry[len(edge.Geometry)-1]
+		}
+		if edge, ok := e.graph.EdgeBetween(via, to); ok && len(edge.Geometry) > 0 {
+			after = edge.Geometry[0]
+		}
+		return tc.TurnCost(geo.Deflection(before, e.graph.Nodes[via], after))
+	}
+}
 
-	return &RouteResult{
-		Nodes:       nodes,
-		Coordinates: coords,
-		Distance:    path.TotalCost,
-		Duration:    duration,
-	}, nil
+// addNote extends the previous note when the same remark continues along
+// the route, so a long unverified footway is reported once.
+func (res *RouteResult) addNote(from, to int64, note string) {
+	if note == "" {
+		return
+	}
+	if last := len(res.Notes) - 1; last >= 0 && res.Notes[last].To == from && res.Notes[last].Note == note {
+		res.Notes[last].To = to
+		return
+	}
+	res.Notes = append(res.Notes, RouteNote{From: from, To: to, Note: note})
 }
 
 func (e *Engine) Stats() GraphStats {
@@ -173,14 +844,11 @@ func (e *Engine) Stats() GraphStats {
 		return GraphStats{}
 	}
 
-	edgeCount := 0
-	for _, edges := range e.graph.Edges {
-		edgeCount += len(edges)
-	}
-
 	return GraphStats{
-		Nodes: len(e.graph.Nodes),
-		Edges: edgeCount,
+		NetworkStats: e.graph.Stats(),
+		Components:   e.components.Sizes,
+		Islands:      len(e.graph.Islands),
+		Profile:      e.graph.Profile,
 	}
 }
 
@@ -189,7 +857,12 @@ func (e *Engine) NearestNode(lat, lon float64) (int64, float64, error) {
 		return 0, 0, fmt.Errorf("graph not loaded")
 	}
 
-	id, dist := e.graph.NearestNode(lat, lon, geo.HaversineDistance)
+	// Snapping into a disconnected fragment would make most routes fail,
+	// so only the main component is considered.
+	id, dist := e.graph.NearestNodeWhere(lat, lon, geo.HaversineDistance, e.components.InMain)
+	if dist < 0 {
+		return 0, 0, fmt.Errorf("graph has no nodes")
+	}
 	return int64(id), dist, nil
 }
 
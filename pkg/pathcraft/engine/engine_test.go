@@ -0,0 +1,48 @@
+package engine_test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielscoffee/pathcraft/internal/mobility"
+	"github.com/danielscoffee/pathcraft/pkg/pathcraft/engine"
+)
+
+func TestRoute_CacheAtOtherSpeed(t *testing.T) {
+	cfg := engine.Config{Profile: mobility.NewDriving(0)}
+	built := engine.NewWithConfig(cfg)
+	if err := built.LoadOSM("../../../examples/example.osm"); err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(t.TempDir(), "example.cache")
+	if err := built.SaveGraph(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	e := engine.NewWithConfig(cfg)
+	if err := e.LoadGraph(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	route := func(e *engine.Engine, speed float64) engine.RouteResult {
+		t.Helper()
+		res, err := e.Route(engine.RouteRequest{From: 1, To: 6, Profile: mobility.NewDriving(speed)})
+		if err != nil {
+			t.Fatalf("Route() at %g m/s error = %v", speed, err)
+		}
+		return *res
+	}
+
+	want := route(built, 0)
+	if got := route(e, 0); got.Duration != want.Duration || got.Distance != want.Distance {
+		t.Errorf("cached route = %v over %.0f m, want %v over %.0f m", got.Duration, got.Distance, want.Duration, want.Distance)
+	}
+
+	// Twice the speed halves the time, whichever speed the cache was
+	// built at.
+	fast := route(e, 2*mobility.DefaultDrivingSpeedMPS)
+	if d := fast.Duration.Seconds() - want.Duration.Seconds()/2; math.Abs(d) > 1e-3 {
+		t.Errorf("route at twice the speed = %v, want %v", fast.Duration, want.Duration/2)
+	}
+}